@@ -112,6 +112,12 @@ var cliRun = &g.CliSC{
 			Type:        "string",
 			Description: "Only run specific streams from a replication. (comma separated)",
 		},
+		{
+			Name:        "render",
+			ShortName:   "",
+			Type:        "bool",
+			Description: "Print the replication config with its template expressions rendered, without running it.",
+		},
 		{
 			Name:        "stdout",
 			ShortName:   "",
@@ -146,7 +152,13 @@ var cliRun = &g.CliSC{
 			Name:        "range",
 			ShortName:   "",
 			Type:        "string",
-			Description: "The range to use for backfill mode, separated by a single comma. Example: `2021-01-01,2021-02-01` or `1,10000`",
+			Description: "The range to use for backfill mode, separated by a single comma. Example: `2021-01-01,2021-02-01` or `1,10000`.\n                       For file streams with date tokens (e.g. `{YYYY}/{MM}/{DD}`), the paths of every date in the range are read",
+		},
+		{
+			Name:        "run-date",
+			ShortName:   "",
+			Type:        "string",
+			Description: "The date used to resolve the date tokens of file streams (e.g. `{YYYY}/{MM}/{DD}`).\n                       Accepts a date such as `2024-01-31`, or an offset from now such as `-1d`. Defaults to now",
 		},
 		{
 			Name:        "primary-key",
@@ -188,6 +200,28 @@ var cliUpdate = &g.CliSC{
 	ExecProcess: updateCLI,
 }
 
+var cliValidate = &g.CliSC{
+	Name:        "validate",
+	Description: "Validate task and replication files without running them",
+	PosFlags: []g.Flag{
+		{
+			Name:        "path",
+			ShortName:   "",
+			Type:        "string",
+			Description: "The task or replication file(s) to validate",
+		},
+	},
+	Flags: []g.Flag{
+		{
+			Name:        "schema",
+			ShortName:   "",
+			Type:        "string",
+			Description: "Print the JSON Schema of `task` or `replication` files (for editor autocomplete).",
+		},
+	},
+	ExecProcess: processValidate,
+}
+
 var cliConns = &g.CliSC{
 	Name:                  "conns",
 	Singular:              "local connection",
@@ -302,6 +336,7 @@ func init() {
 	// cliProject.Make().Add()
 	cliRun.Make().Add()
 	cliUpdate.Make().Add()
+	cliValidate.Make().Add()
 	// cliUi.Make().Add()
 
 	if telemetry {
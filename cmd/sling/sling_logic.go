@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -43,6 +44,7 @@ func processRun(c *g.CliSC) (ok bool, err error) {
 	replicationCfgPath := ""
 	taskCfgStr := ""
 	showExamples := false
+	renderOnly := false
 	selectStreams := []string{}
 	iterate := 1
 	itNumber := 1
@@ -118,6 +120,11 @@ func processRun(c *g.CliSC) (ok bool, err error) {
 				cfg.Source.Options = &sling.SourceOptions{}
 			}
 			cfg.Source.Options.Range = g.String(cast.ToString(v))
+		case "run-date":
+			if cfg.Source.Options == nil {
+				cfg.Source.Options = &sling.SourceOptions{}
+			}
+			cfg.Source.Options.RunDate = g.String(cast.ToString(v))
 
 		case "tgt-object", "tgt-table", "tgt-file":
 			cfg.Target.Object = cast.ToString(v)
@@ -164,6 +171,8 @@ func processRun(c *g.CliSC) (ok bool, err error) {
 			}
 		case "examples":
 			showExamples = cast.ToBool(v)
+		case "render":
+			renderOnly = cast.ToBool(v)
 		}
 	}
 
@@ -176,6 +185,18 @@ func processRun(c *g.CliSC) (ok bool, err error) {
 		return ok, g.Error("cannot provide replication and task configuration. Choose one.")
 	}
 
+	if renderOnly {
+		if replicationCfgPath == "" {
+			return ok, g.Error("need to provide a replication config to render")
+		}
+		rendered, err := sling.ReadReplicationFile(replicationCfgPath)
+		if err != nil {
+			return ok, g.Error(err, "could not render replication config")
+		}
+		fmt.Println(rendered)
+		return ok, nil
+	}
+
 	os.Setenv("SLING_CLI", "TRUE")
 	os.Setenv("SLING_CLI_ARGS", g.Marshal(os.Args[1:]))
 	if os.Getenv("SLING_EXEC_ID") == "" {
@@ -443,6 +464,51 @@ func runReplication(cfgPath string, selectStreams ...string) (err error) {
 	return eG.Err()
 }
 
+func processValidate(c *g.CliSC) (ok bool, err error) {
+	ok = true
+
+	switch schemaType := strings.ToLower(cast.ToString(c.Vals["schema"])); schemaType {
+	case "":
+	case "task":
+		fmt.Println(g.Pretty(sling.TaskSchema()))
+		return ok, nil
+	case "replication":
+		fmt.Println(g.Pretty(sling.ReplicationSchema()))
+		return ok, nil
+	default:
+		return ok, g.Error("invalid schema type '%s'. Expected task or replication", schemaType)
+	}
+
+	paths := append([]string{cast.ToString(c.Vals["path"])}, flaggy.TrailingArguments...)
+	paths = lo.Filter(paths, func(p string, i int) bool { return p != "" })
+	if len(paths) == 0 {
+		flaggy.ShowHelp("")
+		return ok, nil
+	}
+
+	issueCnt := 0
+	for _, path := range paths {
+		issues, err := sling.ValidateFile(path)
+		if err != nil {
+			return ok, g.Error(err, "could not validate %s", path)
+		}
+
+		for _, issue := range issues {
+			env.Println(g.F("%s: %s", path, issue))
+		}
+		if len(issues) == 0 {
+			g.Info("%s is valid", path)
+		}
+		issueCnt += len(issues)
+	}
+
+	if issueCnt > 0 {
+		return ok, g.Error("found %d issues in %d files", issueCnt, len(paths))
+	}
+
+	return ok, nil
+}
+
 func processConns(c *g.CliSC) (ok bool, err error) {
 	ok = true
 
@@ -224,6 +224,15 @@ func (c *Connection) URL() string {
 			url = g.F("%s://%s", c.Type.String(), c.Data["bucket"])
 		case dbio.TypeFileAzure:
 			url = g.F("https://%s.blob.core.windows.net/%s", c.Data["account"], c.Data["container"])
+		case dbio.TypeFileAPI:
+			// endpoints are resolved against the base url
+			host := strings.ToLower(c.Name)
+			if U, err := net.NewURL(cast.ToString(c.Data["base_url"])); err == nil && U.Hostname() != "" {
+				host = U.U.Host
+			}
+			url = g.F("%s://%s", c.Type.String(), host)
+		case dbio.TypeFileSinger, dbio.TypeFileGenerate:
+			url = g.F("%s://%s", c.Type.String(), strings.ToLower(c.Name))
 		}
 	}
 
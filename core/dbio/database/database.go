@@ -2271,6 +2271,95 @@ func (conn *BaseConn) Upsert(srcTable string, tgtTable string, primKeys []string
 	return cast.ToInt64(cnt), err
 }
 
+// Dedupe creates a copy of the source table holding one row per primary key:
+// the first row when ordering by the order fields descending. The drop fields
+// are left out of the copy. It returns the name of the new table, to upsert from.
+func Dedupe(conn Connection, tx Transaction, sourceTable string, pkFields, orderFields, dropFields []string) (dedupedTable string, err error) {
+	if len(pkFields) == 0 {
+		return "", g.Error("primary key is required to dedupe")
+	} else if len(orderFields) == 0 {
+		return "", g.Error("order fields are required to dedupe")
+	}
+
+	srcTable, err := ParseTableName(sourceTable, conn.GetType())
+	if err != nil {
+		err = g.Error(err, "could not parse source table name")
+		return
+	}
+
+	columns, err := conn.GetColumns(srcTable.FullName())
+	if err != nil {
+		err = g.Error(err, "could not get columns for "+srcTable.FullName())
+		return
+	}
+
+	fields := lo.Filter(columns.Names(), func(name string, i int) bool {
+		return !lo.ContainsBy(dropFields, func(dropField string) bool {
+			return strings.EqualFold(dropField, name)
+		})
+	})
+
+	sqlTemplate := conn.Template().Core["dedupe"]
+	if sqlTemplate == "" {
+		return "", g.Error("Did not find dedupe in template for %s", conn.GetType())
+	}
+
+	// oracle names are limited to 30 chars
+	newTable := srcTable
+	if conn.GetType() == dbio.TypeDbOracle && len(newTable.Name) > 27 {
+		newTable.Name = newTable.Name[:27]
+	}
+	newTable.Name = newTable.Name + "_dd"
+	dedupedTable = newTable.FullName()
+
+	if err = conn.DropTable(dedupedTable); err != nil {
+		err = g.Error(err, "could not drop table "+dedupedTable)
+		return
+	}
+
+	// quote with the casing of the table columns
+	quote := func(names []string, suffix string) string {
+		quoted := make([]string, len(names))
+		for i, name := range names {
+			if col := columns.GetColumn(name); col.Name != "" {
+				name = col.Name
+			}
+			quoted[i] = conn.Quote(name, false) + suffix
+		}
+		return strings.Join(quoted, ", ")
+	}
+
+	// latest first, with the nulls last
+	orderTemplate := conn.Template().Core["dedupe_order_by"]
+	if orderTemplate == "" {
+		orderTemplate = "{field} desc"
+	}
+	orderBy := strings.Join(lo.Map(orderFields, func(name string, i int) string {
+		return g.R(orderTemplate, "field", quote([]string{name}, ""))
+	}), ", ")
+
+	q := g.R(
+		sqlTemplate,
+		"table", srcTable.FullName(),
+		"new_table", dedupedTable,
+		"fields", quote(fields, ""),
+		"pk_fields", quote(pkFields, ""),
+		"order_by", orderBy,
+	)
+
+	if tx != nil {
+		_, err = tx.ExecMultiContext(tx.Context().Ctx, q)
+	} else {
+		_, err = conn.ExecMulti(q)
+	}
+	if err != nil {
+		err = g.Error(err, "could not dedupe %s", srcTable.FullName())
+		return
+	}
+
+	return dedupedTable, nil
+}
+
 // SwapTable swaps two table
 func (conn *BaseConn) SwapTable(srcTable string, tgtTable string) (err error) {
 
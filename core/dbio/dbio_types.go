@@ -24,14 +24,17 @@ type Type string
 const (
 	TypeUnknown Type = ""
 
-	TypeFileLocal  Type = "file"
-	TypeFileHDFS   Type = "hdfs"
-	TypeFileS3     Type = "s3"
-	TypeFileAzure  Type = "azure"
-	TypeFileGoogle Type = "gs"
-	TypeFileFtp    Type = "ftp"
-	TypeFileSftp   Type = "sftp"
-	TypeFileHTTP   Type = "http"
+	TypeFileLocal    Type = "file"
+	TypeFileHDFS     Type = "hdfs"
+	TypeFileS3       Type = "s3"
+	TypeFileAzure    Type = "azure"
+	TypeFileGoogle   Type = "gs"
+	TypeFileFtp      Type = "ftp"
+	TypeFileSftp     Type = "sftp"
+	TypeFileHTTP     Type = "http"
+	TypeFileAPI      Type = "api"
+	TypeFileSinger   Type = "singer"
+	TypeFileGenerate Type = "generate"
 
 	TypeDbPostgres   Type = "postgres"
 	TypeDbRedshift   Type = "redshift"
@@ -66,7 +69,7 @@ func ValidateType(tStr string) (Type, bool) {
 
 	switch t {
 	case
-		TypeFileLocal, TypeFileS3, TypeFileAzure, TypeFileGoogle, TypeFileSftp, TypeFileFtp,
+		TypeFileLocal, TypeFileS3, TypeFileAzure, TypeFileGoogle, TypeFileSftp, TypeFileFtp, TypeFileAPI, TypeFileSinger, TypeFileGenerate,
 		TypeDbPostgres, TypeDbRedshift, TypeDbStarRocks, TypeDbMySQL, TypeDbMariaDB, TypeDbOracle, TypeDbBigQuery, TypeDbSnowflake, TypeDbSQLite, TypeDbSQLServer, TypeDbAzure, TypeDbAzureDWH, TypeDbDuckDb, TypeDbMotherDuck, TypeDbClickhouse:
 		return t, true
 	}
@@ -108,7 +111,7 @@ func (t Type) Kind() Kind {
 	case TypeDbPostgres, TypeDbRedshift, TypeDbStarRocks, TypeDbMySQL, TypeDbMariaDB, TypeDbOracle, TypeDbBigQuery, TypeDbBigTable,
 		TypeDbSnowflake, TypeDbSQLite, TypeDbSQLServer, TypeDbAzure, TypeDbClickhouse, TypeDbDuckDb, TypeDbMotherDuck:
 		return KindDatabase
-	case TypeFileLocal, TypeFileHDFS, TypeFileS3, TypeFileAzure, TypeFileGoogle, TypeFileSftp, TypeFileFtp, TypeFileHTTP, Type("https"):
+	case TypeFileLocal, TypeFileHDFS, TypeFileS3, TypeFileAzure, TypeFileGoogle, TypeFileSftp, TypeFileFtp, TypeFileHTTP, Type("https"), TypeFileAPI, TypeFileSinger, TypeFileGenerate:
 		return KindFile
 	}
 	return KindUnknown
@@ -146,6 +149,9 @@ func (t Type) NameLong() string {
 		TypeFileFtp:      "FileSys - Ftp",
 		TypeFileHTTP:     "FileSys - HTTP",
 		Type("https"):    "FileSys - HTTP",
+		TypeFileAPI:      "FileSys - REST API",
+		TypeFileSinger:   "FileSys - Singer",
+		TypeFileGenerate: "FileSys - Generate",
 		TypeDbPostgres:   "DB - PostgreSQL",
 		TypeDbRedshift:   "DB - Redshift",
 		TypeDbStarRocks:  "DB - StarRocks",
@@ -178,6 +184,9 @@ func (t Type) Name() string {
 		TypeFileFtp:      "Ftp",
 		TypeFileHTTP:     "HTTP",
 		Type("https"):    "HTTP",
+		TypeFileAPI:      "REST API",
+		TypeFileSinger:   "Singer",
+		TypeFileGenerate: "Generate",
 		TypeDbPostgres:   "PostgreSQL",
 		TypeDbRedshift:   "Redshift",
 		TypeDbStarRocks:  "StarRocks",
@@ -0,0 +1,276 @@
+package filesys
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/flarco/g"
+	"github.com/slingdata-io/sling-cli/core/dbio"
+	"github.com/slingdata-io/sling-cli/core/dbio/env"
+	"github.com/slingdata-io/sling-cli/core/dbio/iop"
+)
+
+// archiveType returns the archive type of the path (zip or tar), or an empty string
+func archiveType(path string) string {
+	path, _ = trimEncryptionSuffix(strings.ToLower(strings.TrimSpace(path)))
+	switch {
+	case strings.HasSuffix(path, ".zip"):
+		return "zip"
+	case strings.HasSuffix(path, ".tar"), strings.HasSuffix(path, ".tar.gz"), strings.HasSuffix(path, ".tgz"):
+		return "tar"
+	}
+	return ""
+}
+
+func isArchive(paths ...string) bool {
+	for _, path := range paths {
+		if archiveType(path) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// globRegex converts a glob pattern into a regular expression.
+// `*` and `?` do not match `/`, while `**` matches any number of folders.
+func globRegex(pattern string) (*regexp.Regexp, error) {
+	var expr strings.Builder
+	expr.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			expr.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			expr.WriteString(".*")
+			i++
+		case c == '*':
+			expr.WriteString("[^/]*")
+		case c == '?':
+			expr.WriteString("[^/]")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	expr.WriteString("$")
+	return regexp.Compile(expr.String())
+}
+
+// archiveEntryMatcher returns a function matching the entry paths
+// with the ARCHIVE_GLOB prop. All files match if not provided.
+func archiveEntryMatcher(fs FileSysClient) (match func(name string) bool, err error) {
+	pattern := strings.TrimPrefix(fs.GetProp("ARCHIVE_GLOB"), "/")
+	if pattern == "" {
+		pattern = "**"
+	}
+
+	regex, err := globRegex(pattern)
+	if err != nil {
+		return nil, g.Error(err, "invalid archive glob: %s", pattern)
+	}
+
+	match = func(name string) bool {
+		name = strings.TrimPrefix(name, "/")
+		if strings.HasPrefix(name, "__MACOSX/") || strings.HasPrefix(path.Base(name), "._") {
+			return false // macOS metadata entries
+		}
+		return regex.MatchString(name)
+	}
+	return match, nil
+}
+
+// readArchive streams the matching entries of a zip or tar archive,
+// pushing a datastream for each of them. The entries are read one
+// at a time, without extracting the archive.
+func readArchive(fs FileSysClient, url string, fileFormat FileType, pushDatastream func(ds *iop.Datastream)) (err error) {
+	match, err := archiveEntryMatcher(fs)
+	if err != nil {
+		return err
+	}
+
+	count := 0
+	processEntry := func(name string, reader io.Reader) error {
+		if !match(name) {
+			g.Trace("skipping archive entry %s", name)
+			return nil
+		}
+
+		entryFormat := fileFormat
+		if entryFormat == FileTypeNone {
+			entryFormat = InferFileFormat(name)
+		}
+
+		// entries are read sequentially from the archive, the next entry is
+		// read once the entry is consumed or its datastream is closed
+		done := make(chan struct{})
+		var once sync.Once
+		signal := func() { once.Do(func() { close(done) }) }
+
+		g.Debug("reading archive entry %s [format=%s]", name, entryFormat)
+		ds, err := getArchiveEntryDatastream(fs, url+"/"+name, &signalReader{Reader: reader, done: signal}, entryFormat)
+		if err != nil {
+			return g.Error(err, "could not read archive entry %s", name)
+		}
+		ds.Defer(signal)
+		pushDatastream(ds)
+		count++
+
+		select {
+		case <-done:
+		case <-ds.Context.Ctx.Done():
+		case <-fs.Context().Ctx.Done():
+		}
+		return ds.Err()
+	}
+
+	switch archiveType(url) {
+	case "zip":
+		err = readZipArchive(fs, url, processEntry)
+	case "tar":
+		err = readTarArchive(fs, url, processEntry)
+	default:
+		err = g.Error("not an archive: %s", url)
+	}
+
+	if err != nil {
+		return g.Error(err, "could not read archive %s", url)
+	} else if count == 0 {
+		return g.Error("no entries matching '%s' in archive %s", fs.GetProp("ARCHIVE_GLOB"), url)
+	}
+
+	return nil
+}
+
+// signalReader calls done once the reader returns an error, such as io.EOF
+type signalReader struct {
+	io.Reader
+	done func()
+}
+
+func (r *signalReader) Read(p []byte) (n int, err error) {
+	n, err = r.Reader.Read(p)
+	if err != nil {
+		r.done()
+	}
+	return
+}
+
+// getArchiveEntryDatastream returns a datastream consuming the archive entry reader
+func getArchiveEntryDatastream(fs FileSysClient, entryURL string, reader io.Reader, fileFormat FileType) (ds *iop.Datastream, err error) {
+	if fileFormat == FileTypeExcel {
+		ds, err = getExcelStream(fs, reader)
+		if err != nil {
+			return nil, g.Error(err, "Error consuming Excel reader")
+		}
+		ds.Metadata.StreamURL.Value = entryURL
+		return ds, nil
+	}
+
+	ds = iop.NewDatastreamContext(fs.Context().Ctx, nil)
+	ds.SafeInference = true
+	ds.SetMetadata(fs.GetProp("METADATA"))
+	ds.Metadata.StreamURL.Value = entryURL
+	ds.SetConfig(fs.Client().Props())
+
+	err = consumeReader(ds, reader, fileFormat)
+	if err != nil {
+		return nil, g.Error(err, "Error consuming reader for %s", entryURL)
+	}
+
+	return ds, nil
+}
+
+// readZipArchive iterates over the files of a zip archive. Zip archives need
+// random access, so remote or encrypted archives are downloaded to a temp file first.
+func readZipArchive(fs FileSysClient, url string, processEntry func(name string, reader io.Reader) error) (err error) {
+	var file *os.File
+	if fs.FsType() == dbio.TypeFileLocal && fs.GetProp("ENCRYPTION") == "" {
+		file, err = os.Open(cleanLocalFilePath(url))
+		if err != nil {
+			return g.Error(err, "could not open zip file")
+		}
+	} else {
+		reader, err := getReader(fs.Self(), url)
+		if err != nil {
+			return g.Error(err, "could not get zip reader")
+		}
+
+		file, err = os.CreateTemp(env.GetTempFolder(), "dbio_temp_*.zip")
+		if err != nil {
+			return g.Error(err, "could not create temp file")
+		}
+		defer os.Remove(file.Name())
+
+		if _, err = io.Copy(file, reader); err != nil {
+			file.Close()
+			return g.Error(err, "could not download zip file")
+		}
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return g.Error(err, "could not stat zip file")
+	}
+
+	zr, err := zip.NewReader(file, stat.Size())
+	if err != nil {
+		return g.Error(err, "could not open zip archive")
+	}
+
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return g.Error(err, "could not open zip entry %s", f.Name)
+		}
+
+		err = processEntry(f.Name, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readTarArchive iterates over the files of a tar archive, which may be compressed
+func readTarArchive(fs FileSysClient, url string, processEntry func(name string, reader io.Reader) error) (err error) {
+	reader, err := getReader(fs.Self(), url)
+	if err != nil {
+		return g.Error(err, "could not get tar reader")
+	}
+
+	reader, err = iop.AutoDecompress(reader)
+	if err != nil {
+		return g.Error(err, "could not decompress tar archive")
+	}
+
+	tr := tar.NewReader(reader)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return g.Error(err, "could not read tar archive")
+		}
+
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		if err = processEntry(header.Name, tr); err != nil {
+			return err
+		}
+	}
+}
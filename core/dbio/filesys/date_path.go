@@ -0,0 +1,227 @@
+package filesys
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/flarco/g"
+	"github.com/samber/lo"
+	"github.com/slingdata-io/sling-cli/core/dbio/iop"
+	"github.com/spf13/cast"
+)
+
+// dateTokenRegex matches the date tokens of a path, such as {YYYY} or {DD}
+var dateTokenRegex = regexp.MustCompile(`\{(YYYY|YY|MMM|MM|DD|HH|hh|mm|ss)\}`)
+
+// dateOffsetRegex matches a relative date offset, such as -1d or +2h
+var dateOffsetRegex = regexp.MustCompile(`^([+-]?\d+)\s*(h|d|w|M|y)$`)
+
+// HasDateTokens returns true if the path contains date tokens
+func HasDateTokens(path string) bool {
+	return dateTokenRegex.MatchString(path)
+}
+
+// ParseRunDate parses a date (such as `2024-01-31`), or a relative
+// offset from now (such as `-1d`, `-6h`, `-1w`, `-1M` or `-1y`)
+func ParseRunDate(value string, now time.Time) (t time.Time, err error) {
+	value = strings.TrimSpace(value)
+	if value == "" || strings.EqualFold(value, "now") {
+		return now, nil
+	}
+
+	if matches := dateOffsetRegex.FindStringSubmatch(value); len(matches) == 3 {
+		num := cast.ToInt(matches[1])
+		switch matches[2] {
+		case "h":
+			return now.Add(time.Duration(num) * time.Hour), nil
+		case "d":
+			return now.AddDate(0, 0, num), nil
+		case "w":
+			return now.AddDate(0, 0, num*7), nil
+		case "M":
+			return now.AddDate(0, num, 0), nil
+		case "y":
+			return now.AddDate(num, 0, 0), nil
+		}
+	}
+
+	t, err = cast.ToTimeE(value)
+	if err != nil {
+		return t, g.Error("invalid date or offset: %s", value)
+	}
+	return t, nil
+}
+
+// ResolveDatePath replaces the date tokens of the path with the date
+func ResolveDatePath(path string, t time.Time) string {
+	return g.Rm(path, iop.GetISO8601DateMap(t))
+}
+
+// ResolveDatePaths returns the paths with the date tokens resolved. If a
+// range (`start,end`) is provided, the path is resolved for every period
+// from start to end inclusively, with the step being the finest token of
+// the path (hour, day, month or year). Otherwise the path is resolved
+// against the run date, which defaults to now.
+func ResolveDatePaths(path, runDate, dateRange string) (paths []string, err error) {
+	if !HasDateTokens(path) {
+		return []string{path}, nil
+	}
+
+	now := time.Now().UTC()
+	if dateRange == "" {
+		t, err := ParseRunDate(runDate, now)
+		if err != nil {
+			return nil, g.Error(err, "could not parse run date")
+		}
+		return []string{ResolveDatePath(path, t)}, nil
+	}
+
+	rangeArr := strings.Split(dateRange, ",")
+	if len(rangeArr) != 2 {
+		return nil, g.Error("invalid date range '%s'. Expected start and end separated by one comma, for example `2024-01-01,2024-01-31`", dateRange)
+	}
+
+	start, err := ParseRunDate(rangeArr[0], now)
+	if err != nil {
+		return nil, g.Error(err, "could not parse range start")
+	}
+	end, err := ParseRunDate(rangeArr[1], now)
+	if err != nil {
+		return nil, g.Error(err, "could not parse range end")
+	}
+	if end.Before(start) {
+		return nil, g.Error("invalid date range '%s'. The start is after the end", dateRange)
+	}
+
+	period := datePathPeriod(path)
+	for t := period.truncate(start); !t.After(end); t = period.next(t) {
+		paths = append(paths, ResolveDatePath(path, t))
+	}
+
+	return lo.Uniq(paths), nil
+}
+
+// datePeriod is the step between the dates of a range
+type datePeriod string
+
+const (
+	datePeriodSecond datePeriod = "second"
+	datePeriodMinute datePeriod = "minute"
+	datePeriodHour   datePeriod = "hour"
+	datePeriodDay    datePeriod = "day"
+	datePeriodMonth  datePeriod = "month"
+	datePeriodYear   datePeriod = "year"
+)
+
+// datePathPeriod returns the period of the finest date token of the path
+func datePathPeriod(path string) datePeriod {
+	tokens := map[string]bool{}
+	for _, match := range dateTokenRegex.FindAllStringSubmatch(path, -1) {
+		tokens[match[1]] = true
+	}
+
+	switch {
+	case tokens["ss"]:
+		return datePeriodSecond
+	case tokens["mm"]:
+		return datePeriodMinute
+	case tokens["HH"], tokens["hh"]:
+		return datePeriodHour
+	case tokens["DD"]:
+		return datePeriodDay
+	case tokens["MM"], tokens["MMM"]:
+		return datePeriodMonth
+	}
+	return datePeriodYear
+}
+
+// truncate returns the start of the period of the date, so that
+// the period of the range start is included
+func (p datePeriod) truncate(t time.Time) time.Time {
+	switch p {
+	case datePeriodSecond:
+		return t.Truncate(time.Second)
+	case datePeriodMinute:
+		return t.Truncate(time.Minute)
+	case datePeriodHour:
+		return t.Truncate(time.Hour)
+	case datePeriodDay:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	case datePeriodMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	}
+	return time.Date(t.Year(), 1, 1, 0, 0, 0, 0, t.Location())
+}
+
+// next returns the date advanced by one period
+func (p datePeriod) next(t time.Time) time.Time {
+	switch p {
+	case datePeriodSecond:
+		return t.Add(time.Second)
+	case datePeriodMinute:
+		return t.Add(time.Minute)
+	case datePeriodHour:
+		return t.Add(time.Hour)
+	case datePeriodDay:
+		return t.AddDate(0, 0, 1)
+	case datePeriodMonth:
+		return t.AddDate(0, 1, 0)
+	}
+	return t.AddDate(1, 0, 0)
+}
+
+// listDatePaths resolves the date tokens of the url, then lists the
+// files of every resolved prefix. A resolved path can end with a glob
+// pattern, such as `*.json`. Missing prefixes are skipped, since not
+// every period of a range is expected to have files.
+func listDatePaths(fs FileSysClient, url string) (paths []string, err error) {
+	urls, err := ResolveDatePaths(url, fs.GetProp("RUN_DATE"), fs.GetProp("RANGE"))
+	if err != nil {
+		return nil, g.Error(err, "could not resolve date tokens of %s", url)
+	}
+
+	var listErr error
+	for _, resolvedURL := range urls {
+		prefix, pattern := splitGlobPath(resolvedURL)
+
+		g.Trace("listing path: %s", prefix)
+		prefixPaths, err := fs.Self().ListRecursive(prefix)
+		if err != nil {
+			g.Debug("could not list %s: %s", prefix, err.Error())
+			listErr = err
+			continue
+		}
+
+		if pattern != "" {
+			regex, err := globRegex(strings.TrimPrefix(pattern, "file://"))
+			if err != nil {
+				return nil, g.Error(err, "invalid path pattern: %s", pattern)
+			}
+			prefixPaths = lo.Filter(prefixPaths, func(p string, i int) bool {
+				return regex.MatchString(strings.TrimPrefix(p, "file://"))
+			})
+		}
+
+		paths = append(paths, prefixPaths...)
+	}
+
+	if len(paths) == 0 && listErr != nil {
+		return nil, g.Error(listErr, "could not list paths of %s", url)
+	}
+
+	return lo.Uniq(paths), nil
+}
+
+// splitGlobPath splits a path into the prefix before the first folder
+// with a wildcard, and the whole path as a pattern. The pattern is
+// empty if the path has no wildcard.
+func splitGlobPath(path string) (prefix, pattern string) {
+	index := strings.Index(path, "*")
+	if index == -1 {
+		return path, ""
+	}
+
+	prefix = path[:strings.LastIndex(path[:index], "/")+1]
+	return prefix, path
+}
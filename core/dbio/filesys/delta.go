@@ -0,0 +1,478 @@
+package filesys
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flarco/g"
+	"github.com/google/uuid"
+	"github.com/samber/lo"
+	"github.com/slingdata-io/sling-cli/core/dbio"
+	"github.com/slingdata-io/sling-cli/core/dbio/iop"
+	"github.com/spf13/cast"
+)
+
+// deltaLogFolder is the folder of the transaction log of a Delta Lake table
+const deltaLogFolder = "_delta_log"
+
+var deltaLogFileRegex = regexp.MustCompile(`^(\d{20})\.json$`)
+
+// deltaAction is a line of a Delta Lake commit file. Only one field is set.
+// See https://github.com/delta-io/delta/blob/master/PROTOCOL.md
+type deltaAction struct {
+	CommitInfo *deltaCommitInfo `json:"commitInfo,omitempty"`
+	Protocol   *deltaProtocol   `json:"protocol,omitempty"`
+	MetaData   *deltaMetaData   `json:"metaData,omitempty"`
+	Add        *deltaAdd        `json:"add,omitempty"`
+	Remove     *deltaRemove     `json:"remove,omitempty"`
+}
+
+type deltaCommitInfo struct {
+	Timestamp           int64             `json:"timestamp"`
+	Operation           string            `json:"operation"`
+	OperationParameters map[string]string `json:"operationParameters"`
+	EngineInfo          string            `json:"engineInfo,omitempty"`
+	TxnID               string            `json:"txnId,omitempty"` // tells concurrent commits apart
+}
+
+type deltaProtocol struct {
+	MinReaderVersion int `json:"minReaderVersion"`
+	MinWriterVersion int `json:"minWriterVersion"`
+}
+
+type deltaFormat struct {
+	Provider string            `json:"provider"`
+	Options  map[string]string `json:"options"`
+}
+
+type deltaMetaData struct {
+	ID               string            `json:"id"`
+	Format           deltaFormat       `json:"format"`
+	SchemaString     string            `json:"schemaString"`
+	PartitionColumns []string          `json:"partitionColumns"`
+	Configuration    map[string]string `json:"configuration"`
+	CreatedTime      int64             `json:"createdTime,omitempty"`
+}
+
+type deltaAdd struct {
+	Path             string            `json:"path"`
+	PartitionValues  map[string]string `json:"partitionValues"`
+	Size             int64             `json:"size"`
+	ModificationTime int64             `json:"modificationTime"`
+	DataChange       bool              `json:"dataChange"`
+	Stats            string            `json:"stats,omitempty"`
+}
+
+type deltaRemove struct {
+	Path              string            `json:"path"`
+	DeletionTimestamp int64             `json:"deletionTimestamp"`
+	DataChange        bool              `json:"dataChange"`
+	PartitionValues   map[string]string `json:"partitionValues,omitempty"`
+	Size              int64             `json:"size,omitempty"`
+}
+
+type deltaSchema struct {
+	Type   string       `json:"type"`
+	Fields []deltaField `json:"fields"`
+}
+
+type deltaField struct {
+	Name     string         `json:"name"`
+	Type     any            `json:"type"` // string for primitives, object for nested types
+	Nullable bool           `json:"nullable"`
+	Metadata map[string]any `json:"metadata"`
+}
+
+// deltaTable is the state of a Delta Lake table, as replayed from its log
+type deltaTable struct {
+	URL      string
+	Version  int64 // -1 if the table does not exist yet
+	Protocol *deltaProtocol
+	MetaData *deltaMetaData
+	Files    map[string]deltaAdd // active data files, by relative path
+}
+
+// Fields returns the fields of the table schema
+func (t *deltaTable) Fields() (fields []deltaField, err error) {
+	if t.MetaData == nil {
+		return nil, nil
+	}
+	schema := deltaSchema{}
+	if err = json.Unmarshal([]byte(t.MetaData.SchemaString), &schema); err != nil {
+		return nil, g.Error(err, "could not parse delta table schema")
+	}
+	return schema.Fields, nil
+}
+
+// apply replays the actions of a commit file onto the table state
+func (t *deltaTable) apply(content []byte) (err error) {
+	for _, line := range bytes.Split(content, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		action := deltaAction{}
+		if err = json.Unmarshal(line, &action); err != nil {
+			return g.Error(err, "could not parse delta log action: %s", string(line))
+		}
+
+		switch {
+		case action.Protocol != nil:
+			t.Protocol = action.Protocol
+		case action.MetaData != nil:
+			t.MetaData = action.MetaData
+		case action.Add != nil:
+			t.Files[action.Add.Path] = *action.Add
+		case action.Remove != nil:
+			delete(t.Files, action.Remove.Path)
+		}
+	}
+	return nil
+}
+
+// readDeltaTable reads the log of the Delta Lake table at the provided URL.
+// Checkpoints are not read, so the log must have all versions from zero.
+func readDeltaTable(fs FileSysClient, tableURL string) (table *deltaTable, err error) {
+	table = &deltaTable{URL: tableURL, Version: -1, Files: map[string]deltaAdd{}}
+
+	versionURLs, err := listDeltaVersions(fs, tableURL)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := lo.Keys(versionURLs)
+	sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+
+	for i, version := range versions {
+		if version != int64(i) {
+			return nil, g.Error("delta log of %s is missing version %d (reading checkpoints is not supported)", tableURL, i)
+		}
+
+		reader, err := fs.GetReader(versionURLs[version])
+		if err != nil {
+			return nil, g.Error(err, "could not read delta log version %d", version)
+		}
+
+		content, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, g.Error(err, "could not read delta log version %d", version)
+		}
+
+		if err = table.apply(content); err != nil {
+			return nil, g.Error(err, "could not apply delta log version %d", version)
+		}
+		table.Version = version
+	}
+
+	return table, nil
+}
+
+// listDeltaVersions returns the URLs of the commit files of the table log, by version
+func listDeltaVersions(fs FileSysClient, tableURL string) (versionURLs map[int64]string, err error) {
+	versionURLs = map[int64]string{}
+	logURL := tableURL + "/" + deltaLogFolder
+
+	paths, err := fs.List(logURL + "/")
+	if err != nil {
+		if fs.FsType() == dbio.TypeFileLocal && !g.PathExists(cleanLocalFilePath(logURL)) {
+			return versionURLs, nil // new table
+		}
+		return nil, g.Error(err, "could not list delta log at %s", logURL)
+	}
+
+	for _, p := range paths {
+		matches := deltaLogFileRegex.FindStringSubmatch(path.Base(p))
+		if len(matches) < 2 {
+			continue
+		}
+		version, _ := strconv.ParseInt(matches[1], 10, 64)
+		versionURLs[version] = p
+	}
+
+	return versionURLs, nil
+}
+
+// checkDeltaTable checks that sling can write to the table with the mode
+func checkDeltaTable(table *deltaTable, mode string) (err error) {
+	if p := table.Protocol; p != nil && (p.MinReaderVersion > 1 || p.MinWriterVersion > 2) {
+		return g.Error("delta table at %s requires unsupported protocol (reader v%d, writer v%d)", table.URL, p.MinReaderVersion, p.MinWriterVersion)
+	} else if m := table.MetaData; m != nil && len(m.PartitionColumns) > 0 {
+		return g.Error("writing to partitioned delta tables is not supported")
+	} else if m != nil && mode == "overwrite" && cast.ToBool(m.Configuration["delta.appendOnly"]) {
+		return g.Error("delta table at %s is append-only", table.URL)
+	}
+	return nil
+}
+
+// deltaType returns the Delta Lake type matching how the column is written in parquet
+func deltaType(col iop.Column) string {
+	switch {
+	case col.IsBool():
+		return "boolean"
+	case col.IsInteger():
+		return "long"
+	case col.Type == iop.FloatType:
+		return "double"
+	case col.Type == iop.DecimalType:
+		precision := lo.Ternary(col.DbPrecision == 0, 28, lo.Ternary(col.DbPrecision > 38, 38, col.DbPrecision))
+		scale := lo.Ternary(col.DbScale == 0, 9, lo.Ternary(col.DbScale > precision, precision, col.DbScale))
+		return fmt.Sprintf("decimal(%d,%d)", precision, scale)
+	case col.IsDatetime():
+		return "timestamp"
+	}
+	return "string"
+}
+
+// writeDataflowDelta writes a dataflow into a Delta Lake table. The data is
+// written as parquet files in a new folder of the table, then a new version
+// of the log is committed. The DELTA_MODE prop is `overwrite` (default) or `append`.
+// When appending, new columns are added to the table schema. The data files
+// cannot be encrypted, and compression is only applied inside the parquet files.
+func (fs *BaseFileSysClient) writeDataflowDelta(df *iop.Dataflow, url string) (bw int64, err error) {
+	tableURL := strings.TrimSuffix(url, "/")
+	mode := strings.ToLower(lo.Ternary(fs.GetProp("DELTA_MODE") == "", "overwrite", fs.GetProp("DELTA_MODE")))
+	if !g.In(mode, "overwrite", "append") {
+		return 0, g.Error("invalid delta mode: %s (must be overwrite or append)", mode)
+	}
+
+	// delta readers must be able to open the data files
+	if encryption, err := getEncryption(fs.Self()); err != nil {
+		return 0, g.Error(err, "could not get encryption options")
+	} else if encryption != nil {
+		return 0, g.Error("encryption is not supported for delta tables")
+	}
+	compression := iop.CompressorType(strings.ToUpper(fs.GetProp("COMPRESSION")))
+	if g.In(compression, iop.Bzip2CompressorType, iop.XzCompressorType) {
+		return 0, g.Error("compression %s is not supported for delta tables (parquet supports gzip, snappy, zstd, lz4 and brotli)", strings.ToLower(string(compression)))
+	}
+
+	table, err := readDeltaTable(fs.Self(), tableURL)
+	if err != nil {
+		return 0, g.Error(err, "could not read delta table at %s", tableURL)
+	} else if err = checkDeltaTable(table, mode); err != nil {
+		return 0, err
+	}
+
+	// write the data files as parquet, with timestamps in microseconds
+	format, precision := fs.GetProp("FORMAT"), fs.GetProp("TIMESTAMP_PRECISION")
+	fs.SetProp("FORMAT", string(FileTypeParquet))
+	fs.SetProp("TIMESTAMP_PRECISION", "6")
+	defer func() {
+		fs.SetProp("FORMAT", format)
+		fs.SetProp("TIMESTAMP_PRECISION", precision)
+	}()
+
+	files := []FileReady{}
+	fileReadyChn := make(chan FileReady, 10000)
+	done := make(chan struct{})
+	go func() {
+		for file := range fileReadyChn {
+			files = append(files, file)
+		}
+		close(done)
+	}()
+
+	dataFolderURL := g.F("%s/data-%s", tableURL, uuid.NewString())
+	bw, err = fs.Self().WriteDataflowReady(df, dataFolderURL+"/*"+FileTypeParquet.Ext(), fileReadyChn)
+	<-done
+
+	if err == nil {
+		err = commitDeltaTable(fs.Self(), table, mode, files, df.Columns)
+	}
+
+	if err != nil {
+		// uncommitted files are not part of the table, remove them
+		if err := Delete(fs.Self(), dataFolderURL); err != nil {
+			g.Warn("could not delete uncommitted delta files in %s: %s", dataFolderURL, err.Error())
+		}
+		return bw, g.Error(err, "could not write delta table at %s", tableURL)
+	}
+
+	return bw, df.Err()
+}
+
+// deltaCommitRetries is the number of attempts to commit a version,
+// when other writers commit the same version concurrently
+const deltaCommitRetries = 5
+
+// exclusiveWriter is implemented by the file systems able to create a file
+// atomically, only if it does not exist yet
+type exclusiveWriter interface {
+	// writeIfNotExists writes the file, created is false if it already existed
+	writeIfNotExists(path string, data []byte) (created bool, err error)
+}
+
+// commitDeltaTable writes the next version of the log, adding the provided files.
+// The columns are used for the schema when no file was written. If the version
+// was committed by another writer meanwhile, the table is read again and the
+// commit is retried with the next version.
+func commitDeltaTable(fs FileSysClient, table *deltaTable, mode string, files []FileReady, columns iop.Columns) (err error) {
+	for attempt := 1; ; attempt++ {
+		actions, err := deltaCommitActions(table, mode, files, columns)
+		if err != nil {
+			return err
+		}
+
+		lines := make([]string, len(actions))
+		for i, action := range actions {
+			lines[i] = g.Marshal(action)
+		}
+
+		version := table.Version + 1
+		created, err := writeDeltaLog(fs, table.URL, version, []byte(strings.Join(lines, "\n")+"\n"))
+		if err != nil {
+			return g.Error(err, "could not write delta log version %d", version)
+		} else if created {
+			g.Debug("committed version %d of delta table %s [%d files added]", version, table.URL, len(files))
+			return nil
+		} else if attempt >= deltaCommitRetries {
+			return g.Error("could not commit to delta table %s, version %d was committed concurrently (%d attempts)", table.URL, version, attempt)
+		}
+
+		g.Debug("version %d of delta table %s was committed concurrently, retrying", version, table.URL)
+		if table, err = readDeltaTable(fs, table.URL); err != nil {
+			return g.Error(err, "could not read delta table before commit")
+		} else if err = checkDeltaTable(table, mode); err != nil {
+			return err
+		}
+	}
+}
+
+// writeDeltaLog creates a log file of the table, created is false if the
+// version was already committed. Local, S3, Google Cloud Storage and Azure
+// file systems create it atomically. Other file systems check that the
+// version is missing before writing it, and that it holds the written
+// content after, which can still lose a commit made in between: they
+// should have a single writer.
+func writeDeltaLog(fs FileSysClient, tableURL string, version int64, data []byte) (created bool, err error) {
+	logURL := g.F("%s/%s/%020d.json", tableURL, deltaLogFolder, version)
+	if ew, ok := fs.(exclusiveWriter); ok {
+		return ew.writeIfNotExists(logURL, data)
+	}
+
+	versionURLs, err := listDeltaVersions(fs, tableURL)
+	if err != nil {
+		return false, g.Error(err, "could not list delta log before commit")
+	} else if _, exists := versionURLs[version]; exists {
+		return false, nil
+	}
+
+	if _, err = fs.Write(logURL, bytes.NewReader(data)); err != nil {
+		return false, err
+	}
+
+	written, err := fs.GetReader(logURL)
+	if err != nil {
+		return false, g.Error(err, "could not read back %s", logURL)
+	}
+	content, err := io.ReadAll(written)
+	if err != nil {
+		return false, g.Error(err, "could not read back %s", logURL)
+	}
+
+	return bytes.Equal(content, data), nil
+}
+
+// deltaCommitActions returns the actions of the next version of the table
+func deltaCommitActions(table *deltaTable, mode string, files []FileReady, columns iop.Columns) (actions []deltaAction, err error) {
+	now := time.Now().UnixMilli()
+	actions = []deltaAction{{
+		CommitInfo: &deltaCommitInfo{
+			Timestamp:           now,
+			Operation:           "WRITE",
+			OperationParameters: map[string]string{"mode": lo.Ternary(mode == "append", "Append", "Overwrite"), "partitionBy": "[]"},
+			EngineInfo:          "sling",
+			TxnID:               uuid.NewString(),
+		},
+	}}
+
+	if table.Protocol == nil {
+		actions = append(actions, deltaAction{Protocol: &deltaProtocol{MinReaderVersion: 1, MinWriterVersion: 2}})
+	}
+
+	// build the schema from the columns of the new files
+	fields := []deltaField{}
+	fieldIndex := map[string]int{}
+	fileColumns := []iop.Columns{columns}
+	if len(files) > 0 {
+		fileColumns = lo.Map(files, func(file FileReady, i int) iop.Columns { return file.Columns })
+	}
+	if mode == "append" {
+		if fields, err = table.Fields(); err != nil {
+			return nil, err
+		}
+		for i, field := range fields {
+			fieldIndex[strings.ToLower(field.Name)] = i
+		}
+	}
+	existingFields := len(fields)
+
+	for _, cols := range fileColumns {
+		for _, col := range cols {
+			colType := deltaType(col)
+			if i, ok := fieldIndex[strings.ToLower(col.Name)]; ok {
+				if fieldType := cast.ToString(fields[i].Type); fieldType != colType {
+					source := lo.Ternary(i < existingFields, "delta table", "other data file")
+					return nil, g.Error("column %s has type %s, but %s has type %s", col.Name, colType, source, g.Marshal(fields[i].Type))
+				}
+				continue
+			}
+			fieldIndex[strings.ToLower(col.Name)] = len(fields)
+			fields = append(fields, deltaField{Name: col.Name, Type: colType, Nullable: true, Metadata: map[string]any{}})
+		}
+	}
+
+	schemaString := g.Marshal(deltaSchema{Type: "struct", Fields: fields})
+	if table.MetaData == nil || table.MetaData.SchemaString != schemaString {
+		metaData := deltaMetaData{
+			ID:               uuid.NewString(),
+			Format:           deltaFormat{Provider: "parquet", Options: map[string]string{}},
+			PartitionColumns: []string{},
+			Configuration:    map[string]string{},
+			CreatedTime:      now,
+		}
+		if table.MetaData != nil {
+			metaData = *table.MetaData
+		}
+		metaData.SchemaString = schemaString
+		actions = append(actions, deltaAction{MetaData: &metaData})
+	}
+
+	if mode == "overwrite" {
+		paths := lo.Keys(table.Files)
+		sort.Strings(paths)
+		for _, p := range paths {
+			actions = append(actions, deltaAction{Remove: &deltaRemove{
+				Path:              p,
+				DeletionTimestamp: now,
+				DataChange:        true,
+				PartitionValues:   table.Files[p].PartitionValues,
+				Size:              table.Files[p].Size,
+			}})
+		}
+	}
+
+	for _, file := range files {
+		add := &deltaAdd{
+			Path:             strings.TrimPrefix(file.URI, table.URL+"/"),
+			PartitionValues:  map[string]string{},
+			Size:             file.BytesW,
+			ModificationTime: now,
+			DataChange:       true,
+		}
+		if file.Rows >= 0 {
+			add.Stats = g.Marshal(g.M("numRecords", file.Rows))
+		}
+		actions = append(actions, deltaAction{Add: add})
+	}
+
+	return actions, nil
+}
@@ -0,0 +1,287 @@
+package filesys
+
+import (
+	"bufio"
+	"bytes"
+	_ "crypto/sha256" // hash functions used by openpgp
+	_ "crypto/sha512"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/flarco/g"
+)
+
+// EncryptionType is the type of file encryption
+type EncryptionType string
+
+const (
+	EncryptionTypeNone EncryptionType = ""
+	EncryptionTypePGP  EncryptionType = "pgp"
+)
+
+// Encryption holds the options to decrypt source files or encrypt target files.
+// Keys can be provided as a key file path, the name of an environment variable
+// holding an armored key, or the armored key itself.
+type Encryption struct {
+	Type       EncryptionType `json:"type,omitempty" yaml:"type,omitempty"`
+	PublicKey  string         `json:"public_key,omitempty" yaml:"public_key,omitempty"`   // recipients key, to encrypt
+	PrivateKey string         `json:"private_key,omitempty" yaml:"private_key,omitempty"` // to decrypt
+	Passphrase string         `json:"passphrase,omitempty" yaml:"passphrase,omitempty"`   // of the private key
+	Armor      bool           `json:"armor,omitempty" yaml:"armor,omitempty"`             // write ASCII armored files
+
+	publicKeys  openpgp.EntityList
+	privateKeys openpgp.EntityList
+}
+
+// encryptionSuffixes are the file suffixes of encrypted files
+var encryptionSuffixes = []string{".pgp", ".gpg", ".asc"}
+
+// clientEncryption is the encryption parsed from the ENCRYPTION prop of a
+// client, so that the keys are loaded once and not for every file
+type clientEncryption struct {
+	mux    sync.Mutex
+	loaded bool
+	prop   string
+	value  *Encryption
+}
+
+// getEncryption returns the encryption from the ENCRYPTION prop, with the keys
+// loaded. It returns nil if no encryption is specified.
+func getEncryption(fs FileSysClient) (e *Encryption, err error) {
+	val := fs.GetProp("ENCRYPTION")
+
+	cache := &fs.Client().encryption
+	cache.mux.Lock()
+	defer cache.mux.Unlock()
+	if cache.loaded && cache.prop == val {
+		return cache.value, nil
+	}
+
+	if e, err = parseEncryption(val); err != nil {
+		return nil, err
+	}
+	cache.loaded, cache.prop, cache.value = true, val, e
+
+	return e, nil
+}
+
+// parseEncryption parses the encryption options, and loads the keys
+func parseEncryption(val string) (e *Encryption, err error) {
+	if val == "" || val == "null" {
+		return nil, nil
+	}
+
+	e = &Encryption{}
+	if err = g.Unmarshal(val, e); err != nil {
+		return nil, g.Error(err, "could not parse encryption options")
+	}
+
+	e.Type = EncryptionType(strings.ToLower(string(e.Type)))
+	switch e.Type {
+	case EncryptionTypeNone:
+		if e.PublicKey == "" && e.PrivateKey == "" {
+			return nil, nil
+		}
+		e.Type = EncryptionTypePGP
+	case EncryptionTypePGP:
+	default:
+		return nil, g.Error("unsupported encryption type: %s", e.Type)
+	}
+
+	if e.PublicKey != "" {
+		e.publicKeys, err = loadPGPKeys(e.PublicKey)
+		if err != nil {
+			return nil, g.Error(err, "could not load public key")
+		}
+	}
+
+	if e.PrivateKey != "" {
+		e.privateKeys, err = loadPGPKeys(e.PrivateKey)
+		if err != nil {
+			return nil, g.Error(err, "could not load private key")
+		}
+
+		if err = decryptPGPKeys(e.privateKeys, e.Passphrase); err != nil {
+			return nil, g.Error(err, "could not decrypt private key")
+		}
+	}
+
+	return e, nil
+}
+
+// Suffix returns the file suffix of the encrypted files
+func (e *Encryption) Suffix() string {
+	if e.Armor {
+		return ".asc"
+	}
+	return ".pgp"
+}
+
+// Encrypt returns a reader of the encrypted content of the reader
+func (e *Encryption) Encrypt(reader io.Reader) (io.Reader, error) {
+	if len(e.publicKeys) == 0 {
+		return nil, g.Error("public_key is required to encrypt")
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		var err error
+		defer func() { pw.CloseWithError(err) }()
+
+		var w io.WriteCloser = pw
+		if e.Armor {
+			w, err = armor.Encode(pw, "PGP MESSAGE", nil)
+			if err != nil {
+				err = g.Error(err, "could not create armor encoder")
+				return
+			}
+		}
+
+		// the header is written right away, so create the writer here
+		plaintext, err := openpgp.Encrypt(w, e.publicKeys, nil, &openpgp.FileHints{IsBinary: true}, nil)
+		if err != nil {
+			err = g.Error(err, "could not create pgp encrypter")
+			return
+		}
+
+		if _, err = io.Copy(plaintext, reader); err != nil {
+			err = g.Error(err, "could not encrypt")
+			return
+		}
+
+		if err = plaintext.Close(); err != nil {
+			err = g.Error(err, "could not close pgp encrypter")
+			return
+		}
+
+		if e.Armor {
+			err = w.Close()
+		}
+	}()
+
+	return pr, nil
+}
+
+// Decrypt returns a reader of the decrypted content of the reader.
+// Both binary and ASCII armored messages are accepted.
+func (e *Encryption) Decrypt(reader io.Reader) (io.Reader, error) {
+	if len(e.privateKeys) == 0 {
+		return nil, g.Error("private_key is required to decrypt")
+	}
+
+	bReader := bufio.NewReader(reader)
+	if testBytes, _ := bReader.Peek(32); bytes.Contains(testBytes, []byte("-----BEGIN PGP")) {
+		block, err := armor.Decode(bReader)
+		if err != nil {
+			return nil, g.Error(err, "could not decode armored message")
+		}
+		reader = block.Body
+	} else {
+		reader = bReader
+	}
+
+	md, err := openpgp.ReadMessage(reader, e.privateKeys, nil, nil)
+	if err != nil {
+		return nil, g.Error(err, "could not decrypt pgp message")
+	}
+
+	return &stickyErrReader{reader: md.UnverifiedBody}, nil
+}
+
+// stickyErrReader keeps returning the first error of the reader. The openpgp
+// message body verifies the integrity again when read after EOF, which fails.
+type stickyErrReader struct {
+	reader io.Reader
+	err    error
+}
+
+func (r *stickyErrReader) Read(p []byte) (n int, err error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	n, r.err = r.reader.Read(p)
+	return n, r.err
+}
+
+// loadPGPKeys loads the keys from an armored key, the name of an
+// environment variable holding the key, or a key file path
+func loadPGPKeys(value string) (entities openpgp.EntityList, err error) {
+	value = strings.TrimSpace(value)
+
+	var content []byte
+	if strings.HasPrefix(value, "-----BEGIN PGP") {
+		content = []byte(value)
+	} else if envVal := os.Getenv(value); envVal != "" {
+		content = []byte(strings.TrimSpace(envVal))
+	} else {
+		content, err = os.ReadFile(value)
+		if err != nil {
+			return nil, g.Error(err, "could not read key file")
+		}
+	}
+
+	if bytes.Contains(content, []byte("-----BEGIN PGP")) {
+		entities, err = openpgp.ReadArmoredKeyRing(bytes.NewReader(content))
+	} else {
+		entities, err = openpgp.ReadKeyRing(bytes.NewReader(content))
+	}
+	if err != nil {
+		return nil, g.Error(err, "could not read pgp key")
+	} else if len(entities) == 0 {
+		return nil, g.Error("no pgp key found")
+	}
+
+	return entities, nil
+}
+
+// decryptPGPKeys decrypts the private keys protected with a passphrase
+func decryptPGPKeys(entities openpgp.EntityList, passphrase string) (err error) {
+	for _, entity := range entities {
+		if entity.PrivateKey != nil && entity.PrivateKey.Encrypted {
+			if err = entity.PrivateKey.Decrypt([]byte(passphrase)); err != nil {
+				return g.Error(err, "invalid passphrase")
+			}
+		}
+		for _, subkey := range entity.Subkeys {
+			if subkey.PrivateKey != nil && subkey.PrivateKey.Encrypted {
+				if err = subkey.PrivateKey.Decrypt([]byte(passphrase)); err != nil {
+					return g.Error(err, "invalid passphrase")
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// getReader returns the reader of the file, decrypted if
+// encryption is specified with the ENCRYPTION prop
+func getReader(fs FileSysClient, url string) (reader io.Reader, err error) {
+	reader, err = fs.GetReader(url)
+	if err != nil {
+		return nil, err
+	}
+
+	encryption, err := getEncryption(fs)
+	if err != nil {
+		return nil, err
+	} else if encryption == nil {
+		return reader, nil
+	}
+
+	g.Debug("decrypting %s [type=%s]", url, encryption.Type)
+	return encryption.Decrypt(reader)
+}
+
+// trimEncryptionSuffix removes the encrypted file suffix of the path
+func trimEncryptionSuffix(path string) (string, bool) {
+	for _, suffix := range encryptionSuffixes {
+		if strings.HasSuffix(strings.ToLower(path), suffix) {
+			return path[:len(path)-len(suffix)], true
+		}
+	}
+	return path, false
+}
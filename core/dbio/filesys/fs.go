@@ -89,6 +89,12 @@ func NewFileSysClientContext(ctx context.Context, fst dbio.Type, props ...string
 		fsClient = &GoogleFileSysClient{}
 	case dbio.TypeFileHTTP:
 		fsClient = &HTTPFileSysClient{}
+	case dbio.TypeFileAPI:
+		fsClient = &APIFileSysClient{}
+	case dbio.TypeFileSinger:
+		fsClient = &SingerFileSysClient{}
+	case dbio.TypeFileGenerate:
+		fsClient = &GenerateFileSysClient{}
 	default:
 		err = g.Error("Unrecognized File System")
 		return
@@ -153,6 +159,12 @@ func NewFileSysClientFromURLContext(ctx context.Context, url string, props ...st
 		return NewFileSysClientContext(ctx, dbio.TypeFileAzure, props...)
 	case strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://"):
 		return NewFileSysClientContext(ctx, dbio.TypeFileHTTP, props...)
+	case strings.HasPrefix(url, "api://"):
+		return NewFileSysClientContext(ctx, dbio.TypeFileAPI, props...)
+	case strings.HasPrefix(url, "singer://"):
+		return NewFileSysClientContext(ctx, dbio.TypeFileSinger, props...)
+	case strings.HasPrefix(url, "generate://"):
+		return NewFileSysClientContext(ctx, dbio.TypeFileGenerate, props...)
 	case strings.HasPrefix(url, "file://"):
 		props = append(props, g.F("concurencyLimit=%d", 20))
 		return NewFileSysClientContext(ctx, dbio.TypeFileLocal, props...)
@@ -208,11 +220,19 @@ const FileTypeParquet FileType = "parquet"
 const FileTypeAvro FileType = "avro"
 const FileTypeSAS FileType = "sas7bdat"
 const FileTypeJsonLines FileType = "jsonlines"
+const FileTypeExcel FileType = "xlsx"
+const FileTypeArrow FileType = "arrow"     // Arrow IPC stream format
+const FileTypeFeather FileType = "feather" // Arrow IPC file format (Feather V2)
+const FileTypeFixedWidth FileType = "fixed_width"
+const FileTypeDelta FileType = "delta"     // Delta Lake table (parquet files + _delta_log)
+const FileTypeIceberg FileType = "iceberg" // Iceberg table (parquet files + metadata)
 
 func (ft FileType) Ext() string {
 	switch ft {
 	case FileTypeJsonLines:
 		return ".jsonl"
+	case FileTypeFixedWidth:
+		return ".txt"
 	default:
 		return "." + string(ft)
 	}
@@ -310,6 +330,7 @@ type BaseFileSysClient struct {
 	context    g.Context
 	fsType     dbio.Type
 	df         *iop.Dataflow
+	encryption clientEncryption
 }
 
 // Context provides a pointer to context
@@ -401,7 +422,7 @@ func (fs *BaseFileSysClient) GetDatastream(urlStr string) (ds *iop.Datastream, e
 
 	if strings.Contains(strings.ToLower(urlStr), ".xlsx") {
 		g.Debug("reading datastream from %s", urlStr)
-		reader, err := fs.Self().GetReader(urlStr)
+		reader, err := getReader(fs.Self(), urlStr)
 		if err != nil {
 			err = g.Error(err, "Error getting Excel reader")
 			return ds, err
@@ -451,12 +472,14 @@ func (fs *BaseFileSysClient) GetDatastream(urlStr string) (ds *iop.Datastream, e
 		fs.Context().Wg.Read.Add()
 
 		g.Debug("reading datastream from %s [format=%s]", urlStr, fileFormat)
-		reader, err := fs.Self().GetReader(urlStr)
+		reader, err := getReader(fs.Self(), urlStr)
 		if err != nil {
 			ds.Context.CaptureErr(g.Error(err, "error getting reader"))
 			return
 		}
 
+		reader = decompressBrotli(fs.Self(), urlStr, reader)
+
 		// Wait for reader to start reading or err
 		for {
 			// Try peeking
@@ -471,23 +494,7 @@ func (fs *BaseFileSysClient) GetDatastream(urlStr string) (ds *iop.Datastream, e
 			time.Sleep(50 * time.Millisecond)
 		}
 
-		switch fileFormat {
-		case FileTypeJson:
-			err = ds.ConsumeJsonReader(reader)
-		case FileTypeXml:
-			err = ds.ConsumeXmlReader(reader)
-		case FileTypeParquet:
-			err = ds.ConsumeParquetReader(reader)
-		case FileTypeAvro:
-			err = ds.ConsumeAvroReader(reader)
-		case FileTypeSAS:
-			err = ds.ConsumeSASReader(reader)
-		case FileTypeCsv:
-			err = ds.ConsumeCsvReader(reader)
-		default:
-			g.Warn("GetDatastream | File Format not recognized: %s. Using CSV parsing", fileFormat)
-			err = ds.ConsumeCsvReader(reader)
-		}
+		err = consumeReader(ds, reader, fileFormat)
 
 		if err != nil {
 			ds.Context.CaptureErr(g.Error(err, "Error consuming reader for %s", urlStr))
@@ -498,6 +505,32 @@ func (fs *BaseFileSysClient) GetDatastream(urlStr string) (ds *iop.Datastream, e
 	return ds, err
 }
 
+// consumeReader consumes the reader into the datastream according to the file format
+func consumeReader(ds *iop.Datastream, reader io.Reader, fileFormat FileType) (err error) {
+	switch fileFormat {
+	case FileTypeJson, FileTypeJsonLines:
+		err = ds.ConsumeJsonReader(reader)
+	case FileTypeXml:
+		err = ds.ConsumeXmlReader(reader)
+	case FileTypeParquet:
+		err = ds.ConsumeParquetReader(reader)
+	case FileTypeAvro:
+		err = ds.ConsumeAvroReader(reader)
+	case FileTypeSAS:
+		err = ds.ConsumeSASReader(reader)
+	case FileTypeArrow, FileTypeFeather:
+		err = ds.ConsumeArrowReader(reader)
+	case FileTypeFixedWidth:
+		err = ds.ConsumeFixedWidthReader(reader)
+	case FileTypeCsv:
+		err = ds.ConsumeCsvReader(reader)
+	default:
+		g.Warn("GetDatastream | File Format not recognized: %s. Using CSV parsing", fileFormat)
+		err = ds.ConsumeCsvReader(reader)
+	}
+	return err
+}
+
 // ReadDataflow read
 func (fs *BaseFileSysClient) ReadDataflow(url string, cfg ...FileStreamConfig) (df *iop.Dataflow, err error) {
 	Cfg := FileStreamConfig{} // infinite
@@ -507,50 +540,25 @@ func (fs *BaseFileSysClient) ReadDataflow(url string, cfg ...FileStreamConfig) (
 
 	fs.SetProp("url", url)
 
-	if strings.HasSuffix(strings.ToLower(url), ".zip") {
-		localFs, err := NewFileSysClient(dbio.TypeFileLocal)
-		if err != nil {
-			return df, g.Error(err, "could not initialize localFs")
-		}
-
-		reader, err := fs.Self().GetReader(url)
-		if err != nil {
-			return df, g.Error(err, "could not get zip reader")
-		}
-
-		folderPath := path.Join(env.GetTempFolder(), "dbio_temp_")
-
-		zipPath := folderPath + ".zip"
-		_, err = localFs.Write(zipPath, reader)
-		if err != nil {
-			return df, g.Error(err, "could not write to "+zipPath)
-		}
-
-		paths, err := iop.Unzip(zipPath, folderPath)
-		if err != nil {
-			return df, g.Error(err, "Error unzipping")
-		}
-		// delete zip file
-		Delete(localFs, zipPath)
-
-		// TODO: handle multiple files, yielding multiple schemas
-		df, err = GetDataflow(localFs.Self(), paths, Cfg)
-		if err != nil {
-			return df, g.Error(err, "Error making dataflow")
-		}
-
-		// delete unzipped folder when done
-		df.Defer(func() { Delete(localFs, folderPath) })
-
-		return df, nil
+	if FileType(strings.ToLower(fs.GetProp("FORMAT"))) == FileTypeIceberg {
+		return fs.readDataflowIceberg(url, Cfg)
 	}
 
-	g.Trace("listing path: %s", url)
-	paths, err := fs.Self().ListRecursive(url)
+	var paths []string
+	if HasDateTokens(url) {
+		paths, err = listDatePaths(fs.Self(), url)
+	} else {
+		g.Trace("listing path: %s", url)
+		paths, err = fs.Self().ListRecursive(url)
+	}
 	if err != nil {
 		err = g.Error(err, "Error getting paths")
 		return
 	}
+
+	// skip manifest and success marker files
+	paths = lo.Filter(paths, func(path string, i int) bool { return !isSidecarFile(path) })
+
 	df, err = GetDataflow(fs.Self(), paths, Cfg)
 	if err != nil {
 		err = g.Error(err, "error getting dataflow")
@@ -558,37 +566,15 @@ func (fs *BaseFileSysClient) ReadDataflow(url string, cfg ...FileStreamConfig) (
 	}
 
 	df.FsURL = url
+	df.FsPaths = paths
 	return
 }
 
 // WriteDataflow writes a dataflow to a file sys.
 func (fs *BaseFileSysClient) WriteDataflow(df *iop.Dataflow, url string) (bw int64, err error) {
-	// handle excel file here, generate reader
-	if strings.Contains(strings.ToLower(url), ".xlsx") {
-		xls := NewExcel()
-
-		sheetName := fs.GetProp("sheet")
-		if sheetName == "" {
-			sheetName = "Sheet1"
-		}
-
-		err = xls.WriteSheet(sheetName, iop.MergeDataflow(df), "overwrite")
-		if err != nil {
-			err = g.Error(err, "error writing to excel file")
-			return
-		}
-
-		pr, pw := io.Pipe()
-		go func() {
-			defer pw.Close()
-			err = xls.WriteToWriter(pw)
-			if err != nil {
-				df.Context.CaptureErr(g.Error(err, "error writing to excel file"))
-			}
-		}()
-
-		bw, err = fs.Self().Write(url, pr)
-		return
+	fileFormat := FileType(strings.ToLower(cast.ToString(fs.GetProp("FORMAT"))))
+	if fileFormat == FileTypeDelta {
+		return fs.writeDataflowDelta(df, url)
 	}
 
 	fileReadyChn := make(chan FileReady, 10000)
@@ -603,6 +589,26 @@ func (fs *BaseFileSysClient) WriteDataflow(df *iop.Dataflow, url string) (bw int
 	return fs.Self().WriteDataflowReady(df, url, fileReadyChn)
 }
 
+// excelBatchReader writes a datastream into an Excel workbook, in its own
+// sheet(s), and returns a reader of the workbook.
+func (fs *BaseFileSysClient) excelBatchReader(ds *iop.Datastream) (batchR *iop.BatchReader, err error) {
+	xls := NewExcel()
+
+	sheetName := ExcelSheetName(lo.Ternary(fs.GetProp("sheet") == "", "Sheet1", fs.GetProp("sheet")))
+	header := fs.GetProp("header") == "" || cast.ToBool(fs.GetProp("header"))
+
+	if _, err = xls.WriteStream(sheetName, ds, header); err != nil {
+		return nil, g.Error(err, "error writing to excel file")
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(xls.WriteToWriter(pw))
+	}()
+
+	return &iop.BatchReader{Columns: ds.Columns, Reader: pr, Counter: cast.ToInt(ds.Count), Batch: ds.CurrentBatch}, nil
+}
+
 // GetReaders returns one or more readers from specified paths in specified FileSysClient
 func (fs *BaseFileSysClient) GetReaders(paths ...string) (readers []io.Reader, err error) {
 	if len(paths) == 0 {
@@ -611,7 +617,7 @@ func (fs *BaseFileSysClient) GetReaders(paths ...string) (readers []io.Reader, e
 	}
 
 	for _, path := range paths {
-		reader, err := fs.Self().GetReader(path)
+		reader, err := getReader(fs.Self(), path)
 		if err != nil {
 			return nil, g.Error(err, "Unable to process "+path)
 		}
@@ -626,6 +632,21 @@ type FileReady struct {
 	URI     string
 	BytesW  int64
 	BatchID string
+	Rows    int    // -1 if unknown (the json and buffered csv readers do not count rows)
+	MD5     string // set if a manifest is written
+	SHA256  string // set if a manifest is written
+}
+
+// decompressBrotli wraps the reader with a brotli decompressor if the file is
+// brotli compressed, since brotli cannot be auto-detected from magic bytes
+func decompressBrotli(fs FileSysClient, url string, reader io.Reader) io.Reader {
+	url, _ = trimEncryptionSuffix(url)
+	compressor := iop.NewCompressor(iop.BrotliCompressorType)
+	compression := iop.CompressorType(strings.ToUpper(fs.GetProp("COMPRESSION")))
+	if compression == iop.BrotliCompressorType || strings.HasSuffix(strings.ToLower(url), compressor.Suffix()) {
+		reader, _ = compressor.Decompress(reader)
+	}
+	return reader
 }
 
 // WriteDataflowReady writes to a file sys and notifies the fileReady chan.
@@ -655,6 +676,11 @@ func (fs *BaseFileSysClient) WriteDataflowReady(df *iop.Dataflow, url string, fi
 		fileFormat = InferFileFormat(url)
 	}
 
+	encryption, err := getEncryption(fsClient)
+	if err != nil {
+		return 0, g.Error(err, "could not get encryption options")
+	}
+
 	url = strings.TrimSuffix(url, "/")
 
 	singleFile := fileRowLimit == 0 && fileBytesLimit == 0 && len(df.Streams) == 1
@@ -673,10 +699,15 @@ func (fs *BaseFileSysClient) WriteDataflowReady(df *iop.Dataflow, url string, fi
 	}
 
 	// adjust fileBytesLimit due to compression
-	if g.In(compression, iop.GzipCompressorType, iop.ZStandardCompressorType, iop.SnappyCompressorType) {
+	if g.In(compression, iop.GzipCompressorType, iop.ZStandardCompressorType, iop.SnappyCompressorType, iop.Lz4CompressorType, iop.XzCompressorType, iop.BrotliCompressorType) {
 		fileBytesLimit = fileBytesLimit * 6 // compressed, multiply
 	}
 
+	manifest, err := newManifestWriter(fsClient, url, singleFile)
+	if err != nil {
+		return 0, g.Error(err, "could not get manifest options")
+	}
+
 	processStream := func(ds *iop.Datastream, partURL string) {
 		defer df.Context.Wg.Read.Done()
 		localCtx := g.NewContext(ds.Context.Ctx, concurrency)
@@ -684,10 +715,14 @@ func (fs *BaseFileSysClient) WriteDataflowReady(df *iop.Dataflow, url string, fi
 		writePart := func(reader io.Reader, batchR *iop.BatchReader, partURL string) {
 			defer localCtx.Wg.Read.Done()
 
+			reader, checksums := manifest.HashReader(reader)
 			bw0, err := fsClient.Write(partURL, reader)
 			if batchR.Counter != 0 {
 				bID := lo.Ternary(batchR.Batch != nil, batchR.Batch.ID(), "")
-				fileReadyChn <- FileReady{batchR.Columns, partURL, bw0, bID}
+				file := FileReady{Columns: batchR.Columns, URI: partURL, BytesW: bw0, BatchID: bID, Rows: batchR.Counter}
+				file.MD5, file.SHA256 = checksums()
+				manifest.Add(file)
+				fileReadyChn <- file
 			} else {
 				g.DebugLow("no data, did not write to %s", partURL)
 			}
@@ -711,12 +746,26 @@ func (fs *BaseFileSysClient) WriteDataflowReady(df *iop.Dataflow, url string, fi
 			fileCount++
 			fileSuffix := lo.Ternary(fileExt == "", fileFormat.Ext(), fileExt)
 			subPartURL := fmt.Sprintf("%s.%04d%s", partURL, fileCount, fileSuffix)
+			encryptionSuffix := ""
+			if encryption != nil {
+				encryptionSuffix = encryption.Suffix()
+			}
+
 			if singleFile {
 				subPartURL = partURL
+				if trimmedURL, ok := trimEncryptionSuffix(subPartURL); ok && encryption != nil {
+					encryptionSuffix = subPartURL[len(trimmedURL):] // keep provided suffix
+					subPartURL = trimmedURL
+				}
+
 				for _, comp := range []iop.CompressorType{
 					iop.GzipCompressorType,
 					iop.SnappyCompressorType,
 					iop.ZStandardCompressorType,
+					iop.Bzip2CompressorType,
+					iop.Lz4CompressorType,
+					iop.XzCompressorType,
+					iop.BrotliCompressorType,
 				} {
 					compressor := iop.NewCompressor(comp)
 					if strings.HasSuffix(subPartURL, compressor.Suffix()) {
@@ -728,14 +777,26 @@ func (fs *BaseFileSysClient) WriteDataflowReady(df *iop.Dataflow, url string, fi
 			}
 
 			compressor := iop.NewCompressor(compression)
-			if fileFormat == FileTypeParquet {
+			if g.In(fileFormat, FileTypeParquet, FileTypeArrow, FileTypeFeather, FileTypeExcel) {
 				compressor = iop.NewCompressor("NONE") // compression is done internally
 			} else {
 				subPartURL = subPartURL + compressor.Suffix()
 			}
 
+			reader := compressor.Compress(batchR.Reader)
+			if encryption != nil {
+				// encrypt after compressing, since encrypted data does not compress
+				encryptedReader, err := encryption.Encrypt(reader)
+				if err != nil {
+					df.Context.CaptureErr(g.Error(err, "could not encrypt"))
+					return df.Err()
+				}
+				reader = encryptedReader
+				subPartURL = subPartURL + encryptionSuffix
+			}
+
 			g.Trace("writing stream to " + subPartURL)
-			go writePart(compressor.Compress(batchR.Reader), batchR, subPartURL)
+			go writePart(reader, batchR, subPartURL)
 			localCtx.Wg.Read.Add()
 			// localCtx.MemBasedLimit(98) // wait until memory is lower than 90%
 
@@ -744,15 +805,29 @@ func (fs *BaseFileSysClient) WriteDataflowReady(df *iop.Dataflow, url string, fi
 
 		switch fileFormat {
 		case FileTypeJson:
-			for reader := range ds.NewJsonReaderChnl(fileRowLimit, fileBytesLimit) {
-				err := processReader(&iop.BatchReader{Columns: ds.Columns, Reader: reader, Counter: -1, Batch: ds.CurrentBatch})
+			for batchR := range ds.NewJsonReaderChnl(fileRowLimit, fileBytesLimit) {
+				err := processReader(batchR)
 				if err != nil {
 					break
 				}
 			}
 		case FileTypeJsonLines:
-			for reader := range ds.NewJsonLinesReaderChnl(fileRowLimit, fileBytesLimit) {
-				err := processReader(&iop.BatchReader{Columns: ds.Columns, Reader: reader, Counter: -1, Batch: ds.CurrentBatch})
+			for batchR := range ds.NewJsonLinesReaderChnl(fileRowLimit, fileBytesLimit) {
+				err := processReader(batchR)
+				if err != nil {
+					break
+				}
+			}
+		case FileTypeXml:
+			for batchR := range ds.NewXmlReaderChnl(fileRowLimit, fileBytesLimit) {
+				err := processReader(batchR)
+				if err != nil {
+					break
+				}
+			}
+		case FileTypeFixedWidth:
+			for batchR := range ds.NewFixedWidthReaderChnl(fileRowLimit, fileBytesLimit) {
+				err := processReader(batchR)
 				if err != nil {
 					break
 				}
@@ -764,6 +839,21 @@ func (fs *BaseFileSysClient) WriteDataflowReady(df *iop.Dataflow, url string, fi
 					break
 				}
 			}
+		case FileTypeArrow, FileTypeFeather:
+			for reader := range ds.NewArrowReaderChnl(fileRowLimit, fileBytesLimit, fileFormat == FileTypeFeather, compression) {
+				err := processReader(reader)
+				if err != nil {
+					break
+				}
+			}
+		case FileTypeExcel:
+			// each stream is written into its own workbook
+			batchR, err := fs.excelBatchReader(ds)
+			if err != nil {
+				df.Context.CaptureErr(err)
+				break
+			}
+			processReader(batchR)
 		case FileTypeCsv:
 			if useBufferedStream {
 				// faster, but dangerous. Holds data in memory
@@ -810,6 +900,11 @@ func (fs *BaseFileSysClient) WriteDataflowReady(df *iop.Dataflow, url string, fi
 		}
 	}
 
+	if err = manifest.Reset(); err != nil {
+		err = g.Error(err, "could not delete success marker")
+		return
+	}
+
 	partCnt := 1
 	// for ds := range df.MakeStreamCh(true) {
 	for ds := range df.StreamCh {
@@ -832,6 +927,12 @@ func (fs *BaseFileSysClient) WriteDataflowReady(df *iop.Dataflow, url string, fi
 	df.Context.Wg.Read.Wait()
 	if df.Err() != nil {
 		err = g.Error(df.Err())
+		return
+	}
+
+	// written once all the files are written successfully
+	if err = manifest.Finish(); err != nil {
+		err = g.Error(err, "could not write manifest")
 	}
 
 	return
@@ -889,6 +990,42 @@ func Delete(fs FileSysClient, path string) (err error) {
 	return nil
 }
 
+// DeleteFile deletes a single file
+func DeleteFile(fs FileSysClient, url string) (err error) {
+	if fs.FsType() == dbio.TypeFileLocal {
+		url = cleanLocalFilePath(url) // keep the path casing
+	}
+	return Delete(fs, url)
+}
+
+// MoveFile moves a file to another url, possibly on another file system,
+// by copying its content as is, then deleting the source file
+func MoveFile(srcFs FileSysClient, srcURL string, tgtFs FileSysClient, tgtURL string) (err error) {
+	if srcFs.FsType() == dbio.TypeFileLocal && tgtFs.FsType() == dbio.TypeFileLocal {
+		srcPath, tgtPath := cleanLocalFilePath(srcURL), cleanLocalFilePath(tgtURL)
+		if err = os.MkdirAll(path.Dir(tgtPath), 0777); err != nil {
+			return g.Error(err, "could not create folder for %s", tgtPath)
+		} else if err = os.Rename(srcPath, tgtPath); err != nil {
+			return g.Error(err, "could not move %s to %s", srcPath, tgtPath)
+		}
+		return nil
+	}
+
+	reader, err := srcFs.Self().GetReader(srcURL)
+	if err != nil {
+		return g.Error(err, "could not read %s", srcURL)
+	}
+
+	if _, err = tgtFs.Self().Write(tgtURL, reader); err != nil {
+		return g.Error(err, "could not write %s", tgtURL)
+	}
+
+	if err = DeleteFile(srcFs, srcURL); err != nil {
+		return g.Error(err, "could not delete %s", srcURL)
+	}
+	return nil
+}
+
 type FileStreamConfig struct {
 	Limit   int
 	Columns []string
@@ -913,7 +1050,27 @@ func GetDataflow(fs FileSysClient, paths []string, cfg FileStreamConfig) (df *io
 	go func() {
 		defer close(dsCh)
 
+		// shape every stream to the unified columns
+		var unionCols iop.Columns
+		if cast.ToBool(fs.GetProp("UNION_BY_NAME")) && len(paths) > 1 && !isArchive(paths...) {
+			var err error
+			unionCols, err = unionColumns(fs, paths)
+			if err != nil {
+				df.Context.CaptureErr(g.Error(err, "Unable to union columns at %s", fs.GetProp("url")))
+				return
+			}
+		}
+
 		pushDatastream := func(ds *iop.Datastream) {
+			if len(unionCols) > 0 {
+				var err error
+				ds, err = ds.ShapeByName(unionCols)
+				if err != nil {
+					df.Context.CaptureErr(g.Error(err, "Unable to shape stream to union columns"))
+					return
+				}
+			}
+
 			// use selected fields only when not parquet
 			skipSelect := g.In(fs.GetProp("FORMAT"), string(FileTypeParquet))
 			if len(cfg.Columns) > 1 && !skipSelect {
@@ -936,39 +1093,53 @@ func GetDataflow(fs FileSysClient, paths []string, cfg FileStreamConfig) (df *io
 			}
 		}
 
-		flatten := cast.ToBool(fs.GetProp("flatten"))
-		if flatten && (fileFormat.IsJson() || isJson(paths...)) {
+		// normalize splits nested arrays into child streams, and implies flatten
+		normalize := cast.ToBool(fs.GetProp("normalize"))
+		flatten := cast.ToBool(fs.GetProp("flatten")) || normalize
+		if flatten && (fileFormat.IsJson() || isJson(paths...)) && !isArchive(paths...) {
 			ds, err := MergeReaders(fs, FileTypeJson, paths...)
 			if err != nil {
 				df.Context.CaptureErr(g.Error(err, "Unable to merge paths at %s", fs.GetProp("url")))
 				return
 			}
-			ds, err = ProcessStreamViaTempFile(ds)
+			if normalize {
+				// the child streams are consumed along with ds, not via temp file
+				df.ChildStreams = ds.ChildStreams()
+				pushDatastream(ds)
+				return // done
+			}
+			nDs, err := ProcessStreamViaTempFile(ds)
 			if err != nil {
 				df.Context.CaptureErr(g.Error(err, "Unable to process stream via temp file"))
 				return
 			}
-			pushDatastream(ds)
+			pushDatastream(nDs)
 			return // done
 		}
 
-		if flatten && (fileFormat == FileTypeXml || isXml(paths...)) {
+		if flatten && (fileFormat == FileTypeXml || isXml(paths...)) && !isArchive(paths...) {
 			ds, err := MergeReaders(fs, FileTypeXml, paths...)
 			if err != nil {
 				df.Context.CaptureErr(g.Error(err, "Unable to merge paths at %s", fs.GetProp("url")))
 				return
 			}
-			ds, err = ProcessStreamViaTempFile(ds)
+			if normalize {
+				// the child streams are consumed along with ds, not via temp file
+				df.ChildStreams = ds.ChildStreams()
+				pushDatastream(ds)
+				return // done
+			}
+			nDs, err := ProcessStreamViaTempFile(ds)
 			if err != nil {
 				df.Context.CaptureErr(g.Error(err, "Unable to process stream via temp file"))
 				return
 			}
-			pushDatastream(ds)
+			pushDatastream(nDs)
 			return // done
 		}
 
 		// csvs with no header
-		if !cast.ToBool(fs.GetProp("header")) && (fileFormat == FileTypeCsv) {
+		if !cast.ToBool(fs.GetProp("header")) && (fileFormat == FileTypeCsv) && !isArchive(paths...) {
 			ds, err := MergeReaders(fs, fileFormat, paths...)
 			if err != nil {
 				df.Context.CaptureErr(g.Error(err, "Unable to merge paths at %s", fs.GetProp("url")))
@@ -984,12 +1155,25 @@ func GetDataflow(fs FileSysClient, paths []string, cfg FileStreamConfig) (df *io
 				continue
 			}
 
+			// stream each entry of zip and tar archives
+			if isArchive(path) {
+				err := readArchive(fs, path, fileFormat, pushDatastream)
+				if err != nil {
+					df.Context.CaptureErr(g.Error(err, "Unable to process "+path))
+					return
+				}
+				continue
+			}
+
 			ds, err := fs.GetDatastream(path)
 			if err != nil {
 				df.Context.CaptureErr(g.Error(err, "Unable to process "+path))
 				return
 			}
 			pushDatastream(ds)
+			if df.Err() != nil {
+				return
+			}
 
 			// when pulling from local disk, process one file at a time
 			if fs.FsType() == dbio.TypeFileLocal {
@@ -1180,7 +1364,7 @@ func MergeReaders(fs FileSysClient, fileType FileType, paths ...string) (ds *iop
 			go func(path string) {
 				defer ds.Context.Wg.Read.Done()
 
-				reader, err := fs.Self().GetReader(path)
+				reader, err := getReader(fs.Self(), path)
 				if err != nil {
 					setError(g.Error(err, "Error getting reader"))
 					return
@@ -1275,7 +1459,7 @@ func ProcessStreamViaTempFile(ds *iop.Datastream) (nDs *iop.Datastream, err erro
 func InferFileFormat(path string) FileType {
 	path = strings.TrimSpace(strings.ToLower(path))
 
-	for _, fileType := range []FileType{FileTypeJsonLines, FileTypeJson, FileTypeXml, FileTypeParquet, FileTypeAvro, FileTypeSAS} {
+	for _, fileType := range []FileType{FileTypeJsonLines, FileTypeJson, FileTypeXml, FileTypeParquet, FileTypeAvro, FileTypeSAS, FileTypeExcel, FileTypeArrow, FileTypeFeather} {
 		ext := fileType.Ext()
 		if strings.HasSuffix(path, ext) || strings.Contains(path, ext+".") {
 			return fileType
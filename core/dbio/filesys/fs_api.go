@@ -0,0 +1,714 @@
+package filesys
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/flarco/g"
+	"github.com/jmespath/go-jmespath"
+	"github.com/slingdata-io/sling-cli/core/dbio/iop"
+	"github.com/spf13/cast"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+// APIAuthType is the authentication type of a REST API
+type APIAuthType string
+
+const (
+	APIAuthTypeNone   APIAuthType = ""
+	APIAuthTypeBearer APIAuthType = "bearer"
+	APIAuthTypeBasic  APIAuthType = "basic"
+	APIAuthTypeAPIKey APIAuthType = "api_key"
+	APIAuthTypeOAuth2 APIAuthType = "oauth2"
+)
+
+// APIPaginationType is the pagination type of a REST API endpoint
+type APIPaginationType string
+
+const (
+	APIPaginationTypeNone   APIPaginationType = ""
+	APIPaginationTypePage   APIPaginationType = "page"
+	APIPaginationTypeOffset APIPaginationType = "offset"
+	APIPaginationTypeCursor APIPaginationType = "cursor"
+	APIPaginationTypeLink   APIPaginationType = "link"
+)
+
+// APIOptions are the options to read the records of a REST API endpoint,
+// or to send records to it. When reading, the records are extracted from
+// every response with the Records JMESPath expression (such as
+// `data.items`). If empty, the response is expected to be an array of
+// records, or a single record. When writing, the records are sent as JSON
+// arrays of BatchSize records, and every response must satisfy the
+// ResponseCheck JMESPath expression (such as `status == 'ok'`), if provided.
+// Since a batch may have been received despite a server or connection
+// error, sent batches are only retried on those errors with RetryWrites.
+type APIOptions struct {
+	Records          string            `json:"records,omitempty" yaml:"records,omitempty"`
+	Params           map[string]string `json:"params,omitempty" yaml:"params,omitempty"`
+	Headers          map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+	Pagination       *APIPagination    `json:"pagination,omitempty" yaml:"pagination,omitempty"`
+	IncrementalParam string            `json:"incremental_param,omitempty" yaml:"incremental_param,omitempty"` // query parameter bound to the incremental value
+	Method           string            `json:"method,omitempty" yaml:"method,omitempty"`                       // to send records, POST by default
+	BatchSize        int               `json:"batch_size,omitempty" yaml:"batch_size,omitempty"`               // records per request
+	ResponseCheck    string            `json:"response_check,omitempty" yaml:"response_check,omitempty"`
+	RetryWrites      bool              `json:"retry_writes,omitempty" yaml:"retry_writes,omitempty"` // retry sent batches on server and connection errors
+}
+
+// APIPagination are the pagination options of a REST API endpoint
+//   - page: increments the page parameter, starting at start_page
+//   - offset: increments the offset parameter by the page size
+//   - cursor: sets the cursor parameter to the value of the cursor_path
+//     JMESPath expression, evaluated against the previous response
+//   - link: follows the `next` url of the Link header
+//
+// Pages are requested until a page returns fewer records than the page size,
+// no records, or no next cursor or link.
+type APIPagination struct {
+	Type        APIPaginationType `json:"type,omitempty" yaml:"type,omitempty"`
+	PageSize    int               `json:"page_size,omitempty" yaml:"page_size,omitempty"`
+	SizeParam   string            `json:"size_param,omitempty" yaml:"size_param,omitempty"`
+	PageParam   string            `json:"page_param,omitempty" yaml:"page_param,omitempty"`
+	StartPage   *int              `json:"start_page,omitempty" yaml:"start_page,omitempty"`
+	OffsetParam string            `json:"offset_param,omitempty" yaml:"offset_param,omitempty"`
+	CursorPath  string            `json:"cursor_path,omitempty" yaml:"cursor_path,omitempty"`
+	CursorParam string            `json:"cursor_param,omitempty" yaml:"cursor_param,omitempty"`
+	MaxPages    int               `json:"max_pages,omitempty" yaml:"max_pages,omitempty"`
+}
+
+// linkNextRegex matches the next url of a Link header
+var linkNextRegex = regexp.MustCompile(`<([^>]+)>\s*;[^,]*rel="?next"?`)
+
+// APIFileSysClient reads the records of REST API endpoints, as a JSON
+// array of records, and sends records to them in batches. The endpoint urls are `api://<host>/<path>`, which
+// are resolved against the BASE_URL prop.
+type APIFileSysClient struct {
+	BaseFileSysClient
+	client *http.Client
+	auth   APIAuthType
+
+	maxRetries  int
+	interval    time.Duration // min interval between requests
+	lastRequest time.Time
+	mux         sync.Mutex
+}
+
+// Init initializes the fs client
+func (fs *APIFileSysClient) Init(ctx context.Context) (err error) {
+	var instance FileSysClient
+	instance = fs
+	fs.BaseFileSysClient.instance = &instance
+	fs.BaseFileSysClient.context = g.NewContext(ctx)
+
+	if fs.GetProp("FORMAT") == "" {
+		fs.SetProp("FORMAT", string(FileTypeJson))
+	}
+	if fs.GetProp("FLATTEN") == "" {
+		fs.SetProp("FLATTEN", "true") // records are objects
+	}
+
+	return fs.Connect()
+}
+
+// Connect initiates the http client, with the authentication
+func (fs *APIFileSysClient) Connect() (err error) {
+	fs.client = &http.Client{Timeout: 5 * time.Minute}
+
+	fs.maxRetries = 3
+	if val := fs.GetProp("MAX_RETRIES"); val != "" {
+		fs.maxRetries = cast.ToInt(val)
+	}
+	if rate := cast.ToFloat64(fs.GetProp("RATE_LIMIT")); rate > 0 {
+		fs.interval = time.Duration(float64(time.Second) / rate)
+	}
+
+	fs.auth = APIAuthType(strings.ToLower(fs.GetProp("AUTH_TYPE")))
+	if fs.auth == APIAuthTypeNone {
+		// infer from the provided credentials
+		switch {
+		case fs.GetProp("TOKEN") != "":
+			fs.auth = APIAuthTypeBearer
+		case fs.GetProp("API_KEY") != "":
+			fs.auth = APIAuthTypeAPIKey
+		case fs.GetProp("CLIENT_ID") != "":
+			fs.auth = APIAuthTypeOAuth2
+		case fs.GetProp("USERNAME") != "":
+			fs.auth = APIAuthTypeBasic
+		}
+	}
+
+	switch fs.auth {
+	case APIAuthTypeNone, APIAuthTypeBearer, APIAuthTypeBasic, APIAuthTypeAPIKey:
+	case APIAuthTypeOAuth2:
+		if fs.GetProp("TOKEN_URL") == "" {
+			return g.Error("token_url is required for oauth2 authentication")
+		}
+
+		ccConfig := clientcredentials.Config{
+			ClientID:     fs.GetProp("CLIENT_ID"),
+			ClientSecret: fs.GetProp("CLIENT_SECRET"),
+			TokenURL:     fs.GetProp("TOKEN_URL"),
+		}
+		if scopes := fs.GetProp("SCOPES"); scopes != "" {
+			ccConfig.Scopes = strings.Split(scopes, ",")
+		}
+
+		// the client fetches and refreshes the access token
+		fs.client = ccConfig.Client(fs.Context().Ctx)
+		fs.client.Timeout = 5 * time.Minute
+	default:
+		return g.Error("unsupported API auth type: %s", fs.auth)
+	}
+
+	return nil
+}
+
+// options returns the endpoint options from the API prop
+func (fs *APIFileSysClient) options() (opts APIOptions, err error) {
+	if val := fs.GetProp("API"); val != "" && val != "null" {
+		if err = g.Unmarshal(val, &opts); err != nil {
+			return opts, g.Error(err, "could not parse api options")
+		}
+	}
+
+	if opts.Pagination == nil {
+		opts.Pagination = &APIPagination{}
+	}
+
+	p := opts.Pagination
+	p.Type = APIPaginationType(strings.ToLower(string(p.Type)))
+	switch p.Type {
+	case APIPaginationTypeNone, APIPaginationTypeLink:
+	case APIPaginationTypePage:
+		p.PageParam = valueOr(p.PageParam, "page")
+		p.SizeParam = valueOr(p.SizeParam, "per_page")
+		if p.StartPage == nil {
+			p.StartPage = g.Int(1)
+		}
+	case APIPaginationTypeOffset:
+		p.OffsetParam = valueOr(p.OffsetParam, "offset")
+		p.SizeParam = valueOr(p.SizeParam, "limit")
+		if p.PageSize == 0 {
+			return opts, g.Error("page_size is required for offset pagination")
+		}
+	case APIPaginationTypeCursor:
+		p.CursorParam = valueOr(p.CursorParam, "cursor")
+		if p.CursorPath == "" {
+			return opts, g.Error("cursor_path is required for cursor pagination")
+		}
+	default:
+		return opts, g.Error("unsupported API pagination type: %s", p.Type)
+	}
+
+	return opts, nil
+}
+
+// valueOr returns the value, or the default value if empty
+func valueOr(val, defVal string) string {
+	if val == "" {
+		return defVal
+	}
+	return val
+}
+
+// endpointURL resolves the `api://<host>/<path>` url against the base url
+func (fs *APIFileSysClient) endpointURL(urlStr string) (u *url.URL, err error) {
+	u, err = url.Parse(urlStr)
+	if err != nil {
+		return nil, g.Error(err, "could not parse url: %s", urlStr)
+	}
+
+	baseURL := strings.TrimSuffix(fs.GetProp("BASE_URL"), "/")
+	if baseURL == "" {
+		baseURL = "https://" + u.Host
+	}
+
+	endpoint, err := url.Parse(baseURL + "/" + strings.TrimPrefix(u.Path, "/"))
+	if err != nil {
+		return nil, g.Error(err, "could not parse base url: %s", baseURL)
+	}
+	endpoint.Path = strings.TrimSuffix(endpoint.Path, "/")
+	endpoint.RawQuery = u.RawQuery
+
+	return endpoint, nil
+}
+
+// List returns the endpoint url
+func (fs *APIFileSysClient) List(url string) (paths []string, err error) {
+	return []string{url}, nil
+}
+
+// ListRecursive returns the endpoint url
+func (fs *APIFileSysClient) ListRecursive(url string) (paths []string, err error) {
+	return fs.List(url)
+}
+
+// GetReader returns a reader of the records of all the pages
+// of the endpoint, as a JSON array
+func (fs *APIFileSysClient) GetReader(urlStr string) (reader io.Reader, err error) {
+	opts, err := fs.options()
+	if err != nil {
+		return nil, g.Error(err, "invalid api options")
+	}
+
+	endpoint, err := fs.endpointURL(urlStr)
+	if err != nil {
+		return nil, g.Error(err, "invalid endpoint")
+	}
+
+	var records *jmespath.JMESPath
+	if opts.Records != "" {
+		records, err = jmespath.Compile(opts.Records)
+		if err != nil {
+			return nil, g.Error(err, "invalid records expression: %s", opts.Records)
+		}
+	}
+
+	query := endpoint.Query()
+	for k, v := range opts.Params {
+		query.Set(k, v)
+	}
+	if opts.IncrementalParam != "" {
+		if val := fs.GetProp("INCREMENTAL_VALUE"); val != "" {
+			query.Set(opts.IncrementalParam, val)
+		}
+	}
+	endpoint.RawQuery = query.Encode()
+
+	pipeR, pipeW := io.Pipe()
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		err := fs.readPages(endpoint, opts, records, pipeW)
+		pipeW.CloseWithError(err)
+	}()
+
+	// once stopped, close the reader side so that a pending write returns
+	go func() {
+		select {
+		case <-fs.Context().Ctx.Done():
+			pipeR.CloseWithError(g.Error(fs.Context().Ctx.Err(), "stopped reading %s", endpoint.String()))
+		case <-done:
+		}
+	}()
+
+	return pipeR, nil
+}
+
+// readPages requests every page of the endpoint, and writes the
+// records into the writer as a JSON array
+func (fs *APIFileSysClient) readPages(endpoint *url.URL, opts APIOptions, records *jmespath.JMESPath, writer io.Writer) (err error) {
+	p := opts.Pagination
+	page, offset, count := 0, 0, 0
+	if p.StartPage != nil {
+		page = *p.StartPage
+	}
+
+	if _, err = writer.Write([]byte("[")); err != nil {
+		return err
+	}
+
+	nextURL := endpoint.String()
+	for pageNum := 1; nextURL != ""; pageNum++ {
+		if err = fs.Context().Err(); err != nil {
+			return g.Error(err, "stopped reading %s", endpoint.String())
+		}
+
+		reqURL, _ := url.Parse(nextURL)
+		if p.Type != APIPaginationTypeLink || pageNum == 1 {
+			query := reqURL.Query()
+			switch p.Type {
+			case APIPaginationTypePage:
+				query.Set(p.PageParam, cast.ToString(page))
+			case APIPaginationTypeOffset:
+				query.Set(p.OffsetParam, cast.ToString(offset))
+			}
+			if p.PageSize > 0 && p.SizeParam != "" {
+				query.Set(p.SizeParam, cast.ToString(p.PageSize))
+			}
+			reqURL.RawQuery = query.Encode()
+		}
+
+		resp, data, err := fs.doRequest(http.MethodGet, reqURL.String(), opts.Headers, nil, true)
+		if err != nil {
+			return g.Error(err, "could not request %s", endpoint.String())
+		}
+
+		body, err := decodeJSON(data)
+		if err != nil {
+			return g.Error(err, "invalid response from %s", reqURL.String())
+		}
+
+		pageRecords, err := extractRecords(body, records)
+		if err != nil {
+			return g.Error(err, "could not extract records from %s", reqURL.String())
+		}
+		g.Trace("received %d records from page %d of %s", len(pageRecords), pageNum, endpoint.String())
+
+		for _, rec := range pageRecords {
+			prefix := ","
+			if count == 0 {
+				prefix = ""
+			}
+			if _, err = writer.Write([]byte(prefix + g.Marshal(rec))); err != nil {
+				return err
+			}
+			count++
+		}
+
+		// determine the next page
+		nextURL = ""
+		if len(pageRecords) == 0 || (p.MaxPages > 0 && pageNum >= p.MaxPages) {
+			break
+		}
+
+		switch p.Type {
+		case APIPaginationTypePage, APIPaginationTypeOffset:
+			if p.PageSize == 0 || len(pageRecords) >= p.PageSize {
+				page++
+				offset += len(pageRecords)
+				nextURL = endpoint.String()
+			}
+		case APIPaginationTypeCursor:
+			cursor, err := jmespath.Search(p.CursorPath, body)
+			if err != nil {
+				return g.Error(err, "could not evaluate cursor path: %s", p.CursorPath)
+			}
+			if cursorStr := cast.ToString(cursor); cursor != nil && cursorStr != "" {
+				cursorURL := *endpoint
+				query := cursorURL.Query()
+				query.Set(p.CursorParam, cursorStr)
+				cursorURL.RawQuery = query.Encode()
+				nextURL = cursorURL.String()
+			}
+		case APIPaginationTypeLink:
+			if matches := linkNextRegex.FindStringSubmatch(resp.Header.Get("Link")); len(matches) == 2 {
+				linkURL, err := reqURL.Parse(matches[1]) // can be relative
+				if err != nil {
+					return g.Error(err, "invalid next link: %s", matches[1])
+				}
+				nextURL = linkURL.String()
+			}
+		}
+	}
+
+	g.Debug("received %d records from %s", count, endpoint.String())
+
+	_, err = writer.Write([]byte("]"))
+	return err
+}
+
+// extractRecords returns the records of the response body. An object
+// is considered a single record.
+func extractRecords(body any, records *jmespath.JMESPath) (recs []any, err error) {
+	if records != nil {
+		body, err = records.Search(body)
+		if err != nil {
+			return nil, g.Error(err, "could not evaluate records expression")
+		}
+	}
+
+	switch value := body.(type) {
+	case nil:
+		return nil, nil
+	case []any:
+		return value, nil
+	case map[string]any:
+		return []any{value}, nil
+	}
+	return nil, g.Error("records are not an array or an object, but %T", body)
+}
+
+// doRequest sends the request with the authentication and headers, and
+// returns the response body. Rate limited responses (429) and failed
+// connections are retried after the Retry-After delay, or with an
+// exponential backoff. Server errors (5xx) and other connection errors
+// are retried only when retryAll, since the request may have been processed.
+func (fs *APIFileSysClient) doRequest(method, urlStr string, headers map[string]string, payload []byte, retryAll bool) (resp *http.Response, data []byte, err error) {
+	for attempt := 0; ; attempt++ {
+		fs.wait()
+
+		req, err := http.NewRequestWithContext(fs.Context().Ctx, method, urlStr, bytes.NewReader(payload))
+		if err != nil {
+			return nil, nil, g.Error(err, "could not construct request")
+		}
+		fs.setAuth(req)
+		req.Header.Set("Accept", "application/json")
+		if payload != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+
+		g.Trace("%s %s", method, urlStr)
+		resp, err = fs.client.Do(req)
+		if err == nil {
+			data, err = io.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+
+		retryable := isRetryable(resp, err, retryAll)
+		if attempt < fs.maxRetries && fs.Context().Err() == nil && retryable {
+			retryAfter := ""
+			if err != nil {
+				g.Debug("request failed, retrying: %s", err.Error())
+			} else {
+				retryAfter = resp.Header.Get("Retry-After")
+				g.Debug("received status %d, retrying", resp.StatusCode)
+			}
+
+			select {
+			case <-fs.Context().Ctx.Done():
+				return resp, nil, fs.Context().Ctx.Err()
+			case <-time.After(retryDelay(retryAfter, attempt)):
+			}
+			continue
+		} else if err != nil {
+			return resp, nil, g.Error(err, "could not request url")
+		}
+
+		if resp.StatusCode >= 300 || resp.StatusCode < 200 {
+			return resp, data, g.Error("status code error: %s\n%s", resp.Status, truncate(string(data), 500))
+		}
+
+		return resp, data, nil
+	}
+}
+
+// decodeJSON decodes the JSON response body, keeping numbers as is
+func decodeJSON(data []byte) (body any, err error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err = decoder.Decode(&body); err != nil && err != io.EOF {
+		return nil, g.Error(err, "could not decode JSON response")
+	}
+	return body, nil
+}
+
+// isRetryable returns true for rate limited responses and failed
+// connections, where the request was not sent, and with retryAll,
+// for transient server errors and other connection errors
+func isRetryable(resp *http.Response, err error, retryAll bool) bool {
+	if err != nil {
+		var opErr *net.OpError
+		return retryAll || (errors.As(err, &opErr) && opErr.Op == "dial")
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || (retryAll && resp.StatusCode >= 500)
+}
+
+// setAuth sets the authentication of the request
+func (fs *APIFileSysClient) setAuth(req *http.Request) {
+	switch fs.auth {
+	case APIAuthTypeBearer:
+		req.Header.Set("Authorization", "Bearer "+fs.GetProp("TOKEN"))
+	case APIAuthTypeBasic:
+		req.SetBasicAuth(fs.GetProp("USERNAME"), fs.GetProp("PASSWORD"))
+	case APIAuthTypeAPIKey:
+		req.Header.Set(valueOr(fs.GetProp("API_KEY_HEADER"), "X-API-Key"), fs.GetProp("API_KEY"))
+	}
+}
+
+// wait waits for the rate limit interval since the last request
+func (fs *APIFileSysClient) wait() {
+	fs.mux.Lock()
+	defer fs.mux.Unlock()
+
+	if fs.interval > 0 {
+		if elapsed := time.Since(fs.lastRequest); elapsed < fs.interval {
+			time.Sleep(fs.interval - elapsed)
+		}
+	}
+	fs.lastRequest = time.Now()
+}
+
+// retryDelay returns the delay of the Retry-After header (in seconds or
+// as a date), or an exponential backoff of the attempt
+func retryDelay(retryAfter string, attempt int) time.Duration {
+	if retryAfter != "" {
+		if seconds, err := cast.ToIntE(retryAfter); err == nil {
+			return time.Duration(seconds) * time.Second
+		}
+		if t, err := http.ParseTime(retryAfter); err == nil {
+			if delay := time.Until(t); delay > 0 {
+				return delay
+			}
+			return 0
+		}
+	}
+	return time.Duration(math.Pow(2, float64(attempt))) * time.Second
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n] + "..."
+	}
+	return s
+}
+
+// Delete is not supported
+func (fs *APIFileSysClient) delete(path string) (err error) {
+	return g.Error("cannot delete from a REST API")
+}
+
+// Write sends the reader content as one request to the endpoint
+func (fs *APIFileSysClient) Write(urlStr string, reader io.Reader) (bw int64, err error) {
+	opts, err := fs.options()
+	if err != nil {
+		return 0, g.Error(err, "invalid api options")
+	}
+
+	payload, err := io.ReadAll(reader)
+	if err != nil {
+		return 0, g.Error(err, "could not read payload")
+	}
+
+	endpoint, err := fs.endpointURL(urlStr)
+	if err != nil {
+		return 0, g.Error(err, "invalid endpoint")
+	}
+
+	err = fs.send(endpoint.String(), opts, nil, payload)
+	if err != nil {
+		return 0, g.Error(err, "could not send to %s", endpoint.String())
+	}
+
+	return int64(len(payload)), nil
+}
+
+// WriteDataflow sends the records of the dataflow to the endpoint, as
+// JSON arrays of records. The batches are sent concurrently, up to the
+// CONCURRENCY prop.
+func (fs *APIFileSysClient) WriteDataflow(df *iop.Dataflow, urlStr string) (bw int64, err error) {
+	opts, err := fs.options()
+	if err != nil {
+		return 0, g.Error(err, "invalid api options")
+	}
+
+	endpoint, err := fs.endpointURL(urlStr)
+	if err != nil {
+		return 0, g.Error(err, "invalid endpoint")
+	}
+	query := endpoint.Query()
+	for k, v := range opts.Params {
+		query.Set(k, v)
+	}
+	endpoint.RawQuery = query.Encode()
+
+	var check *jmespath.JMESPath
+	if opts.ResponseCheck != "" {
+		check, err = jmespath.Compile(opts.ResponseCheck)
+		if err != nil {
+			return 0, g.Error(err, "invalid response check expression: %s", opts.ResponseCheck)
+		}
+	}
+
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+
+	concurrency := cast.ToInt(fs.GetProp("CONCURRENCY"))
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+
+	g.Trace("sending dataflow to %s", endpoint.String())
+	for ds := range df.StreamCh {
+		for batchR := range ds.NewJsonReaderChnl(batchSize, 0) {
+			if df.Err() != nil {
+				if reader, ok := batchR.Reader.(*io.PipeReader); ok {
+					reader.CloseWithError(df.Err()) // stops the stream
+				}
+				continue
+			}
+
+			payload, err := io.ReadAll(batchR.Reader)
+			if err != nil {
+				df.Context.CaptureErr(g.Error(err, "could not read batch"))
+				continue
+			} else if string(payload) == "[]" {
+				continue // empty last batch
+			}
+
+			sem <- struct{}{}
+			wg.Add(1)
+			go func(payload []byte) {
+				defer func() { <-sem; wg.Done() }()
+
+				if err := fs.send(endpoint.String(), opts, check, payload); err != nil {
+					df.Context.CaptureErr(g.Error(err, "could not send batch to %s", endpoint.String()))
+					return
+				}
+
+				atomic.AddInt64(&bw, int64(len(payload)))
+			}(payload)
+		}
+	}
+
+	wg.Wait()
+
+	if err = df.Err(); err != nil {
+		return bw, g.Error(err, "could not write dataflow")
+	}
+
+	return bw, nil
+}
+
+// send sends the payload with the write method, and checks the response
+func (fs *APIFileSysClient) send(urlStr string, opts APIOptions, check *jmespath.JMESPath, payload []byte) (err error) {
+	method := strings.ToUpper(valueOr(opts.Method, http.MethodPost))
+
+	_, data, err := fs.doRequest(method, urlStr, opts.Headers, payload, opts.RetryWrites)
+	if err != nil {
+		return g.Error(err, "request failed")
+	}
+
+	if check != nil {
+		body, err := decodeJSON(data)
+		if err != nil {
+			return g.Error(err, "could not check response")
+		}
+
+		result, err := check.Search(body)
+		if err != nil {
+			return g.Error(err, "could not evaluate response check")
+		} else if !isTruthy(result) {
+			return g.Error("response check failed (%s): %s", opts.ResponseCheck, truncate(string(data), 500))
+		}
+	}
+
+	return nil
+}
+
+// isTruthy returns true if the JMESPath result is not false, null or empty
+func isTruthy(value any) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	case []any:
+		return len(v) > 0
+	case map[string]any:
+		return len(v) > 0
+	}
+	return true
+}
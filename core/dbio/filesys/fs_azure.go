@@ -1,6 +1,7 @@
 package filesys
 
 import (
+	"bytes"
 	"context"
 	"io"
 	"net/url"
@@ -460,6 +461,43 @@ func (fs *AzureFileSysClient) Write(urlStr string, reader io.Reader) (bw int64,
 	return
 }
 
+// writeIfNotExists uploads the blob with an `If-None-Match: *` condition,
+// created is false if it exists
+func (fs *AzureFileSysClient) writeIfNotExists(urlStr string, data []byte) (created bool, err error) {
+	host, path, err := ParseURL(urlStr)
+	if err != nil || host == "" {
+		err = g.Error(err, "Error Parsing url: "+urlStr)
+		return
+	}
+
+	pathArr := strings.Split(cleanKeyAzure(path), "/")
+	if len(pathArr) < 2 {
+		err = g.Error("Invalid Azure path (need blob URL): " + urlStr)
+		return
+	}
+
+	container := fs.client.GetBlobService().GetContainerReference(pathArr[0])
+	containerURL, err := fs.getAuthContainerURL(container)
+	if err != nil {
+		err = g.Error(err, "Unable to getAuthContainerURL: "+container.GetURL())
+		return
+	}
+
+	blockBlobURL := containerURL.NewBlockBlobURL(strings.Join(pathArr[1:], "/"))
+	_, err = blockBlobURL.Upload(
+		fs.Context().Ctx, bytes.NewReader(data), azblob.BlobHTTPHeaders{}, azblob.Metadata{},
+		azblob.BlobAccessConditions{ModifiedAccessConditions: azblob.ModifiedAccessConditions{IfNoneMatch: azblob.ETagAny}},
+		azblob.DefaultAccessTier, nil, azblob.ClientProvidedKeyOptions{}, azblob.ImmutabilityPolicyOptions{},
+	)
+	if err != nil {
+		if serr, ok := err.(azblob.StorageError); ok && g.In(serr.ServiceCode(), azblob.ServiceCodeBlobAlreadyExists, azblob.ServiceCodeConditionNotMet) {
+			return false, nil
+		}
+		return false, g.Error(err, "Error Upload: "+blockBlobURL.String())
+	}
+	return true, nil
+}
+
 // GetReader returns an Azure FS reader
 func (fs *AzureFileSysClient) GetReader(urlStr string) (reader io.Reader, err error) {
 	host, path, err := ParseURL(urlStr)
@@ -0,0 +1,98 @@
+package filesys
+
+import (
+	"context"
+	"io"
+
+	"github.com/flarco/g"
+	"github.com/slingdata-io/sling-cli/core/dbio/iop"
+)
+
+// GenerateFileSysClient produces synthetic rows, following the column
+// specification of the GENERATE prop. The stream urls are
+// `generate://<stream>`.
+type GenerateFileSysClient struct {
+	BaseFileSysClient
+}
+
+// Init initializes the fs client
+func (fs *GenerateFileSysClient) Init(ctx context.Context) (err error) {
+	var instance FileSysClient
+	instance = fs
+	fs.BaseFileSysClient.instance = &instance
+	fs.BaseFileSysClient.context = g.NewContext(ctx)
+	return nil
+}
+
+// options returns the generate options of the props
+func (fs *GenerateFileSysClient) options() (opts iop.GenerateOptions, err error) {
+	val := fs.GetProp("GENERATE")
+	if val == "" || val == "null" {
+		return opts, g.Error("generate options are required for a generate source")
+	}
+
+	if err = g.Unmarshal(val, &opts); err != nil {
+		return opts, g.Error(err, "could not parse generate options")
+	}
+	return opts, nil
+}
+
+// List returns the stream url
+func (fs *GenerateFileSysClient) List(url string) (paths []string, err error) {
+	return []string{url}, nil
+}
+
+// ListRecursive returns the stream url
+func (fs *GenerateFileSysClient) ListRecursive(url string) (paths []string, err error) {
+	return fs.List(url)
+}
+
+// ReadDataflow generates the rows of the stream
+func (fs *GenerateFileSysClient) ReadDataflow(url string, cfg ...FileStreamConfig) (df *iop.Dataflow, err error) {
+	Cfg := FileStreamConfig{} // infinite
+	if len(cfg) > 0 {
+		Cfg = cfg[0]
+	}
+
+	opts, err := fs.options()
+	if err != nil {
+		return nil, err
+	} else if Cfg.Limit > 0 && int64(Cfg.Limit) < opts.Rows {
+		opts.Rows = int64(Cfg.Limit)
+	}
+
+	ds, err := iop.NewGenerateDatastream(fs.Context().Ctx, opts)
+	if err != nil {
+		return nil, g.Error(err, "could not generate stream %s", url)
+	}
+	ds.SetConfig(fs.Props()) // pass options
+	ds.Metadata.StreamURL.Value = url
+
+	if err = ds.Start(); err != nil {
+		return nil, g.Error(err, "could not start generating stream %s", url)
+	}
+
+	df, err = iop.MakeDataFlow(ds)
+	if err != nil {
+		return nil, g.Error(err, "could not make dataflow")
+	}
+	df.FsURL = url
+	fs.setDf(df)
+
+	return df, nil
+}
+
+// GetReader is not supported, the rows are generated with ReadDataflow
+func (fs *GenerateFileSysClient) GetReader(path string) (reader io.Reader, err error) {
+	return nil, g.Error("cannot get a reader of a generate stream")
+}
+
+// Write is not supported
+func (fs *GenerateFileSysClient) Write(path string, reader io.Reader) (bw int64, err error) {
+	return 0, g.Error("cannot write to a generate connection")
+}
+
+// delete is not supported
+func (fs *GenerateFileSysClient) delete(path string) (err error) {
+	return g.Error("cannot delete from a generate connection")
+}
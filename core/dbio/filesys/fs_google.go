@@ -2,7 +2,9 @@ package filesys
 
 import (
 	"context"
+	"errors"
 	"io"
+	"net/http"
 	"os"
 	"strings"
 
@@ -10,6 +12,7 @@ import (
 	"github.com/flarco/g"
 	"github.com/spf13/cast"
 	"golang.org/x/oauth2/google"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 )
@@ -119,6 +122,33 @@ func (fs *GoogleFileSysClient) Write(path string, reader io.Reader) (bw int64, e
 	return
 }
 
+// writeIfNotExists writes the object with a does-not-exist precondition,
+// created is false if it exists
+func (fs *GoogleFileSysClient) writeIfNotExists(path string, data []byte) (created bool, err error) {
+	bucket, key, err := ParseURL(path)
+	if err != nil || bucket == "" {
+		err = g.Error(err, "Error Parsing url: "+path)
+		return
+	}
+	key = cleanKeyGoogle(key)
+
+	obj := fs.client.Bucket(bucket).Object(key).If(gcstorage.Conditions{DoesNotExist: true})
+	wc := obj.NewWriter(fs.Context().Ctx)
+	if _, err = wc.Write(data); err != nil {
+		wc.Close()
+		return false, g.Error(err, "Error Writing")
+	}
+
+	if err = wc.Close(); err != nil {
+		var gerr *googleapi.Error
+		if errors.As(err, &gerr) && gerr.Code == http.StatusPreconditionFailed {
+			return false, nil
+		}
+		return false, g.Error(err, "Error Closing writer")
+	}
+	return true, nil
+}
+
 // GetReader returns the reader for the given path
 func (fs *GoogleFileSysClient) GetReader(path string) (reader io.Reader, err error) {
 	bucket, key, err := ParseURL(path)
@@ -3,6 +3,7 @@ package filesys
 import (
 	"bufio"
 	"context"
+	"errors"
 	"io"
 	"io/ioutil"
 	"os"
@@ -79,6 +80,11 @@ func (fs *LocalFileSysClient) GetReader(path string) (reader io.Reader, err erro
 
 // GetDatastream return a datastream for the given path
 func (fs *LocalFileSysClient) GetDatastream(path string) (ds *iop.Datastream, err error) {
+	if fs.GetProp("ENCRYPTION") != "" {
+		// encrypted files cannot be read with seekers
+		return fs.BaseFileSysClient.GetDatastream(path)
+	}
+
 	path = cleanLocalFilePath(path)
 	file, err := os.Open(path)
 	if err != nil {
@@ -127,23 +133,28 @@ func (fs *LocalFileSysClient) GetDatastream(path string) (ds *iop.Datastream, er
 		fs.Context().Wg.Read.Add()
 
 		g.Debug("reading datastream from %s [format=%s]", path, fileFormat)
+		reader := decompressBrotli(fs.Self(), path, bufio.NewReader(file))
 
 		switch fileFormat {
 		case FileTypeJson, FileTypeJsonLines:
-			err = ds.ConsumeJsonReader(bufio.NewReader(file))
+			err = ds.ConsumeJsonReader(reader)
 		case FileTypeXml:
-			err = ds.ConsumeXmlReader(bufio.NewReader(file))
+			err = ds.ConsumeXmlReader(reader)
 		case FileTypeParquet:
 			err = ds.ConsumeParquetReaderSeeker(file)
 		case FileTypeAvro:
 			err = ds.ConsumeAvroReaderSeeker(file)
 		case FileTypeSAS:
 			err = ds.ConsumeSASReaderSeeker(file)
+		case FileTypeArrow, FileTypeFeather:
+			err = ds.ConsumeArrowReaderSeeker(file)
+		case FileTypeFixedWidth:
+			err = ds.ConsumeFixedWidthReader(reader)
 		case FileTypeCsv:
-			err = ds.ConsumeCsvReader(bufio.NewReader(file))
+			err = ds.ConsumeCsvReader(reader)
 		default:
 			g.Warn("LocalFileSysClient | File Format not recognized: %s. Using CSV parsing", fileFormat)
-			err = ds.ConsumeCsvReader(bufio.NewReader(file))
+			err = ds.ConsumeCsvReader(reader)
 		}
 
 		if err != nil {
@@ -206,6 +217,37 @@ func (fs *LocalFileSysClient) Write(filePath string, reader io.Reader) (bw int64
 	return
 }
 
+// writeIfNotExists writes a temporary file and links it to the path, which
+// fails if the path exists. Readers never see a partial file.
+func (fs *LocalFileSysClient) writeIfNotExists(filePath string, data []byte) (created bool, err error) {
+	filePath = cleanLocalFilePath(filePath)
+	if err = os.MkdirAll(path.Dir(filePath), 0777); err != nil {
+		return false, g.Error(err, "Unable to create folder "+path.Dir(filePath))
+	}
+
+	tmpFile, err := os.CreateTemp(path.Dir(filePath), "."+path.Base(filePath)+".*.tmp")
+	if err != nil {
+		return false, g.Error(err, "Unable to create temp file for "+filePath)
+	}
+	defer os.Remove(tmpFile.Name())
+
+	_, err = tmpFile.Write(data)
+	if cErr := tmpFile.Close(); err == nil {
+		err = cErr
+	}
+	if err != nil {
+		return false, g.Error(err, "Error writing to "+tmpFile.Name())
+	}
+
+	err = os.Link(tmpFile.Name(), filePath)
+	if errors.Is(err, os.ErrExist) {
+		return false, nil
+	} else if err != nil {
+		return false, g.Error(err, "Unable to create "+filePath)
+	}
+	return true, nil
+}
+
 // List lists the file in given directory path
 func (fs *LocalFileSysClient) List(path string) (paths []string, err error) {
 	path = cleanLocalFilePath(path)
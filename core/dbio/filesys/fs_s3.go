@@ -1,6 +1,7 @@
 package filesys
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
@@ -347,6 +348,35 @@ func (fs *S3FileSysClient) Write(path string, reader io.Reader) (bw int64, err e
 	return
 }
 
+// writeIfNotExists puts the object with an `If-None-Match: *` condition,
+// created is false if the key exists
+func (fs *S3FileSysClient) writeIfNotExists(path string, data []byte) (created bool, err error) {
+	bucket, key, err := ParseURL(path)
+	if err != nil || bucket == "" {
+		err = g.Error(err, "Error Parsing url: "+path)
+		return
+	}
+	fs.bucket = bucket
+	key = cleanKeyS3(key)
+
+	svc := s3.New(fs.getSession())
+	req, _ := svc.PutObjectRequest(&s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(data),
+	})
+	req.SetContext(fs.Context().Ctx)
+	req.HTTPRequest.Header.Set("If-None-Match", "*")
+
+	if err = req.Send(); err != nil {
+		if rerr, ok := err.(awserr.RequestFailure); ok && g.In(rerr.StatusCode(), 409, 412) {
+			return false, nil // exists, or a concurrent write of the key
+		}
+		return false, g.Error(err, "failed to upload file: "+key)
+	}
+	return true, nil
+}
+
 // Buckets returns the buckets found in the account
 func (fs *S3FileSysClient) Buckets() (paths []string, err error) {
 	// Create S3 service client
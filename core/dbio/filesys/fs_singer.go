@@ -0,0 +1,312 @@
+package filesys
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/flarco/g"
+	"github.com/slingdata-io/sling-cli/core/dbio/iop"
+)
+
+// SingerFileSysClient runs executables speaking the Singer protocol. As a
+// source, the COMMAND prop is a tap, and the records of the stream are read
+// from its output. As a target, the COMMAND prop is a target, and the
+// records are piped into its input. The stream urls are
+// `singer://<name>/<stream>`. The tap runs once per stream read, so with a
+// state, the tap must only emit that stream (such as with a catalog
+// selecting it), since its state covers all the emitted streams. The state
+// file of the connection is kept per stream, see SingerStatePath.
+type SingerFileSysClient struct {
+	BaseFileSysClient
+}
+
+// SingerOptions are the options of a singer source stream, which
+// take precedence over the CATALOG and STATE props of the connection
+type SingerOptions struct {
+	Catalog string `json:"catalog,omitempty" yaml:"catalog,omitempty"` // catalog selecting the stream
+	State   string `json:"state,omitempty" yaml:"state,omitempty"`     // state file of the stream
+}
+
+// Init initializes the fs client
+func (fs *SingerFileSysClient) Init(ctx context.Context) (err error) {
+	var instance FileSysClient
+	instance = fs
+	fs.BaseFileSysClient.instance = &instance
+	fs.BaseFileSysClient.context = g.NewContext(ctx)
+
+	if fs.GetProp("COMMAND") == "" {
+		return g.Error("command is required for a singer connection")
+	}
+
+	return nil
+}
+
+// options returns the catalog and state of the stream, from the
+// SINGER prop or else from the connection
+func (fs *SingerFileSysClient) options(stream string) (opts SingerOptions, err error) {
+	if val := fs.GetProp("SINGER"); val != "" && val != "null" {
+		if err = g.Unmarshal(val, &opts); err != nil {
+			return opts, g.Error(err, "could not parse singer options")
+		}
+	}
+
+	if opts.Catalog == "" {
+		opts.Catalog = fs.GetProp("CATALOG")
+	}
+	if opts.State == "" {
+		opts.State = SingerStatePath(fs.GetProp("STATE"), stream)
+	}
+
+	return opts, nil
+}
+
+// command returns the command of the executable, with the config
+// argument, and the catalog and state arguments of a tap
+func (fs *SingerFileSysClient) command(ctx context.Context, tapOpts *SingerOptions) *exec.Cmd {
+	args := strings.Fields(fs.GetProp("COMMAND"))
+	if config := fs.GetProp("CONFIG"); config != "" {
+		args = append(args, "--config", config)
+	}
+
+	if tapOpts != nil {
+		if tapOpts.Catalog != "" {
+			args = append(args, "--catalog", tapOpts.Catalog)
+		}
+		if tapOpts.State != "" && g.PathExists(tapOpts.State) {
+			args = append(args, "--state", tapOpts.State)
+		}
+	}
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Env = os.Environ()
+	return cmd
+}
+
+// SingerStatePath returns the state file of the stream, for the STATE of
+// the connection: `state.json` becomes `state.<stream>.json`. Each run of
+// the tap emits the state of its stream only, so the streams of a
+// connection cannot share a state file.
+func SingerStatePath(state, stream string) string {
+	if state == "" {
+		return ""
+	}
+	ext := filepath.Ext(state)
+	return strings.TrimSuffix(state, ext) + "." + stream + ext
+}
+
+// streamName returns the singer stream name of the url
+func streamName(urlStr string) (name string, err error) {
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return "", g.Error(err, "could not parse url: %s", urlStr)
+	}
+
+	name = strings.Trim(u.Path, "/")
+	if name == "" {
+		return "", g.Error("singer stream name is missing from url: %s", urlStr)
+	}
+	return name, nil
+}
+
+// List returns the stream url
+func (fs *SingerFileSysClient) List(url string) (paths []string, err error) {
+	return []string{url}, nil
+}
+
+// ListRecursive returns the stream url
+func (fs *SingerFileSysClient) ListRecursive(url string) (paths []string, err error) {
+	return fs.List(url)
+}
+
+// ReadDataflow runs the tap, and reads the records of the stream. The latest
+// state and its file are set in the dataflow, once the tap has exited successfully.
+func (fs *SingerFileSysClient) ReadDataflow(urlStr string, cfg ...FileStreamConfig) (df *iop.Dataflow, err error) {
+	Cfg := FileStreamConfig{} // infinite
+	if len(cfg) > 0 {
+		Cfg = cfg[0]
+	}
+
+	stream, err := streamName(urlStr)
+	if err != nil {
+		return nil, err
+	}
+
+	df = iop.NewDataflow(Cfg.Limit)
+	ctx := g.NewContext(fs.Context().Ctx)
+	df.Context = &ctx
+	df.FsURL = urlStr
+	fs.setDf(df)
+
+	opts, err := fs.options(stream)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := fs.command(df.Context.Ctx, &opts)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, g.Error(err, "could not get stdout of tap")
+	}
+	stderr := &tailBuffer{limit: 4096}
+	cmd.Stderr = stderr
+
+	g.Debug("running singer tap: %s", cmd.String())
+	if err = cmd.Start(); err != nil {
+		return nil, g.Error(err, "could not start tap: %s", fs.GetProp("COMMAND"))
+	}
+
+	parser := iop.NewSingerParser(df.Context.Ctx, stream)
+	parser.Config = fs.Props()             // pass options
+	parser.SelectedOnly = opts.State != "" // the state covers all the streams
+	dsCh := make(chan *iop.Datastream)
+
+	go func() {
+		defer close(dsCh)
+
+		err := parser.Parse(stdout, dsCh)
+		if err != nil {
+			df.Context.CaptureErr(g.Error(err, "could not parse output of tap"))
+			io.Copy(io.Discard, stdout) // let the tap exit
+		}
+
+		if err := cmd.Wait(); err != nil {
+			df.Context.CaptureErr(g.Error(err, "tap failed: %s", stderr.String()))
+			return
+		} else if len(parser.Streams()) == 0 {
+			df.Context.CaptureErr(g.Error("singer stream %s was not found in the output of the tap", stream))
+			return
+		}
+
+		df.SingerState, df.SingerStatePath = parser.State, opts.State
+	}()
+
+	go df.PushStreamChan(dsCh)
+
+	// wait for first ds to start streaming.
+	// columns need to be populated
+	if err = df.WaitReady(); err != nil {
+		return df, g.Error(err, "could not read singer stream %s", stream)
+	}
+
+	return df, nil
+}
+
+// GetReader is not supported, the records are read with ReadDataflow
+func (fs *SingerFileSysClient) GetReader(path string) (reader io.Reader, err error) {
+	return nil, g.Error("cannot get a reader of a singer tap")
+}
+
+// WriteDataflow pipes the records of the dataflow into the
+// target, as Singer messages of the stream
+func (fs *SingerFileSysClient) WriteDataflow(df *iop.Dataflow, urlStr string) (bw int64, err error) {
+	stream, err := streamName(urlStr)
+	if err != nil {
+		return 0, err
+	}
+
+	cmd := fs.command(fs.Context().Ctx, nil)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return 0, g.Error(err, "could not get stdin of target")
+	}
+	stdout := &tailBuffer{limit: 4096} // the target emits states
+	stderr := &tailBuffer{limit: 4096}
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+
+	g.Debug("running singer target: %s", cmd.String())
+	if err = cmd.Start(); err != nil {
+		return 0, g.Error(err, "could not start target: %s", fs.GetProp("COMMAND"))
+	}
+
+	for ds := range df.StreamCh {
+		n, err := io.Copy(stdin, ds.NewSingerReader(stream))
+		bw += n
+		if err != nil {
+			df.Context.CaptureErr(g.Error(err, "could not write to target"))
+			break
+		}
+	}
+	stdin.Close()
+
+	if err = cmd.Wait(); err != nil {
+		return bw, g.Error(err, "target failed: %s", stderr.String())
+	} else if err = df.Err(); err != nil {
+		return bw, g.Error(err, "could not write dataflow")
+	}
+
+	if state := lastLine(stdout.String()); state != "" {
+		g.Debug("singer target state: %s", state)
+	}
+
+	return bw, nil
+}
+
+// Write is not supported, the records are written with WriteDataflow
+func (fs *SingerFileSysClient) Write(path string, reader io.Reader) (bw int64, err error) {
+	return 0, g.Error("cannot write a file to a singer target")
+}
+
+// delete is not supported
+func (fs *SingerFileSysClient) delete(path string) (err error) {
+	return g.Error("cannot delete from a singer connection")
+}
+
+// WriteSingerState writes the state into the state file
+func WriteSingerState(path, state string) (err error) {
+	if path == "" || state == "" {
+		return nil
+	}
+
+	tmpPath := path + ".tmp"
+	if err = os.WriteFile(tmpPath, []byte(state), 0644); err != nil {
+		return g.Error(err, "could not write singer state")
+	}
+	if err = os.Rename(tmpPath, path); err != nil {
+		return g.Error(err, "could not write singer state")
+	}
+	return nil
+}
+
+// tailBuffer keeps the last bytes written, such as the error
+// messages of an executable
+type tailBuffer struct {
+	buf   bytes.Buffer
+	limit int
+	mux   sync.Mutex
+}
+
+func (tb *tailBuffer) Write(p []byte) (n int, err error) {
+	tb.mux.Lock()
+	defer tb.mux.Unlock()
+
+	tb.buf.Write(p)
+	if extra := tb.buf.Len() - tb.limit; extra > 0 {
+		tb.buf.Next(extra)
+	}
+	return len(p), nil
+}
+
+func (tb *tailBuffer) String() string {
+	tb.mux.Lock()
+	defer tb.mux.Unlock()
+	return strings.TrimSpace(tb.buf.String())
+}
+
+// lastLine returns the last non-empty line of the text
+func lastLine(text string) (line string) {
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		if l := strings.TrimSpace(scanner.Text()); l != "" {
+			line = l
+		}
+	}
+	return line
+}
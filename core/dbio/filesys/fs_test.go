@@ -1,19 +1,38 @@
 package filesys
 
 import (
+	"archive/tar"
+	"archive/zip"
 	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/json"
 	"fmt"
 	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
 	arrowParquet "github.com/apache/arrow/go/v16/parquet"
 	"github.com/apache/arrow/go/v16/parquet/compress"
 	"github.com/flarco/g/net"
 	"github.com/linkedin/goavro/v2"
 	"github.com/parquet-go/parquet-go"
+	"github.com/samber/lo"
 	"github.com/slingdata-io/sling-cli/core/dbio"
 	"github.com/spf13/cast"
 
@@ -135,7 +154,7 @@ func TestFileSysLocalFormat(t *testing.T) {
 		"10:00:00", // timez_type
 	})
 
-	for _, format := range []FileType{FileTypeJson, FileTypeJsonLines, FileTypeCsv, FileTypeParquet} {
+	for _, format := range []FileType{FileTypeJson, FileTypeJsonLines, FileTypeCsv, FileTypeArrow, FileTypeFeather, FileTypeParquet} {
 		if t.Failed() {
 			break
 		}
@@ -230,6 +249,964 @@ func TestFileSysLocalJson(t *testing.T) {
 
 }
 
+func TestFileSysLocalXml(t *testing.T) {
+	t.Parallel()
+	fs, err := NewFileSysClient(dbio.TypeFileLocal)
+	assert.NoError(t, err)
+
+	df1, err := fs.ReadDataflow("test/test1/csv/test1.csv")
+	assert.NoError(t, err)
+
+	data1, err := df1.Collect()
+	assert.NoError(t, err)
+
+	// single file
+	fs2, err := NewFileSysClient(dbio.TypeFileLocal, "XML_ROOT=records", "XML_ROW=record", "XML_ATTRIBUTES=id")
+	assert.NoError(t, err)
+
+	df2, err := iop.MakeDataFlow(data1.Stream())
+	assert.NoError(t, err)
+
+	_, err = fs2.WriteDataflow(df2, "test/test_write_xml/test1.xml")
+	assert.NoError(t, err)
+
+	bytes, err := os.ReadFile("test/test_write_xml/test1.xml")
+	assert.NoError(t, err)
+	content := string(bytes)
+	assert.True(t, strings.HasPrefix(content, "<?xml"))
+	assert.Contains(t, content, "<records>")
+	assert.Contains(t, content, `<record id="1">`)
+	assert.Len(t, regexp.MustCompile(`<record[ />]`).FindAllString(content, -1), len(data1.Rows))
+
+	// folder
+	fs3, err := NewFileSysClient(dbio.TypeFileLocal, "FORMAT=xml", "FILE_MAX_ROWS=400")
+	assert.NoError(t, err)
+
+	df3, err := iop.MakeDataFlow(data1.Stream())
+	assert.NoError(t, err)
+
+	_, err = fs3.WriteDataflow(df3, "test/test_write_xml/folder")
+	assert.NoError(t, err)
+
+	paths, err := fs3.ListRecursive("test/test_write_xml/folder")
+	assert.NoError(t, err)
+	assert.Len(t, paths, cast.ToInt(math.Ceil(float64(len(data1.Rows))/400)))
+
+	if !t.Failed() {
+		os.RemoveAll("test/test_write_xml")
+	}
+}
+
+func TestFileSysLocalFixedWidth(t *testing.T) {
+	t.Parallel()
+	fs, err := NewFileSysClient(dbio.TypeFileLocal)
+	assert.NoError(t, err)
+
+	df1, err := fs.ReadDataflow("test/test1/csv/test1.csv")
+	assert.NoError(t, err)
+
+	data1, err := df1.Collect()
+	assert.NoError(t, err)
+
+	layout := `[{"name":"id","width":6},{"name":"first_name","width":12},{"name":"last_name","width":20},{"name":"email","width":40},{"name":"target","width":6},{"name":"create_dt","width":27},{"name":"rating","width":10}]`
+	fs2, err := NewFileSysClient(dbio.TypeFileLocal, "FORMAT=fixed_width", "LAYOUT="+layout)
+	assert.NoError(t, err)
+
+	df2, err := iop.MakeDataFlow(data1.Stream())
+	assert.NoError(t, err)
+
+	_, err = fs2.WriteDataflow(df2, "test/test_write_fixed_width/test1.txt")
+	assert.NoError(t, err)
+
+	bytes, err := os.ReadFile("test/test_write_fixed_width/test1.txt")
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSuffix(string(bytes), "\n"), "\n")
+	assert.Len(t, lines, len(data1.Rows)+1) // with header
+	for _, line := range lines {
+		if !assert.Equal(t, 121, len([]rune(line))) {
+			break
+		}
+	}
+
+	df3, err := fs2.ReadDataflow("test/test_write_fixed_width/test1.txt")
+	assert.NoError(t, err)
+
+	data3, err := df3.Collect()
+	assert.NoError(t, err)
+	assert.Equal(t, data1.Columns.Names(), data3.Columns.Names())
+	if assert.Len(t, data3.Rows, len(data1.Rows)) {
+		assert.EqualValues(t, data1.Rows[0][3], data3.Rows[0][3])
+		assert.EqualValues(t, cast.ToInt(data1.Rows[10][0]), cast.ToInt(data3.Rows[10][0]))
+	}
+
+	if !t.Failed() {
+		os.RemoveAll("test/test_write_fixed_width")
+	}
+}
+
+func TestFileSysLocalDelta(t *testing.T) {
+	t.Parallel()
+	tableURL := "test/test_write_delta/table"
+	os.RemoveAll(tableURL)
+
+	fs, err := NewFileSysClient(dbio.TypeFileLocal, "FORMAT=delta", "DELTA_MODE=append")
+	assert.NoError(t, err)
+
+	countRows := func(table *deltaTable) (count int) {
+		for p := range table.Files {
+			df, err := NewFileSysClient(dbio.TypeFileLocal)
+			assert.NoError(t, err)
+			dfp, err := df.ReadDataflow(tableURL + "/" + p)
+			if !assert.NoError(t, err) {
+				return
+			}
+			data, err := dfp.Collect()
+			assert.NoError(t, err)
+			count = count + len(data.Rows)
+		}
+		return
+	}
+
+	writeCsv := func(mode string) {
+		fs, err := NewFileSysClient(dbio.TypeFileLocal)
+		assert.NoError(t, err)
+
+		df, err := fs.ReadDataflow("test/test1/csv/test1.csv")
+		assert.NoError(t, err)
+
+		fs2, err := NewFileSysClient(dbio.TypeFileLocal, "FORMAT=delta", "DELTA_MODE="+mode)
+		assert.NoError(t, err)
+
+		_, err = fs2.WriteDataflow(df, tableURL)
+		assert.NoError(t, err)
+	}
+
+	// create table
+	writeCsv("overwrite")
+	table, err := readDeltaTable(fs, tableURL)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.EqualValues(t, 0, table.Version)
+	assert.Equal(t, &deltaProtocol{MinReaderVersion: 1, MinWriterVersion: 2}, table.Protocol)
+	assert.Equal(t, 1000, countRows(table))
+
+	fields, err := table.Fields()
+	assert.NoError(t, err)
+	if assert.Len(t, fields, 7) {
+		assert.Equal(t, "id", fields[0].Name)
+		assert.Equal(t, "long", fields[0].Type)
+		assert.Equal(t, "boolean", fields[4].Type)
+		assert.Equal(t, "decimal(28,9)", fields[6].Type)
+	}
+	tableID := table.MetaData.ID
+
+	// append with a new column
+	columns := iop.NewColumns(iop.Columns{
+		{Name: "id", Type: iop.BigIntType},
+		{Name: "first_name", Type: iop.StringType},
+		{Name: "category", Type: iop.StringType},
+		{Name: "updated_at", Type: iop.TimestampType},
+	}...)
+	data := iop.NewDataset(columns)
+	data.Inferred = true
+	data.Append([]any{int64(2001), "Fred", "new", time.Now()})
+	data.Append([]any{int64(2002), "Wilma", nil, nil})
+
+	df, err := iop.MakeDataFlow(data.Stream())
+	assert.NoError(t, err)
+
+	_, err = fs.WriteDataflow(df, tableURL)
+	assert.NoError(t, err)
+
+	table, err = readDeltaTable(fs, tableURL)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, table.Version)
+	assert.Equal(t, tableID, table.MetaData.ID)
+	assert.Equal(t, 1002, countRows(table))
+	fields, _ = table.Fields()
+	if assert.Len(t, fields, 9) {
+		assert.Equal(t, "category", fields[7].Name)
+		assert.Equal(t, "timestamp", fields[8].Type)
+	}
+
+	// append with a conflicting type fails, without a commit
+	data = iop.NewDataset(iop.NewColumns(iop.Column{Name: "id", Type: iop.StringType}))
+	data.Inferred = true
+	data.Append([]any{"abc"})
+	df, err = iop.MakeDataFlow(data.Stream())
+	assert.NoError(t, err)
+	_, err = fs.WriteDataflow(df, tableURL)
+	assert.Error(t, err)
+
+	table, err = readDeltaTable(fs, tableURL)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, table.Version)
+
+	// overwrite removes prior files
+	writeCsv("overwrite")
+	table, err = readDeltaTable(fs, tableURL)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, table.Version)
+	assert.Equal(t, 1000, countRows(table))
+	fields, _ = table.Fields()
+	assert.Len(t, fields, 7)
+
+	content, err := os.ReadFile(tableURL + "/_delta_log/00000000000000000002.json")
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), `"remove":`)
+	assert.Contains(t, string(content), `numRecords\":1000`)
+
+	// a commit based on a stale version is retried with the next version
+	stale := &deltaTable{URL: tableURL, Version: 1, Files: map[string]deltaAdd{}}
+	err = commitDeltaTable(fs, stale, "append", nil, iop.NewColumns(iop.Column{Name: "id", Type: iop.BigIntType}))
+	assert.NoError(t, err)
+	table, err = readDeltaTable(fs, tableURL)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, table.Version)
+	assert.Equal(t, 1000, countRows(table))
+
+	// concurrent commits each get their own version
+	var commitWg sync.WaitGroup
+	commitErrs := make([]error, 4)
+	for i := range commitErrs {
+		commitWg.Add(1)
+		go func(i int) {
+			defer commitWg.Done()
+			stale := &deltaTable{URL: tableURL, Version: 3, Files: map[string]deltaAdd{}}
+			commitErrs[i] = commitDeltaTable(fs, stale, "append", nil, iop.NewColumns(iop.Column{Name: "id", Type: iop.BigIntType}))
+		}(i)
+	}
+	commitWg.Wait()
+	for _, err := range commitErrs {
+		assert.NoError(t, err)
+	}
+	table, err = readDeltaTable(fs, tableURL)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 7, table.Version)
+
+	// data files must be readable by delta readers
+	fs3, err := NewFileSysClient(dbio.TypeFileLocal, "FORMAT=delta", "COMPRESSION=xz")
+	assert.NoError(t, err)
+	df, err = iop.MakeDataFlow(data.Stream())
+	assert.NoError(t, err)
+	_, err = fs3.WriteDataflow(df, tableURL)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "not supported for delta tables")
+	}
+
+	if !t.Failed() {
+		os.RemoveAll("test/test_write_delta")
+	}
+}
+
+func TestFileSysLocalCompression(t *testing.T) {
+	t.Parallel()
+	folder := "test/test_compression"
+	os.RemoveAll(folder)
+
+	data := iop.NewDataset(iop.NewColumns(iop.Column{Name: "id", Type: iop.BigIntType}, iop.Column{Name: "name", Type: iop.StringType}))
+	data.Inferred = true
+	for i := 0; i < 50; i++ {
+		data.Append([]any{int64(i), g.F("name_%d", i)})
+	}
+
+	readCount := func(url string) int {
+		fs, err := NewFileSysClient(dbio.TypeFileLocal)
+		assert.NoError(t, err)
+		df, err := fs.ReadDataflow(url)
+		if !assert.NoError(t, err, url) {
+			return 0
+		}
+		result, err := df.Collect()
+		assert.NoError(t, err, url)
+		return len(result.Rows)
+	}
+
+	for _, cpType := range []iop.CompressorType{iop.GzipCompressorType, iop.ZStandardCompressorType, iop.Lz4CompressorType, iop.XzCompressorType, iop.BrotliCompressorType} {
+		suffix := iop.NewCompressor(cpType).Suffix()
+
+		// folder with parts
+		fs, err := NewFileSysClient(dbio.TypeFileLocal, "COMPRESSION="+string(cpType), "FILE_MAX_ROWS=20")
+		assert.NoError(t, err)
+		df, err := iop.MakeDataFlow(data.Stream())
+		assert.NoError(t, err)
+		partsURL := g.F("%s/%s", folder, strings.ToLower(string(cpType)))
+		_, err = fs.WriteDataflow(df, partsURL)
+		if !assert.NoError(t, err, cpType) {
+			continue
+		}
+
+		paths, err := fs.ListRecursive(partsURL)
+		assert.NoError(t, err)
+		if assert.Len(t, paths, 3, cpType) {
+			assert.True(t, strings.HasSuffix(paths[0], ".csv"+suffix), paths[0])
+		}
+		assert.Equal(t, 50, readCount(partsURL), cpType)
+
+		// single file, compression from suffix
+		fs, err = NewFileSysClient(dbio.TypeFileLocal)
+		assert.NoError(t, err)
+		df, err = iop.MakeDataFlow(data.Stream())
+		assert.NoError(t, err)
+		fileURL := g.F("%s/file.csv%s", folder, suffix)
+		_, err = fs.WriteDataflow(df, fileURL)
+		assert.NoError(t, err, cpType)
+		assert.Equal(t, 50, readCount(fileURL), cpType)
+	}
+
+	// bzip2 is read only
+	fs, err := NewFileSysClient(dbio.TypeFileLocal)
+	assert.NoError(t, err)
+	df, err := iop.MakeDataFlow(data.Stream())
+	assert.NoError(t, err)
+	_, err = fs.WriteDataflow(df, folder+"/file.csv.bz2")
+	assert.Error(t, err)
+
+	if !t.Failed() {
+		os.RemoveAll(folder)
+	}
+}
+
+func TestFileSysLocalEncryption(t *testing.T) {
+	t.Parallel()
+	folder := "test/test_encryption"
+	os.RemoveAll(folder)
+	os.MkdirAll(folder, 0755)
+
+	// generate keys, private key as a file, public key in env var
+	entity, err := openpgp.NewEntity("sling", "test", "test@sling.local", &packet.Config{RSABits: 1024})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	privateKeyFile, err := os.Create(folder + "/private.asc")
+	assert.NoError(t, err)
+	aw, _ := armor.Encode(privateKeyFile, openpgp.PrivateKeyType, nil)
+	assert.NoError(t, entity.SerializePrivate(aw, nil))
+	aw.Close()
+	privateKeyFile.Close()
+
+	var publicKey bytes.Buffer
+	aw, _ = armor.Encode(&publicKey, openpgp.PublicKeyType, nil)
+	assert.NoError(t, entity.Serialize(aw))
+	aw.Close()
+	os.Setenv("TEST_SLING_PGP_PUBLIC_KEY", publicKey.String())
+
+	data := iop.NewDataset(iop.NewColumns(iop.Column{Name: "id", Type: iop.BigIntType}, iop.Column{Name: "name", Type: iop.StringType}))
+	data.Inferred = true
+	for i := 0; i < 50; i++ {
+		data.Append([]any{int64(i), g.F("name_%d", i)})
+	}
+
+	encryptProp := "ENCRYPTION=" + g.Marshal(Encryption{Type: EncryptionTypePGP, PublicKey: "TEST_SLING_PGP_PUBLIC_KEY"})
+	decryptProp := "ENCRYPTION=" + g.Marshal(Encryption{PrivateKey: folder + "/private.asc"})
+
+	readCount := func(url string, props ...string) (int, error) {
+		fs, err := NewFileSysClient(dbio.TypeFileLocal, props...)
+		assert.NoError(t, err)
+		df, err := fs.ReadDataflow(url)
+		if err != nil {
+			return 0, err
+		}
+		result, err := df.Collect()
+		return len(result.Rows), err
+	}
+
+	// folder with compressed and encrypted parts
+	fs, err := NewFileSysClient(dbio.TypeFileLocal, encryptProp, "COMPRESSION=GZIP", "FILE_MAX_ROWS=20")
+	assert.NoError(t, err)
+	df, err := iop.MakeDataFlow(data.Stream())
+	assert.NoError(t, err)
+	partsURL := folder + "/parts"
+	_, err = fs.WriteDataflow(df, partsURL)
+	assert.NoError(t, err)
+
+	paths, err := fs.ListRecursive(partsURL)
+	assert.NoError(t, err)
+	if assert.Len(t, paths, 3) {
+		assert.True(t, strings.HasSuffix(paths[0], ".csv.gz.pgp"), paths[0])
+		content, _ := os.ReadFile(strings.TrimPrefix(paths[0], "file://"))
+		assert.NotContains(t, string(content), "name_")
+		assert.NotEqual(t, iop.GzipCompressorType, iop.DetectCompressor(content))
+	}
+
+	count, err := readCount(partsURL, decryptProp)
+	assert.NoError(t, err)
+	assert.Equal(t, 50, count)
+
+	// cannot read without the key
+	_, err = readCount(partsURL)
+	assert.Error(t, err)
+
+	// single armored file, from inline key
+	encryptProp = "ENCRYPTION=" + g.Marshal(Encryption{PublicKey: publicKey.String(), Armor: true})
+	for _, format := range []FileType{FileTypeCsv, FileTypeParquet} {
+		fs, err = NewFileSysClient(dbio.TypeFileLocal, encryptProp, "FORMAT="+string(format))
+		assert.NoError(t, err)
+		df, err = iop.MakeDataFlow(data.Stream())
+		assert.NoError(t, err)
+		fileURL := g.F("%s/file%s.asc", folder, format.Ext())
+		_, err = fs.WriteDataflow(df, fileURL)
+		assert.NoError(t, err, format)
+
+		content, _ := os.ReadFile(fileURL)
+		assert.True(t, strings.HasPrefix(string(content), "-----BEGIN PGP MESSAGE"), format)
+
+		count, err = readCount(fileURL, decryptProp)
+		assert.NoError(t, err, format)
+		assert.Equal(t, 50, count, format)
+	}
+
+	// missing public key
+	fs, err = NewFileSysClient(dbio.TypeFileLocal, decryptProp)
+	assert.NoError(t, err)
+	df, err = iop.MakeDataFlow(data.Stream())
+	assert.NoError(t, err)
+	_, err = fs.WriteDataflow(df, folder+"/file.csv.pgp")
+	assert.Error(t, err)
+
+	if !t.Failed() {
+		os.RemoveAll(folder)
+	}
+}
+
+func TestFileSysLocalManifest(t *testing.T) {
+	t.Parallel()
+	folder := "test/test_manifest"
+	os.RemoveAll(folder)
+
+	data := iop.NewDataset(iop.NewColumns(iop.Column{Name: "id", Type: iop.BigIntType}, iop.Column{Name: "name", Type: iop.StringType}))
+	data.Inferred = true
+	for i := 0; i < 50; i++ {
+		data.Append([]any{int64(i), g.F("name_%d", i)})
+	}
+
+	// folder with parts, json manifest
+	fs, err := NewFileSysClient(dbio.TypeFileLocal, "MANIFEST=json", "SUCCESS_MARKER=true", "FILE_MAX_ROWS=20")
+	assert.NoError(t, err)
+	df, err := iop.MakeDataFlow(data.Stream())
+	assert.NoError(t, err)
+	partsURL := folder + "/parts"
+	_, err = fs.WriteDataflow(df, partsURL)
+	assert.NoError(t, err)
+
+	assert.FileExists(t, partsURL+"/"+SuccessMarkerName)
+	content, err := os.ReadFile(partsURL + "/_manifest.json")
+	if assert.NoError(t, err) {
+		manifest := Manifest{}
+		assert.NoError(t, g.Unmarshal(string(content), &manifest))
+		assert.EqualValues(t, 50, manifest.Rows)
+		if assert.Len(t, manifest.Files, 3) {
+			file := manifest.Files[0]
+			assert.Equal(t, 20, file.Rows)
+			assert.Equal(t, []ManifestColumn{{"id", "bigint"}, {"name", "string"}}, file.Columns)
+
+			fileContent, err := os.ReadFile(strings.TrimPrefix(file.URL, "file://"))
+			assert.NoError(t, err)
+			assert.EqualValues(t, len(fileContent), file.Bytes)
+			assert.Equal(t, g.F("%x", md5.Sum(fileContent)), file.MD5)
+			assert.Equal(t, g.F("%x", sha256.Sum256(fileContent)), file.SHA256)
+		}
+	}
+
+	// sidecar files are not read as data
+	df, err = fs.ReadDataflow(partsURL)
+	if assert.NoError(t, err) {
+		result, err := df.Collect()
+		assert.NoError(t, err)
+		assert.Len(t, result.Rows, 50)
+	}
+
+	// single file, csv manifest
+	fs, err = NewFileSysClient(dbio.TypeFileLocal, "MANIFEST=csv", "SUCCESS_MARKER=true")
+	assert.NoError(t, err)
+	df, err = iop.MakeDataFlow(data.Stream())
+	assert.NoError(t, err)
+	fileURL := folder + "/single/file.csv"
+	_, err = fs.WriteDataflow(df, fileURL)
+	assert.NoError(t, err)
+
+	assert.FileExists(t, fileURL+"."+SuccessMarkerName)
+	assert.NoFileExists(t, folder+"/single/"+SuccessMarkerName)
+	content, err = os.ReadFile(fileURL + ".manifest.csv")
+	if assert.NoError(t, err) {
+		lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+		if assert.Len(t, lines, 2) {
+			assert.True(t, strings.HasPrefix(lines[0], "url,rows,bytes,md5,sha256,columns"))
+			assert.Contains(t, lines[1], "file.csv,50,")
+		}
+	}
+
+	// json parts are counted too
+	for _, format := range []string{"json", "jsonlines"} {
+		fs, err = NewFileSysClient(dbio.TypeFileLocal, "MANIFEST=json", "FORMAT="+format, "FILE_MAX_ROWS=20")
+		assert.NoError(t, err)
+		df, err = iop.MakeDataFlow(data.Stream())
+		assert.NoError(t, err)
+		jsonURL := folder + "/" + format
+		_, err = fs.WriteDataflow(df, jsonURL)
+		assert.NoError(t, err)
+
+		content, err = os.ReadFile(jsonURL + "/_manifest.json")
+		if assert.NoError(t, err) {
+			manifest := Manifest{}
+			assert.NoError(t, g.Unmarshal(string(content), &manifest))
+			assert.EqualValues(t, 50, manifest.Rows, format)
+			for _, file := range manifest.Files {
+				assert.Greater(t, file.Rows, 0, format)
+			}
+		}
+	}
+
+	// invalid manifest format
+	fs, err = NewFileSysClient(dbio.TypeFileLocal, "MANIFEST=xml")
+	assert.NoError(t, err)
+	df, err = iop.MakeDataFlow(data.Stream())
+	assert.NoError(t, err)
+	_, err = fs.WriteDataflow(df, folder+"/invalid")
+	assert.Error(t, err)
+
+	if !t.Failed() {
+		os.RemoveAll(folder)
+	}
+}
+
+func TestFileSysLocalDatePath(t *testing.T) {
+	t.Parallel()
+	folder := "test/test_date_path"
+	os.RemoveAll(folder)
+
+	now := time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC)
+	for _, c := range []struct {
+		value    string
+		expected time.Time
+	}{
+		{"", now},
+		{"-1d", time.Date(2024, 2, 29, 10, 30, 0, 0, time.UTC)},
+		{"-6h", time.Date(2024, 3, 1, 4, 30, 0, 0, time.UTC)},
+		{"-1w", time.Date(2024, 2, 23, 10, 30, 0, 0, time.UTC)},
+		{"+1M", time.Date(2024, 4, 1, 10, 30, 0, 0, time.UTC)},
+		{"2024-01-31", time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)},
+	} {
+		val, err := ParseRunDate(c.value, now)
+		if assert.NoError(t, err, c.value) {
+			assert.Equal(t, c.expected, val, c.value)
+		}
+	}
+	_, err := ParseRunDate("yesterday", now)
+	assert.Error(t, err)
+
+	paths, err := ResolveDatePaths("s3://bucket/events/{YYYY}/{MM}/{DD}/*.json", "2024-01-31", "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"s3://bucket/events/2024/01/31/*.json"}, paths)
+
+	paths, err = ResolveDatePaths("s3://bucket/events/{YYYY}/{MM}/{DD}/", "", "2024-01-30,2024-02-02")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"s3://bucket/events/2024/01/30/",
+		"s3://bucket/events/2024/01/31/",
+		"s3://bucket/events/2024/02/01/",
+		"s3://bucket/events/2024/02/02/",
+	}, paths)
+
+	// the step is the finest token, the start period is included
+	paths, err = ResolveDatePaths("s3://bucket/events/{YYYY}-{MM}/", "", "2023-11-15,2024-01-01")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"s3://bucket/events/2023-11/", "s3://bucket/events/2023-12/", "s3://bucket/events/2024-01/"}, paths)
+
+	_, err = ResolveDatePaths("s3://bucket/events/{YYYY}/", "", "2024-01-01")
+	assert.Error(t, err)
+	_, err = ResolveDatePaths("s3://bucket/events/{YYYY}/", "", "2024-01-01,2023-01-01")
+	assert.Error(t, err)
+
+	// files of the range are read, missing days are skipped
+	for _, day := range []string{"2024/01/30", "2024/02/01"} {
+		os.MkdirAll(folder+"/"+day, 0755)
+		content := g.F("id,day\n1,%s\n2,%s\n", day, day)
+		assert.NoError(t, os.WriteFile(folder+"/"+day+"/data.csv", []byte(content), 0644))
+		assert.NoError(t, os.WriteFile(folder+"/"+day+"/notes.txt", []byte("skip"), 0644))
+	}
+
+	fs, err := NewFileSysClient(dbio.TypeFileLocal, "RANGE=2024-01-29,2024-02-02")
+	assert.NoError(t, err)
+	df, err := fs.ReadDataflow("file://" + folder + "/{YYYY}/{MM}/{DD}/*.csv")
+	if assert.NoError(t, err) {
+		data, err := df.Collect()
+		assert.NoError(t, err)
+		assert.Len(t, data.Rows, 4)
+		assert.Len(t, df.FsPaths, 2)
+	}
+
+	fs, err = NewFileSysClient(dbio.TypeFileLocal, "RUN_DATE=2024-02-01")
+	assert.NoError(t, err)
+	df, err = fs.ReadDataflow("file://" + folder + "/{YYYY}/{MM}/{DD}/*.csv")
+	if assert.NoError(t, err) {
+		data, err := df.Collect()
+		assert.NoError(t, err)
+		if assert.Len(t, data.Rows, 2) {
+			assert.Equal(t, "2024/02/01", cast.ToString(data.Rows[0][1]))
+		}
+	}
+
+	// no files for the run date
+	fs, err = NewFileSysClient(dbio.TypeFileLocal, "RUN_DATE=2024-01-31")
+	assert.NoError(t, err)
+	_, err = fs.ReadDataflow("file://" + folder + "/{YYYY}/{MM}/{DD}/*.csv")
+	assert.Error(t, err)
+
+	if !t.Failed() {
+		os.RemoveAll(folder)
+	}
+}
+
+func TestFileSysLocalUnionByName(t *testing.T) {
+	t.Parallel()
+	folder := "test/test_union_by_name"
+	os.RemoveAll(folder)
+	os.MkdirAll(folder, 0755)
+
+	files := map[string]string{
+		"1.csv": "id,name\n1,alice\n2,bob\n",
+		"2.csv": "amount,id\n1.5,3\n2.5,x4\n",
+	}
+	for name, content := range files {
+		assert.NoError(t, os.WriteFile(folder+"/"+name, []byte(content), 0644))
+	}
+
+	fs, err := NewFileSysClient(dbio.TypeFileLocal, "UNION_BY_NAME=true")
+	assert.NoError(t, err)
+
+	df, err := fs.ReadDataflow(folder)
+	if assert.NoError(t, err) {
+		data, err := df.Collect()
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"id", "name", "amount"}, data.Columns.Names())
+		assert.Equal(t, iop.TextType, data.Columns[0].Type) // widened with x4
+		assert.Equal(t, iop.DecimalType, data.Columns[2].Type)
+
+		records := lo.Map(data.Rows, func(row []any, i int) map[string]any { return data.Columns.MakeRec(row) })
+		sort.Slice(records, func(i, j int) bool { return cast.ToString(records[i]["id"]) < cast.ToString(records[j]["id"]) })
+		if assert.Len(t, records, 4) {
+			assert.Equal(t, "1", cast.ToString(records[0]["id"]))
+			assert.Equal(t, "alice", records[0]["name"])
+			assert.Nil(t, records[0]["amount"])
+			assert.Equal(t, "x4", records[3]["id"])
+			assert.Nil(t, records[3]["name"])
+			assert.Equal(t, 2.5, cast.ToFloat64(records[3]["amount"]))
+		}
+	}
+
+	// parquet and avro columns are read from the schema, without a stream
+	for _, path := range []string{"test/test1/parquet/test1.parquet", "test/test1/avro/twitter.avro"} {
+		fs, err := NewFileSysClient(dbio.TypeFileLocal)
+		assert.NoError(t, err)
+
+		cols, err := scanColumns(fs, path)
+		if assert.NoError(t, err, path) {
+			ds, err := fs.GetDatastream(path)
+			if assert.NoError(t, err, path) {
+				assert.NoError(t, ds.WaitReady())
+				assert.Equal(t, ds.Columns.Names(), cols.Names(), path)
+				ds.Context.Cancel()
+			}
+		}
+	}
+
+	if !t.Failed() {
+		os.RemoveAll(folder)
+	}
+}
+
+func TestFileSysLocalNormalize(t *testing.T) {
+	t.Parallel()
+	folder := "test/test_normalize"
+	os.RemoveAll(folder)
+	os.MkdirAll(folder, 0755)
+
+	content := `[
+		{"order_id": 1, "customer": {"name": "alice", "tags": ["vip"]}, "items": [
+			{"sku": "a", "qty": 2, "options": [{"name": "gift"}]},
+			{"sku": "b", "qty": 1}
+		]},
+		{"order_id": 2, "customer": {"name": "bob"}, "items": [{"sku": "c", "qty": 5}]},
+		{"order_id": 2, "customer": {"name": "bob"}, "items": [{"sku": "c", "qty": 5}]}
+	]`
+	assert.NoError(t, os.WriteFile(folder+"/orders.json", []byte(content), 0644))
+
+	fs, err := NewFileSysClient(dbio.TypeFileLocal, "NORMALIZE=true")
+	assert.NoError(t, err)
+
+	df, err := fs.ReadDataflow(folder + "/orders.json")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// the child streams are consumed concurrently with the parent
+	children := map[string]iop.Dataset{}
+	childErrs := g.ErrorGroup{}
+	wg := sync.WaitGroup{}
+	mux := sync.Mutex{}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for child := range df.ChildStreams {
+			wg.Add(1)
+			go func(child *iop.ChildStream) {
+				defer wg.Done()
+				data, err := child.Collect(0)
+				mux.Lock()
+				children[child.Name] = data
+				childErrs.Capture(err)
+				mux.Unlock()
+			}(child)
+		}
+	}()
+
+	data, err := df.Collect()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"_sling_id", "customer__name", "customer__tags", "order_id"}, data.Columns.Names())
+	if assert.Len(t, data.Rows, 3) {
+		assert.Equal(t, `["vip"]`, data.Rows[0][2])
+		assert.NotEqual(t, data.Rows[1][0], data.Rows[2][0]) // identical records
+	}
+	orderIDs := map[any]string{}
+	for _, rec := range data.Records() {
+		orderIDs[cast.ToInt(rec["order_id"])] = cast.ToString(rec["_sling_id"])
+	}
+
+	<-done
+	wg.Wait()
+	assert.NoError(t, childErrs.Err())
+	if !assert.Len(t, children, 2) {
+		return
+	}
+
+	items := children["items"]
+	assert.Equal(t, []string{"_sling_parent_id", "_sling_index", "_sling_id", "qty", "sku"}, items.Columns.Names())
+	assert.Equal(t, iop.IntegerType, items.Columns[3].Type)
+	if assert.Len(t, items.Rows, 4) {
+		rec := items.Records()[1]
+		assert.Equal(t, orderIDs[1], rec["_sling_parent_id"])
+		assert.EqualValues(t, 1, rec["_sling_index"])
+		assert.Equal(t, "b", rec["sku"])
+		assert.NotEqual(t, items.Rows[2][2], items.Rows[3][2]) // identical records
+	}
+	itemID := cast.ToString(items.Records()[0]["_sling_id"])
+
+	options := children["items__options"]
+	if assert.Len(t, options.Rows, 1) {
+		rec := options.Records()[0]
+		assert.Equal(t, itemID, rec["_sling_parent_id"])
+		assert.Equal(t, "gift", rec["name"])
+	}
+
+	if !t.Failed() {
+		os.RemoveAll(folder)
+	}
+}
+
+func TestFileSysLocalArchive(t *testing.T) {
+	t.Parallel()
+	folder := "test/test_archive"
+	os.RemoveAll(folder)
+	os.MkdirAll(folder, 0755)
+
+	entries := []struct{ name, content string }{
+		{"data/a.csv", "id,name\n1,a1\n2,a2\n"},
+		{"data/b.csv", "id,name\n3,b1\n"},
+		{"data/nested/c.json", `[{"id": 4, "name": "c1"}]`},
+		{"readme.txt", "not data"},
+	}
+
+	// zip
+	zipFile, err := os.Create(folder + "/archive.zip")
+	assert.NoError(t, err)
+	zw := zip.NewWriter(zipFile)
+	for _, entry := range entries {
+		w, err := zw.Create(entry.name)
+		assert.NoError(t, err)
+		w.Write([]byte(entry.content))
+	}
+	assert.NoError(t, zw.Close())
+	zipFile.Close()
+
+	// tar.gz
+	tarFile, err := os.Create(folder + "/archive.tar.gz")
+	assert.NoError(t, err)
+	gw := gzip.NewWriter(tarFile)
+	tw := tar.NewWriter(gw)
+	tw.WriteHeader(&tar.Header{Name: "data/", Typeflag: tar.TypeDir, Mode: 0755})
+	for _, entry := range entries {
+		tw.WriteHeader(&tar.Header{Name: entry.name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(entry.content))})
+		tw.Write([]byte(entry.content))
+	}
+	assert.NoError(t, tw.Close())
+	gw.Close()
+	tarFile.Close()
+
+	metadata := g.Marshal(iop.Metadata{StreamURL: iop.KeyValue{Key: "_stream_url"}})
+	readArchive := func(url, glob string) (urls map[string]int, err error) {
+		fs, err := NewFileSysClient(dbio.TypeFileLocal, "ARCHIVE_GLOB="+glob, "METADATA="+metadata)
+		assert.NoError(t, err)
+		df, err := fs.ReadDataflow(url)
+		if err != nil {
+			return nil, err
+		}
+
+		urls = map[string]int{}
+		for ds := range df.StreamCh {
+			data, err := ds.Collect(0)
+			if err != nil {
+				return nil, err
+			}
+			for _, row := range data.Rows {
+				urls[cast.ToString(row[len(row)-1])]++
+			}
+		}
+		return urls, df.Err()
+	}
+
+	for _, url := range []string{folder + "/archive.zip", folder + "/archive.tar.gz"} {
+		streamURL := "file://" + url
+		urls, err := readArchive(url, "data/*.csv")
+		if assert.NoError(t, err, url) {
+			assert.Equal(t, map[string]int{streamURL + "/data/a.csv": 2, streamURL + "/data/b.csv": 1}, urls, url)
+		}
+
+		urls, err = readArchive(url, "**/*.json")
+		if assert.NoError(t, err, url) {
+			assert.Equal(t, map[string]int{streamURL + "/data/nested/c.json": 1}, urls, url)
+		}
+
+		_, err = readArchive(url, "*.parquet")
+		assert.Error(t, err, url)
+	}
+
+	if !t.Failed() {
+		os.RemoveAll(folder)
+	}
+}
+
+func TestFileSysLocalIceberg(t *testing.T) {
+	t.Parallel()
+	tableURL := "test/test_read_iceberg/table"
+	location := "s3a://warehouse/db/table" // as written by spark, rebased on read
+	os.RemoveAll(tableURL)
+
+	fs, err := NewFileSysClient(dbio.TypeFileLocal)
+	assert.NoError(t, err)
+
+	// data files
+	writeParquet := func(name string, ids ...int64) {
+		data := iop.NewDataset(iop.NewColumns(iop.Column{Name: "id", Type: iop.BigIntType}, iop.Column{Name: "name", Type: iop.StringType}))
+		data.Inferred = true
+		for _, id := range ids {
+			data.Append([]any{id, g.F("name_%d", id)})
+		}
+		df, err := iop.MakeDataFlow(data.Stream())
+		assert.NoError(t, err)
+		_, err = fs.WriteDataflow(df, tableURL+"/data/"+name)
+		assert.NoError(t, err)
+	}
+	writeParquet("a.parquet", 1, 2)
+	writeParquet("b.parquet", 3)
+
+	writeAvro := func(name, schema string, records []map[string]any) {
+		var buf bytes.Buffer
+		writer, err := goavro.NewOCFWriter(goavro.OCFConfig{W: &buf, Schema: schema})
+		assert.NoError(t, err)
+		assert.NoError(t, writer.Append(records))
+		assert.NoError(t, os.WriteFile(tableURL+"/metadata/"+name, buf.Bytes(), 0644))
+	}
+
+	manifestSchema := `{"type":"record","name":"manifest_entry","fields":[
+		{"name":"status","type":"int"},
+		{"name":"snapshot_id","type":["null","long"],"default":null},
+		{"name":"data_file","type":{"type":"record","name":"r2","fields":[
+			{"name":"content","type":"int","default":0},
+			{"name":"file_path","type":"string"},
+			{"name":"file_format","type":"string"},
+			{"name":"record_count","type":"long"}]}}]}`
+	manifestListSchema := `{"type":"record","name":"manifest_file","fields":[
+		{"name":"manifest_path","type":"string"},
+		{"name":"content","type":"int"},
+		{"name":"added_snapshot_id","type":["null","long"],"default":null}]}`
+
+	entry := func(status int, snapshotID int64, file string, count int64) map[string]any {
+		return map[string]any{
+			"status":      status,
+			"snapshot_id": goavro.Union("long", snapshotID),
+			"data_file":   map[string]any{"content": 0, "file_path": location + "/data/" + file, "file_format": "PARQUET", "record_count": count},
+		}
+	}
+
+	os.MkdirAll(tableURL+"/metadata", 0755)
+	writeAvro("m1.avro", manifestSchema, []map[string]any{entry(1, 1, "a.parquet", 2)})
+	writeAvro("m2.avro", manifestSchema, []map[string]any{
+		entry(0, 1, "a.parquet", 2),
+		entry(1, 2, "b.parquet", 1),
+		entry(2, 2, "missing.parquet", 5), // deleted, should be skipped
+	})
+	writeAvro("snap-1.avro", manifestListSchema, []map[string]any{
+		{"manifest_path": location + "/metadata/m1.avro", "content": 0, "added_snapshot_id": goavro.Union("long", int64(1))},
+	})
+	writeAvro("snap-2.avro", manifestListSchema, []map[string]any{
+		{"manifest_path": location + "/metadata/m2.avro", "content": 0, "added_snapshot_id": goavro.Union("long", int64(2))},
+	})
+
+	ts1, ts2 := int64(1700000000000), int64(1700000600000)
+	snapshot1 := g.M("snapshot-id", 1, "timestamp-ms", ts1, "manifest-list", location+"/metadata/snap-1.avro")
+	snapshot2 := g.M("snapshot-id", 2, "timestamp-ms", ts2, "manifest-list", location+"/metadata/snap-2.avro")
+	metadata1 := g.M("format-version", 2, "location", location, "current-snapshot-id", 1,
+		"snapshots", []any{snapshot1}, "snapshot-log", []any{g.M("snapshot-id", 1, "timestamp-ms", ts1)})
+	metadata2 := g.M("format-version", 2, "location", location, "current-snapshot-id", 2,
+		"snapshots", []any{snapshot1, snapshot2}, "snapshot-log", []any{g.M("snapshot-id", 1, "timestamp-ms", ts1), g.M("snapshot-id", 2, "timestamp-ms", ts2)})
+	os.WriteFile(tableURL+"/metadata/v1.metadata.json", []byte(g.Marshal(metadata1)), 0644)
+	os.WriteFile(tableURL+"/metadata/v2.metadata.json", []byte(g.Marshal(metadata2)), 0644)
+	os.WriteFile(tableURL+"/metadata/version-hint.text", []byte("2"), 0644)
+
+	readRows := func(props ...string) (int, error) {
+		fs, err := NewFileSysClient(dbio.TypeFileLocal, append(props, "FORMAT=iceberg")...)
+		assert.NoError(t, err)
+		df, err := fs.ReadDataflow(tableURL)
+		if err != nil {
+			return 0, err
+		}
+		data, err := df.Collect()
+		if err != nil {
+			return 0, err
+		}
+		return len(data.Rows), nil
+	}
+
+	count, err := readRows()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	count, err = readRows("SNAPSHOT_ID=1")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	count, err = readRows("AS_OF=" + cast.ToString(ts1+1000))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	count, err = readRows("AS_OF=" + time.UnixMilli(ts2).UTC().Format(time.RFC3339))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	_, err = readRows("AS_OF=2001-01-01")
+	assert.Error(t, err)
+
+	_, err = readRows("SNAPSHOT_ID=3")
+	assert.Error(t, err)
+
+	if !t.Failed() {
+		os.RemoveAll("test/test_read_iceberg")
+	}
+}
+
 func TestFileSysLocalParquet(t *testing.T) {
 	t.Parallel()
 	fs, err := NewFileSysClient(dbio.TypeFileLocal)
@@ -1003,3 +1980,397 @@ func testManyCSV(t *testing.T) {
 		}
 	}
 }
+
+func TestFileSysAPI(t *testing.T) {
+	t.Parallel()
+
+	users := []map[string]any{}
+	for i := 1; i <= 5; i++ {
+		users = append(users, g.M("id", i, "name", g.F("user%d", i), "updated", g.F("2024-01-0%d", i)))
+	}
+
+	var requests atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oauth/token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token": "oauth-token", "token_type": "bearer", "expires_in": 3600}`))
+	})
+	mux.HandleFunc("/v1/", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		// rate limit the first request
+		if requests.Add(1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+
+		authorized := map[string]bool{
+			"Bearer secret":      true,
+			"Bearer oauth-token": true,
+		}
+		user, password, _ := r.BasicAuth()
+		if !authorized[r.Header.Get("Authorization")] && r.Header.Get("X-Token") != "secret" && !(user == "user" && password == "secret") {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		// filter on the incremental value
+		records := lo.Filter(users, func(u map[string]any, i int) bool {
+			return cast.ToString(u["updated"]) > query.Get("since")
+		})
+
+		start, size := 0, len(records)
+		switch r.URL.Path {
+		case "/v1/page":
+			size = cast.ToInt(query.Get("per_page"))
+			start = (cast.ToInt(query.Get("page")) - 1) * size
+		case "/v1/offset":
+			size = cast.ToInt(query.Get("limit"))
+			start = cast.ToInt(query.Get("offset"))
+		case "/v1/cursor", "/v1/link":
+			size = 2
+			start = cast.ToInt(query.Get("cursor"))
+		}
+		end := lo.Min([]int{start + size, len(records)})
+		start = lo.Min([]int{start, end})
+
+		body := g.M("data", g.M("items", records[start:end]))
+		if end < len(records) {
+			switch r.URL.Path {
+			case "/v1/cursor":
+				body["next"] = end
+			case "/v1/link":
+				w.Header().Set("Link", g.F(`<%s?cursor=%d>; rel="next"`, r.URL.Path, end))
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(g.Marshal(body)))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	type testCase struct {
+		name       string
+		endpoint   string
+		props      []string
+		options    APIOptions
+		expectedID []int
+	}
+
+	cases := []testCase{
+		{
+			name:       "bearer, page",
+			endpoint:   "page",
+			props:      []string{"TOKEN=secret"},
+			options:    APIOptions{Pagination: &APIPagination{Type: APIPaginationTypePage, PageSize: 2}},
+			expectedID: []int{1, 2, 3, 4, 5},
+		},
+		{
+			name:       "basic, offset",
+			endpoint:   "offset",
+			props:      []string{"USERNAME=user", "PASSWORD=secret"},
+			options:    APIOptions{Pagination: &APIPagination{Type: APIPaginationTypeOffset, PageSize: 3}},
+			expectedID: []int{1, 2, 3, 4, 5},
+		},
+		{
+			name:       "api key, cursor",
+			endpoint:   "cursor",
+			props:      []string{"API_KEY=secret", "API_KEY_HEADER=X-Token"},
+			options:    APIOptions{Pagination: &APIPagination{Type: APIPaginationTypeCursor, CursorPath: "next"}},
+			expectedID: []int{1, 2, 3, 4, 5},
+		},
+		{
+			name:       "oauth2, link",
+			endpoint:   "link",
+			props:      []string{"CLIENT_ID=id", "CLIENT_SECRET=secret", "TOKEN_URL=" + server.URL + "/oauth/token"},
+			options:    APIOptions{Pagination: &APIPagination{Type: APIPaginationTypeLink}},
+			expectedID: []int{1, 2, 3, 4, 5},
+		},
+		{
+			name:       "incremental",
+			endpoint:   "cursor",
+			props:      []string{"TOKEN=secret", "INCREMENTAL_VALUE=2024-01-03"},
+			options:    APIOptions{IncrementalParam: "since", Pagination: &APIPagination{Type: APIPaginationTypeCursor, CursorPath: "next"}},
+			expectedID: []int{4, 5},
+		},
+	}
+
+	for _, c := range cases {
+		requests.Store(0)
+		c.options.Records = "data.items"
+		props := append(c.props, "BASE_URL="+server.URL+"/v1", "API="+g.Marshal(c.options))
+
+		fs, err := NewFileSysClientFromURL("api://localhost", props...)
+		if !assert.NoError(t, err, c.name) {
+			continue
+		}
+
+		df, err := fs.ReadDataflow("api://localhost/" + c.endpoint)
+		if !assert.NoError(t, err, c.name) {
+			continue
+		}
+
+		data, err := df.Collect()
+		if !assert.NoError(t, err, c.name) {
+			continue
+		}
+
+		ids := lo.Map(data.Records(), func(rec map[string]any, i int) int {
+			return cast.ToInt(rec["id"])
+		})
+		assert.Equal(t, c.expectedID, ids, c.name)
+		assert.Equal(t, []string{"id", "name", "updated"}, data.Columns.Names(), c.name)
+	}
+
+	// unauthorized
+	fs, err := NewFileSysClientFromURL("api://localhost", "BASE_URL="+server.URL+"/v1", "MAX_RETRIES=0")
+	if assert.NoError(t, err) {
+		requests.Store(1)
+		_, err = fs.ReadDataflow("api://localhost/page")
+		assert.Error(t, err)
+	}
+
+	// stopped while paging, the pending write returns
+	ctx, cancel := context.WithCancel(context.Background())
+	options := APIOptions{Records: "data.items", Pagination: &APIPagination{Type: APIPaginationTypePage, PageSize: 1}}
+	fs, err = NewFileSysClientFromURLContext(ctx, "api://localhost", "TOKEN=secret", "BASE_URL="+server.URL+"/v1", "API="+g.Marshal(options))
+	if assert.NoError(t, err) {
+		requests.Store(1)
+		reader, err := fs.GetReader("api://localhost/page")
+		if assert.NoError(t, err) {
+			_, err = reader.Read(make([]byte, 1)) // the opening bracket
+			assert.NoError(t, err)
+			cancel()
+			_, err = io.ReadAll(reader)
+			assert.Error(t, err)
+			assert.Less(t, requests.Load(), int32(4))
+		}
+	}
+	cancel()
+}
+
+func TestFileSysAPIWrite(t *testing.T) {
+	t.Parallel()
+
+	var requests, unavailable atomic.Int32
+	var mux sync.Mutex
+	received := []map[string]any{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// rate limit the first request, to be retried
+		if requests.Add(1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+
+		// the batch may have been received, not retried by default
+		if r.URL.Path == "/v1/unavailable" {
+			unavailable.Add(1)
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		if r.Method != http.MethodPut || r.Header.Get("X-Source") != "sling" || r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		records := []map[string]any{}
+		if err := json.NewDecoder(r.Body).Decode(&records); err != nil || len(records) > 2 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		mux.Lock()
+		received = append(received, records...)
+		mux.Unlock()
+
+		status := lo.Ternary(r.URL.Path == "/v1/failing", "error", "ok")
+		w.Write([]byte(g.Marshal(g.M("status", status))))
+	}))
+	defer server.Close()
+
+	makeDf := func() *iop.Dataflow {
+		data := iop.NewDataset(iop.NewColumnsFromFields("id", "name"))
+		for i := 1; i <= 5; i++ {
+			data.Append([]any{i, g.F("user%d", i)})
+		}
+		df, err := iop.MakeDataFlow(data.Stream())
+		assert.NoError(t, err)
+		return df
+	}
+
+	options := APIOptions{
+		Method:        "put",
+		BatchSize:     2,
+		Headers:       map[string]string{"X-Source": "sling"},
+		ResponseCheck: "status == 'ok'",
+	}
+	fs, err := NewFileSysClientFromURL(
+		"api://localhost", "BASE_URL="+server.URL+"/v1", "TOKEN=secret",
+		"CONCURRENCY=3", "API="+g.Marshal(options),
+	)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	_, err = fs.WriteDataflow(makeDf(), "api://localhost/contacts")
+	if assert.NoError(t, err) {
+		assert.EqualValues(t, 4, requests.Load()) // 3 batches and a retry
+		ids := lo.Map(received, func(rec map[string]any, i int) int { return cast.ToInt(rec["id"]) })
+		sort.Ints(ids)
+		assert.Equal(t, []int{1, 2, 3, 4, 5}, ids)
+	}
+
+	// response check fails
+	_, err = fs.WriteDataflow(makeDf(), "api://localhost/failing")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "response check failed")
+	}
+
+	// server errors fail the batch, retried with retry_writes only
+	requests.Store(1)
+	_, err = fs.WriteDataflow(makeDf(), "api://localhost/unavailable")
+	assert.Error(t, err)
+	assert.LessOrEqual(t, unavailable.Load(), int32(3)) // a request per batch
+
+	options.RetryWrites = true
+	fs, err = NewFileSysClientFromURL(
+		"api://localhost", "BASE_URL="+server.URL+"/v1", "TOKEN=secret",
+		"CONCURRENCY=1", "API="+g.Marshal(options),
+	)
+	if assert.NoError(t, err) {
+		unavailable.Store(0)
+		_, err = fs.WriteDataflow(makeDf(), "api://localhost/unavailable")
+		assert.Error(t, err)
+		assert.GreaterOrEqual(t, unavailable.Load(), int32(4)) // a batch and its retries
+	}
+}
+
+func TestFileSysSinger(t *testing.T) {
+	t.Parallel()
+
+	folder := t.TempDir()
+	messages := strings.Join([]string{
+		`{"type": "SCHEMA", "stream": "users", "key_properties": ["id"], "schema": {"properties": {"id": {"type": "integer"}, "name": {"type": ["null", "string"]}}}}`,
+		`{"type": "RECORD", "stream": "users", "record": {"id": 1, "name": "Fred"}}`,
+		`{"type": "RECORD", "stream": "users", "record": {"id": 2, "name": "Wilma"}}`,
+		`{"type": "STATE", "value": {"bookmarks": {"users": 2}}}`,
+	}, "\n")
+	messagesPath := filepath.Join(folder, "messages.jsonl")
+	assert.NoError(t, os.WriteFile(messagesPath, []byte(messages), 0644))
+
+	// the tap outputs the messages, and records its arguments
+	tapPath := filepath.Join(folder, "tap.sh")
+	tapScript := g.F("#!/bin/sh\necho \"$@\" > %s/tap_args.txt\ncat %s\n", folder, messagesPath)
+	assert.NoError(t, os.WriteFile(tapPath, []byte(tapScript), 0755))
+
+	statePath := filepath.Join(folder, "state.json")
+	fs, err := NewFileSysClientFromURL("singer://tap", "COMMAND="+tapPath, "CONFIG=config.json", "STATE="+statePath)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	df, err := fs.ReadDataflow("singer://tap/users")
+	if !assert.NoError(t, err) {
+		return
+	}
+	data, err := iop.MergeDataflow(df).Collect(0)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"id", "name"}, data.Columns.Names())
+		assert.Len(t, data.Rows, 2)
+		assert.Equal(t, "Wilma", data.Rows[1][1])
+		assert.Equal(t, `{"bookmarks": {"users": 2}}`, df.SingerState)
+	}
+
+	// state is passed once written, keyed by stream
+	usersStatePath := filepath.Join(folder, "state.users.json")
+	assert.Equal(t, usersStatePath, df.SingerStatePath)
+	assert.NoError(t, WriteSingerState(df.SingerStatePath, df.SingerState))
+	df, err = fs.ReadDataflow("singer://tap/users")
+	if assert.NoError(t, err) {
+		df.Collect()
+		args, _ := os.ReadFile(filepath.Join(folder, "tap_args.txt"))
+		assert.Equal(t, "--config config.json --state "+usersStatePath, strings.TrimSpace(string(args)))
+	}
+
+	// the stream options take precedence over the connection
+	singerOpts := SingerOptions{Catalog: "users.catalog.json", State: usersStatePath}
+	fs.SetProp("SINGER", g.Marshal(singerOpts))
+	df, err = fs.ReadDataflow("singer://tap/users")
+	if assert.NoError(t, err) {
+		df.Collect()
+		assert.Equal(t, usersStatePath, df.SingerStatePath)
+		args, _ := os.ReadFile(filepath.Join(folder, "tap_args.txt"))
+		assert.Equal(t, "--config config.json --catalog users.catalog.json --state "+usersStatePath, strings.TrimSpace(string(args)))
+	}
+	fs.SetProp("SINGER", "")
+
+	// stream not in output
+	_, err = fs.ReadDataflow("singer://tap/orders")
+	assert.Error(t, err)
+
+	// with a state, the other streams of the tap are rejected
+	messages += "\n" + `{"type": "SCHEMA", "stream": "orders", "schema": {"properties": {"id": {"type": "integer"}}}}`
+	assert.NoError(t, os.WriteFile(messagesPath, []byte(messages), 0644))
+	df, err = fs.ReadDataflow("singer://tap/users")
+	if err == nil {
+		_, err = df.Collect()
+	}
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "singer stream orders was not selected")
+	}
+
+	fs, err = NewFileSysClientFromURL("singer://tap", "COMMAND="+tapPath)
+	if assert.NoError(t, err) {
+		df, err = fs.ReadDataflow("singer://tap/users")
+		if assert.NoError(t, err) {
+			data, err = df.Collect()
+			assert.NoError(t, err)
+			assert.Len(t, data.Rows, 2)
+		}
+	}
+
+	// the target saves its input
+	outPath := filepath.Join(folder, "out.jsonl")
+	targetPath := filepath.Join(folder, "target.sh")
+	targetScript := g.F("#!/bin/sh\ncat > %s\necho '{\"bookmarks\": {}}'\n", outPath)
+	assert.NoError(t, os.WriteFile(targetPath, []byte(targetScript), 0755))
+
+	fs, err = NewFileSysClientFromURL("singer://target", "COMMAND="+targetPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	data = iop.NewDataset(iop.NewColumnsFromFields("id", "name"))
+	data.Append([]any{1, "Fred"})
+	data.Append([]any{2, "Wilma"})
+	df, err = iop.MakeDataFlow(data.Stream())
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	_, err = fs.WriteDataflow(df, "singer://target/people")
+	if assert.NoError(t, err) {
+		out, _ := os.ReadFile(outPath)
+		lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+		if assert.Len(t, lines, 3) {
+			msg := iop.SingerMessage{}
+			assert.NoError(t, g.Unmarshal(lines[2], &msg))
+			assert.Equal(t, iop.SingerMessageRecord, msg.Type)
+			assert.Equal(t, "people", msg.Stream)
+			assert.Equal(t, "Wilma", msg.Record["name"])
+		}
+	}
+
+	// the target fails
+	fs, err = NewFileSysClientFromURL("singer://target", "COMMAND=false")
+	if assert.NoError(t, err) {
+		df, _ = iop.MakeDataFlow(data.Stream())
+		_, err = fs.WriteDataflow(df, "singer://target/people")
+		assert.Error(t, err)
+	}
+}
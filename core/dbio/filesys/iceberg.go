@@ -0,0 +1,312 @@
+package filesys
+
+import (
+	"encoding/json"
+	"io"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flarco/g"
+	"github.com/linkedin/goavro/v2"
+	"github.com/slingdata-io/sling-cli/core/dbio/iop"
+	"github.com/spf13/cast"
+)
+
+var (
+	icebergMetadataFileRegex = regexp.MustCompile(`^v?(\d+)[-.].*metadata\.json(\.gz)?$`)
+	icebergSchemeRegex       = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9+.\-]*:/*`)
+)
+
+// icebergMetadata is the table metadata file of an Iceberg table.
+// See https://iceberg.apache.org/spec/#table-metadata
+type icebergMetadata struct {
+	FormatVersion     int                  `json:"format-version"`
+	Location          string               `json:"location"`
+	CurrentSnapshotID *int64               `json:"current-snapshot-id"`
+	Snapshots         []icebergSnapshot    `json:"snapshots"`
+	SnapshotLog       []icebergSnapshotLog `json:"snapshot-log"`
+}
+
+type icebergSnapshot struct {
+	SnapshotID   int64    `json:"snapshot-id"`
+	TimestampMs  int64    `json:"timestamp-ms"`
+	ManifestList string   `json:"manifest-list"`
+	Manifests    []string `json:"manifests"` // v1 tables without manifest list
+}
+
+type icebergSnapshotLog struct {
+	SnapshotID  int64 `json:"snapshot-id"`
+	TimestampMs int64 `json:"timestamp-ms"`
+}
+
+// icebergTable is an Iceberg table read from its metadata
+type icebergTable struct {
+	URL         string // location of the table on the file system
+	MetadataURL string
+	Metadata    icebergMetadata
+}
+
+// readIcebergTable reads the metadata of the Iceberg table at the provided URL.
+// The URL can be the table location, or a specific metadata JSON file.
+// The current metadata is determined from `version-hint.text` if present,
+// else the metadata file with the highest version is used.
+func readIcebergTable(fs FileSysClient, url string) (table *icebergTable, err error) {
+	url = strings.TrimSuffix(url, "/")
+	table = &icebergTable{URL: url}
+
+	if icebergMetadataFileRegex.MatchString(path.Base(url)) {
+		table.MetadataURL = url
+		table.URL = strings.TrimSuffix(strings.TrimSuffix(url, "/"+path.Base(url)), "/metadata")
+	} else {
+		metadataFolderURL := url + "/metadata"
+		paths, err := fs.List(metadataFolderURL + "/")
+		if err != nil {
+			return nil, g.Error(err, "could not list iceberg metadata folder %s", metadataFolderURL)
+		}
+
+		// version hint from hadoop catalog
+		hintVersion := int64(-1)
+		for _, p := range paths {
+			if path.Base(p) == "version-hint.text" {
+				content, err := readAllFromURL(fs, p)
+				if err != nil {
+					return nil, g.Error(err, "could not read iceberg version hint")
+				}
+				hintVersion, _ = strconv.ParseInt(strings.TrimSpace(string(content)), 10, 64)
+			}
+		}
+
+		maxVersion := int64(-1)
+		for _, p := range paths {
+			matches := icebergMetadataFileRegex.FindStringSubmatch(path.Base(p))
+			if len(matches) < 2 {
+				continue
+			}
+			version, _ := strconv.ParseInt(matches[1], 10, 64)
+			if version == hintVersion && strings.HasPrefix(path.Base(p), "v") {
+				table.MetadataURL = p
+				break
+			} else if version > maxVersion {
+				maxVersion = version
+				table.MetadataURL = p
+			}
+		}
+
+		if table.MetadataURL == "" {
+			return nil, g.Error("no iceberg metadata file found in %s", metadataFolderURL)
+		}
+	}
+
+	g.Debug("reading iceberg metadata from %s", table.MetadataURL)
+	content, err := readAllFromURL(fs, table.MetadataURL)
+	if err != nil {
+		return nil, g.Error(err, "could not read iceberg metadata file")
+	}
+
+	if err = json.Unmarshal(content, &table.Metadata); err != nil {
+		return nil, g.Error(err, "could not parse iceberg metadata file")
+	}
+
+	return table, nil
+}
+
+// Snapshot returns the snapshot to read. If snapshotID is provided, the
+// matching snapshot is returned. If asOf is provided, the snapshot
+// current at that time is returned. Else the current snapshot is returned.
+func (t *icebergTable) Snapshot(snapshotID int64, asOf time.Time) (snapshot icebergSnapshot, err error) {
+	getSnapshot := func(id int64) (icebergSnapshot, error) {
+		for _, s := range t.Metadata.Snapshots {
+			if s.SnapshotID == id {
+				return s, nil
+			}
+		}
+		return icebergSnapshot{}, g.Error("iceberg snapshot %d not found", id)
+	}
+
+	switch {
+	case snapshotID != 0:
+		return getSnapshot(snapshotID)
+	case !asOf.IsZero():
+		// the snapshot log is the history of current snapshots
+		history := t.Metadata.SnapshotLog
+		if len(history) == 0 {
+			for _, s := range t.Metadata.Snapshots {
+				history = append(history, icebergSnapshotLog{SnapshotID: s.SnapshotID, TimestampMs: s.TimestampMs})
+			}
+		}
+
+		var current *icebergSnapshotLog
+		for i, entry := range history {
+			if entry.TimestampMs <= asOf.UnixMilli() && (current == nil || entry.TimestampMs >= current.TimestampMs) {
+				current = &history[i]
+			}
+		}
+
+		if current == nil {
+			return snapshot, g.Error("no iceberg snapshot found as of %s", asOf.Format(time.RFC3339))
+		}
+		return getSnapshot(current.SnapshotID)
+	case t.Metadata.CurrentSnapshotID == nil || *t.Metadata.CurrentSnapshotID == -1:
+		return snapshot, g.Error("iceberg table has no current snapshot")
+	}
+
+	return getSnapshot(*t.Metadata.CurrentSnapshotID)
+}
+
+// DataFiles returns the URLs of the live data files of the snapshot
+func (t *icebergTable) DataFiles(fs FileSysClient, snapshot icebergSnapshot) (paths []string, err error) {
+	manifestURLs := []string{}
+	for _, manifest := range snapshot.Manifests {
+		manifestURLs = append(manifestURLs, t.resolveURL(manifest))
+	}
+
+	if snapshot.ManifestList != "" {
+		records, err := readAvroRecords(fs, t.resolveURL(snapshot.ManifestList))
+		if err != nil {
+			return nil, g.Error(err, "could not read iceberg manifest list")
+		}
+		for _, rec := range records {
+			if content := cast.ToInt(avroValue(rec["content"])); content != 0 {
+				return nil, g.Error("iceberg row-level deletes are not supported (manifest %s)", avroValue(rec["manifest_path"]))
+			}
+			manifestURLs = append(manifestURLs, t.resolveURL(cast.ToString(avroValue(rec["manifest_path"]))))
+		}
+	}
+
+	for _, manifestURL := range manifestURLs {
+		entries, err := readAvroRecords(fs, manifestURL)
+		if err != nil {
+			return nil, g.Error(err, "could not read iceberg manifest %s", manifestURL)
+		}
+
+		for _, entry := range entries {
+			if cast.ToInt(avroValue(entry["status"])) == 2 {
+				continue // deleted
+			}
+
+			dataFile, ok := avroValue(entry["data_file"]).(map[string]any)
+			if !ok {
+				return nil, g.Error("invalid iceberg manifest entry in %s", manifestURL)
+			}
+
+			if content := cast.ToInt(avroValue(dataFile["content"])); content != 0 {
+				return nil, g.Error("iceberg row-level deletes are not supported (manifest %s)", manifestURL)
+			} else if format := cast.ToString(avroValue(dataFile["file_format"])); !strings.EqualFold(format, "parquet") {
+				return nil, g.Error("iceberg data file format %s is not supported, only parquet", format)
+			}
+
+			paths = append(paths, t.resolveURL(cast.ToString(avroValue(dataFile["file_path"]))))
+		}
+	}
+
+	return paths, nil
+}
+
+// resolveURL rebases a path under the table location in the metadata
+// onto the URL the table was read from, since the table may have been
+// moved or be accessed with a different scheme (e.g. s3a:// vs s3://)
+func (t *icebergTable) resolveURL(p string) string {
+	trimScheme := func(u string) string {
+		return strings.TrimLeft(icebergSchemeRegex.ReplaceAllString(u, ""), "/")
+	}
+
+	location := trimScheme(strings.TrimSuffix(t.Metadata.Location, "/"))
+	if location != "" && strings.HasPrefix(trimScheme(p), location+"/") {
+		return t.URL + strings.TrimPrefix(trimScheme(p), location)
+	}
+	return p
+}
+
+// readDataflowIceberg reads the data files of a snapshot of an Iceberg table.
+// The SNAPSHOT_ID and AS_OF props allow selecting a prior snapshot.
+func (fs *BaseFileSysClient) readDataflowIceberg(url string, cfg FileStreamConfig) (df *iop.Dataflow, err error) {
+	table, err := readIcebergTable(fs.Self(), url)
+	if err != nil {
+		return nil, g.Error(err, "could not read iceberg table at %s", url)
+	}
+
+	var asOf time.Time
+	if val := fs.GetProp("AS_OF"); val != "" {
+		if ms, err := strconv.ParseInt(val, 10, 64); err == nil {
+			asOf = time.UnixMilli(ms)
+		} else if asOf, err = cast.ToTimeE(val); err != nil {
+			return nil, g.Error(err, "invalid as_of timestamp: %s", val)
+		}
+	}
+
+	snapshot, err := table.Snapshot(cast.ToInt64(fs.GetProp("SNAPSHOT_ID")), asOf)
+	if err != nil {
+		return nil, g.Error(err, "could not determine iceberg snapshot")
+	}
+
+	paths, err := table.DataFiles(fs.Self(), snapshot)
+	if err != nil {
+		return nil, g.Error(err, "could not get data files of iceberg snapshot %d", snapshot.SnapshotID)
+	} else if len(paths) == 0 {
+		return nil, g.Error("iceberg snapshot %d has no data files", snapshot.SnapshotID)
+	}
+
+	g.Debug("reading %d data files from iceberg snapshot %d", len(paths), snapshot.SnapshotID)
+	fs.SetProp("FORMAT", string(FileTypeParquet)) // data files are read as parquet
+	df, err = GetDataflow(fs.Self(), paths, cfg)
+	if err != nil {
+		return df, g.Error(err, "error getting dataflow")
+	}
+
+	df.FsURL = url
+	return df, nil
+}
+
+func readAllFromURL(fs FileSysClient, url string) (content []byte, err error) {
+	reader, err := fs.GetReader(url)
+	if err != nil {
+		return nil, g.Error(err, "could not get reader for %s", url)
+	}
+
+	reader, err = iop.AutoDecompress(reader)
+	if err != nil {
+		return nil, g.Error(err, "could not decompress %s", url)
+	}
+
+	return io.ReadAll(reader)
+}
+
+// readAvroRecords reads all the records of an avro file
+func readAvroRecords(fs FileSysClient, url string) (records []map[string]any, err error) {
+	reader, err := fs.GetReader(url)
+	if err != nil {
+		return nil, g.Error(err, "could not get reader for %s", url)
+	}
+
+	ocf, err := goavro.NewOCFReader(reader)
+	if err != nil {
+		return nil, g.Error(err, "could not read avro file %s", url)
+	}
+
+	for ocf.Scan() {
+		datum, err := ocf.Read()
+		if err != nil {
+			return nil, g.Error(err, "could not read avro record in %s", url)
+		}
+		if rec, ok := datum.(map[string]any); ok {
+			records = append(records, rec)
+		}
+	}
+
+	return records, ocf.Err()
+}
+
+// avroValue unwraps a nullable primitive, which goavro decodes as a map of type to value
+func avroValue(val any) any {
+	if m, ok := val.(map[string]any); ok && len(m) == 1 {
+		for k, v := range m {
+			if g.In(k, "boolean", "int", "long", "float", "double", "bytes", "string") {
+				return v
+			}
+		}
+	}
+	return val
+}
@@ -0,0 +1,245 @@
+package filesys
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flarco/g"
+	"github.com/flarco/g/csv"
+	"github.com/samber/lo"
+	"github.com/slingdata-io/sling-cli/core/dbio"
+	"github.com/spf13/cast"
+)
+
+// SuccessMarkerName is the name of the marker file written once all files are written
+const SuccessMarkerName = "_SUCCESS"
+
+// Manifest lists the files written to a file target
+type Manifest struct {
+	URL       string          `json:"url"`
+	CreatedAt time.Time       `json:"created_at"`
+	Rows      int64           `json:"rows"` // -1 if unknown
+	Bytes     int64           `json:"bytes"`
+	Files     []ManifestEntry `json:"files"`
+}
+
+// ManifestEntry is a file listed in the manifest
+type ManifestEntry struct {
+	URL     string           `json:"url"`
+	Rows    int              `json:"rows"` // -1 if unknown
+	Bytes   int64            `json:"bytes"`
+	MD5     string           `json:"md5"`
+	SHA256  string           `json:"sha256"`
+	Columns []ManifestColumn `json:"columns"`
+}
+
+// ManifestColumn is a column of a file listed in the manifest
+type ManifestColumn struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// manifestWriter collects the written files, and writes the manifest and
+// success marker once all the files are written
+type manifestWriter struct {
+	fs            FileSysClient
+	url           string
+	singleFile    bool
+	format        FileType // json or csv, none if no manifest
+	successMarker bool
+	files         []FileReady
+	mux           sync.Mutex
+}
+
+// newManifestWriter returns a manifestWriter from the MANIFEST and SUCCESS_MARKER props.
+// It returns nil if neither is specified.
+func newManifestWriter(fs FileSysClient, url string, singleFile bool) (mw *manifestWriter, err error) {
+	mw = &manifestWriter{
+		fs:            fs,
+		url:           url,
+		singleFile:    singleFile && fs.FsType() != dbio.TypeFileAzure, // azure always writes parts
+		successMarker: cast.ToBool(fs.GetProp("SUCCESS_MARKER")),
+	}
+
+	switch format := strings.ToLower(fs.GetProp("MANIFEST")); format {
+	case "", "false":
+	case "true", "json":
+		mw.format = FileTypeJson
+	case "csv":
+		mw.format = FileTypeCsv
+	default:
+		return nil, g.Error("invalid manifest format: %s (expected json or csv)", format)
+	}
+
+	if mw.format == FileTypeNone && !mw.successMarker {
+		return nil, nil
+	}
+
+	return mw, nil
+}
+
+// manifestURL returns the manifest url. For a single file, the manifest
+// is written next to it, otherwise inside the folder.
+func (mw *manifestWriter) manifestURL() string {
+	if mw.singleFile {
+		return mw.url + ".manifest" + mw.format.Ext()
+	}
+	return mw.url + "/_manifest" + mw.format.Ext()
+}
+
+// markerURL returns the success marker url. For a single file, the marker
+// is written next to it (such as `file.csv._SUCCESS`), so that files
+// sharing a folder each have their own marker.
+func (mw *manifestWriter) markerURL() string {
+	if mw.singleFile {
+		return mw.url + "." + SuccessMarkerName
+	}
+	return mw.url + "/" + SuccessMarkerName
+}
+
+// Reset deletes the marker of the file from a previous write, so that consumers
+// do not pick up the file before it is completely written
+func (mw *manifestWriter) Reset() (err error) {
+	if mw == nil || !mw.successMarker || !mw.singleFile {
+		return nil // folders are deleted before writing
+	}
+	return Delete(mw.fs, mw.markerURL())
+}
+
+// HashReader returns a reader computing the MD5 and SHA-256 of the
+// file content as it is read, if a manifest is written
+func (mw *manifestWriter) HashReader(reader io.Reader) (io.Reader, func() (md5Sum, sha256Sum string)) {
+	if mw == nil || mw.format == FileTypeNone {
+		return reader, func() (string, string) { return "", "" }
+	}
+
+	md5Hash, sha256Hash := md5.New(), sha256.New()
+	sums := func() (string, string) {
+		return hexSum(md5Hash), hexSum(sha256Hash)
+	}
+	return io.TeeReader(reader, io.MultiWriter(md5Hash, sha256Hash)), sums
+}
+
+// Add collects a written file
+func (mw *manifestWriter) Add(file FileReady) {
+	if mw == nil {
+		return
+	}
+	mw.mux.Lock()
+	mw.files = append(mw.files, file)
+	mw.mux.Unlock()
+}
+
+// Finish writes the manifest, then the success marker
+func (mw *manifestWriter) Finish() (err error) {
+	if mw == nil {
+		return nil
+	}
+
+	if mw.format != FileTypeNone {
+		content, err := mw.Manifest().Content(mw.format)
+		if err != nil {
+			return g.Error(err, "could not generate manifest")
+		}
+
+		g.Debug("writing manifest to %s", mw.manifestURL())
+		if _, err = mw.fs.Write(mw.manifestURL(), bytes.NewReader(content)); err != nil {
+			return g.Error(err, "could not write manifest")
+		}
+	}
+
+	if mw.successMarker {
+		g.Debug("writing success marker to %s", mw.markerURL())
+		if _, err = mw.fs.Write(mw.markerURL(), bytes.NewReader([]byte{})); err != nil {
+			return g.Error(err, "could not write success marker")
+		}
+	}
+
+	return nil
+}
+
+// Manifest returns the manifest of the collected files, sorted by url
+func (mw *manifestWriter) Manifest() (m Manifest) {
+	m = Manifest{URL: mw.url, CreatedAt: time.Now().UTC(), Files: []ManifestEntry{}}
+
+	files := lo.Filter(mw.files, func(f FileReady, i int) bool { return f.URI != "" })
+	sort.Slice(files, func(i, j int) bool { return files[i].URI < files[j].URI })
+
+	for _, file := range files {
+		entry := ManifestEntry{
+			URL:     file.URI,
+			Rows:    file.Rows,
+			Bytes:   file.BytesW,
+			MD5:     file.MD5,
+			SHA256:  file.SHA256,
+			Columns: []ManifestColumn{},
+		}
+		for _, col := range file.Columns {
+			entry.Columns = append(entry.Columns, ManifestColumn{Name: col.Name, Type: string(col.Type)})
+		}
+
+		if file.Rows < 0 || m.Rows < 0 {
+			m.Rows = -1
+		} else {
+			m.Rows = m.Rows + int64(file.Rows)
+		}
+		m.Bytes = m.Bytes + file.BytesW
+		m.Files = append(m.Files, entry)
+	}
+
+	return m
+}
+
+// Content returns the manifest as JSON, or as CSV with a row per file
+func (m Manifest) Content(format FileType) ([]byte, error) {
+	if format == FileTypeJson {
+		return []byte(g.Pretty(m)), nil
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"url", "rows", "bytes", "md5", "sha256", "columns"})
+	for _, entry := range m.Files {
+		_, err := w.Write([]string{
+			entry.URL,
+			cast.ToString(entry.Rows),
+			cast.ToString(entry.Bytes),
+			entry.MD5,
+			entry.SHA256,
+			g.Marshal(entry.Columns),
+		})
+		if err != nil {
+			return nil, g.Error(err, "could not write manifest row")
+		}
+	}
+	w.Flush()
+
+	return buf.Bytes(), nil
+}
+
+func hexSum(h hash.Hash) string {
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// isSidecarFile returns true for the manifest and marker files, which are not data
+func isSidecarFile(uri string) bool {
+	name := path.Base(uri)
+	switch {
+	case name == SuccessMarkerName, strings.HasSuffix(name, "."+SuccessMarkerName):
+		return true
+	case name == "_manifest"+FileTypeJson.Ext(), name == "_manifest"+FileTypeCsv.Ext():
+		return true
+	case strings.HasSuffix(name, ".manifest"+FileTypeJson.Ext()), strings.HasSuffix(name, ".manifest"+FileTypeCsv.Ext()):
+		return true
+	}
+	return false
+}
@@ -9,11 +9,13 @@ import (
 	"os"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/slingdata-io/sling-cli/core/dbio/iop"
 
 	"github.com/360EntSecGroup-Skylar/excelize"
 	"github.com/flarco/g"
+	"github.com/samber/lo"
 	"github.com/spf13/cast"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
@@ -160,6 +162,12 @@ func (s *spreadsheet) makeDatasetInterf(rangeRows [][]interface{}) (data iop.Dat
 	return
 }
 
+// ExcelMaxRows is the maximum number of rows of an Excel sheet
+const ExcelMaxRows = 1048576
+
+// excelMaxCellChars is the maximum number of characters of an Excel cell
+const excelMaxCellChars = 32767
+
 // Excel represent an Excel object pointing to its file
 type Excel struct {
 	spreadsheet
@@ -168,6 +176,7 @@ type Excel struct {
 	Path       string
 	context    g.Context
 	sheetIndex map[string]int
+	maxRows    int
 }
 
 // GoogleSheet represent a Google Sheet object
@@ -186,8 +195,10 @@ func NewExcel() (xls *Excel) {
 	xls = &Excel{
 		File:    excelize.NewFile(),
 		context: g.NewContext(context.Background()),
+		maxRows: ExcelMaxRows,
 	}
 	xls.spreadsheet.Props = map[string]string{}
+	xls.RefreshSheets()
 
 	return
 }
@@ -411,6 +422,166 @@ func (xls *Excel) WriteSheet(shtName string, ds *iop.Datastream, mode string) (e
 	return
 }
 
+// WriteStream writes a datastream into new sheets, with a styled header row
+// and typed cells (numbers, booleans and dates are not written as strings).
+// A new sheet is started once the Excel row limit is reached, or when the
+// columns change (so that the rows always match the header).
+// Returns the names of the sheets written.
+func (xls *Excel) WriteStream(shtName string, ds *iop.Datastream, header bool) (sheetNames []string, err error) {
+	headerStyle, err := xls.File.NewStyle(`{"font":{"bold":true},"fill":{"type":"pattern","color":["#D9D9D9"],"pattern":1},"border":[{"type":"bottom","color":"#808080","style":1}]}`)
+	if err != nil {
+		return nil, g.Error(err, "could not create header style")
+	}
+	dateStyle, err := xls.File.NewStyle(`{"custom_number_format": "yyyy-mm-dd"}`)
+	if err != nil {
+		return nil, g.Error(err, "could not create date style")
+	}
+	datetimeStyle, err := xls.File.NewStyle(`{"custom_number_format": "yyyy-mm-dd hh:mm:ss"}`)
+	if err != nil {
+		return nil, g.Error(err, "could not create datetime style")
+	}
+
+	maxRows := lo.Ternary(xls.maxRows > 0, xls.maxRows, ExcelMaxRows)
+	if header && maxRows < 2 {
+		return nil, g.Error("max rows per sheet must be greater than 1 when writing a header")
+	}
+
+	var columns iop.Columns
+	var sheet string
+	i := 0 // current row number in sheet
+
+	writeHeader := func() {
+		if !header {
+			return
+		}
+		for c, col := range columns {
+			axis := g.F("%s1", excelize.ToAlphaString(c))
+			xls.File.SetCellStr(sheet, axis, col.Name)
+		}
+		if len(columns) > 0 {
+			xls.File.SetCellStyle(sheet, "A1", g.F("%s1", excelize.ToAlphaString(len(columns)-1)), headerStyle)
+			xls.File.SetPanes(sheet, `{"freeze":true,"split":false,"x_split":0,"y_split":1,"top_left_cell":"A2","active_pane":"bottomLeft","panes":[{"sqref":"A2","active_cell":"A2","pane":"bottomLeft"}]}`)
+		}
+	}
+
+	nextSheet := func() {
+		name := shtName
+		if len(sheetNames) > 0 {
+			suffix := g.F("_%d", len(sheetNames)+1)
+			name = ExcelSheetName(shtName, len(suffix)) + suffix
+		}
+		sheet = xls.getBlankSheet(name)
+		sheetNames = append(sheetNames, sheet)
+		i = 0
+		writeHeader()
+		if header {
+			i = 1
+		}
+	}
+
+	for batch := range ds.BatchChan {
+		if batch.ColumnsChanged() || batch.IsFirst() {
+			columns = batch.Columns
+			if sheet == "" || i > lo.Ternary(header, 1, 0) {
+				nextSheet()
+			} else {
+				writeHeader() // no rows written yet, only the header changes
+			}
+		}
+
+		for row := range batch.Rows {
+			if i >= maxRows {
+				nextSheet()
+			}
+			i++
+
+			for c, val := range row {
+				if val == nil || c >= len(columns) {
+					continue
+				}
+
+				axis := g.F("%s%d", excelize.ToAlphaString(c), i)
+				colType := columns[c].Type
+				switch {
+				case colType.IsDatetime() || colType.IsDate():
+					if t, ok := val.(time.Time); ok {
+						// set style before value so that the default time style is not applied
+						xls.File.SetCellStyle(sheet, axis, axis, lo.Ternary(colType.IsDate(), dateStyle, datetimeStyle))
+						xls.File.SetCellValue(sheet, axis, t)
+						continue
+					}
+				case colType.IsBool():
+					if b, err := cast.ToBoolE(val); err == nil {
+						xls.File.SetCellBool(sheet, axis, b)
+						continue
+					}
+				case colType.IsInteger():
+					if n, err := cast.ToInt64E(val); err == nil {
+						xls.File.SetCellValue(sheet, axis, n)
+						continue
+					}
+				case colType.IsNumber():
+					if f, err := cast.ToFloat64E(val); err == nil {
+						xls.File.SetCellValue(sheet, axis, f)
+						continue
+					}
+				}
+
+				str := ds.Sp.CastToString(c, val, colType)
+				if len(str) > excelMaxCellChars {
+					str = str[:excelMaxCellChars]
+				}
+				xls.File.SetCellStr(sheet, axis, str)
+			}
+		}
+	}
+
+	if sheet == "" {
+		// no batches, create sheet with header only
+		columns = ds.Columns
+		nextSheet()
+	}
+
+	xls.RefreshSheets()
+
+	return sheetNames, ds.Err()
+}
+
+// getBlankSheet returns the provided sheet if it exists and is empty.
+// Otherwise a new sheet is created with a unique name.
+func (xls *Excel) getBlankSheet(shtName string) string {
+	if _, ok := xls.sheetIndex[shtName]; ok && len(xls.File.GetRows(shtName)) == 0 {
+		return shtName
+	}
+
+	// reuse the default blank sheet of a new workbook
+	if len(xls.Sheets) == 1 && xls.Sheets[0] == "Sheet1" && len(xls.File.GetRows("Sheet1")) == 0 {
+		xls.File.SetSheetName("Sheet1", shtName)
+		xls.RefreshSheets()
+		return shtName
+	}
+	return xls.createSheet(shtName)
+}
+
+// ExcelSheetName cleans up a name to be a valid Excel sheet name.
+// Sheet names cannot contain `[]:*?/\` and are limited to 31 characters.
+// reserve is the number of characters to keep free (for a suffix).
+func ExcelSheetName(name string, reserve ...int) string {
+	name = strings.TrimSpace(regexp.MustCompile(`[\[\]:*?/\\]`).ReplaceAllString(name, "_"))
+	if name == "" {
+		name = "Sheet1"
+	}
+
+	maxLen := 31
+	if len(reserve) > 0 {
+		maxLen = maxLen - reserve[0]
+	}
+	if runes := []rune(name); len(runes) > maxLen {
+		name = string(runes[:maxLen])
+	}
+	return name
+}
+
 // NewGoogleSheet is a blank spreadsheet
 // title is the new spreadsheet title
 func NewGoogleSheet(props ...string) (ggs *GoogleSheet, err error) {
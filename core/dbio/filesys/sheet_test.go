@@ -3,12 +3,15 @@ package filesys
 import (
 	"bufio"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/slingdata-io/sling-cli/core/dbio"
 	"github.com/slingdata-io/sling-cli/core/dbio/iop"
 
 	"github.com/flarco/g"
+	"github.com/spf13/cast"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -99,6 +102,67 @@ func TestExcel(t *testing.T) {
 	os.RemoveAll("test/test.excel6.xlsx")
 }
 
+func TestExcelWriteStream(t *testing.T) {
+	t.Parallel()
+
+	columns := iop.NewColumns(
+		iop.Columns{
+			{Name: "id", Type: iop.BigIntType},
+			{Name: "name", Type: iop.StringType},
+			{Name: "amount", Type: iop.DecimalType},
+			{Name: "active", Type: iop.BoolType},
+			{Name: "created_at", Type: iop.DatetimeType},
+		}...,
+	)
+	data := iop.NewDataset(columns)
+	data.Inferred = true
+	for i := 1; i <= 5; i++ {
+		data.Append([]any{int64(i), g.F("name %d", i), 10.5 * float64(i), i%2 == 0, time.Date(2023, 1, i, 10, 0, 0, 0, time.UTC)})
+	}
+
+	// roll over to new sheets
+	xls := NewExcel()
+	xls.maxRows = 3
+	sheetNames, err := xls.WriteStream("orders", data.Stream(), true)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"orders", "orders_2", "orders_3"}, sheetNames)
+
+	rows := xls.File.GetRows("orders")
+	if assert.Len(t, rows, 3) {
+		assert.Equal(t, []string{"id", "name", "amount", "active", "created_at"}, rows[0])
+		assert.Equal(t, "1", rows[1][0])
+		assert.Equal(t, "10.5", rows[1][2])
+		assert.Equal(t, "1", rows[2][3])     // boolean cell
+		_, err = cast.ToFloat64E(rows[1][4]) // dates are stored as excel serial numbers
+		assert.NoError(t, err)
+	}
+	assert.Len(t, xls.File.GetRows("orders_3"), 2)
+	assert.NotEqual(t, 0, xls.File.GetCellStyle("orders", "A1"))
+
+	// write dataflow to file
+	localFs, err := NewFileSysClient(dbio.TypeFileLocal, "SHEET=my/orders")
+	assert.NoError(t, err)
+
+	df, err := iop.MakeDataFlow(data.Stream())
+	assert.NoError(t, err)
+	_, err = localFs.WriteDataflow(df, "test/test_write_excel/orders.xlsx")
+	assert.NoError(t, err)
+
+	xls2, err := NewExcelFromFile("test/test_write_excel/orders.xlsx")
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"my_orders"}, xls2.Sheets)
+		data2 := xls2.GetDataset("my_orders")
+		assert.Len(t, data2.Rows, 5)
+		assert.Len(t, data2.Columns, 5)
+	}
+
+	assert.Equal(t, "a_b_c", ExcelSheetName("a[b]c"))
+	assert.Len(t, ExcelSheetName(strings.Repeat("x", 40)), 31)
+	assert.Len(t, ExcelSheetName(strings.Repeat("x", 40), 3), 28)
+
+	os.RemoveAll("test/test_write_excel")
+}
+
 func TestGoogleSheet(t *testing.T) {
 
 	url := "https://docs.google.com/spreadsheets/d/1Wo7d_2oiYpWy1hYGqHIy0DSPWki24Xif3FnlRjNGzo4/edit#gid=0"
@@ -0,0 +1,100 @@
+package filesys
+
+import (
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/flarco/g"
+	"github.com/slingdata-io/sling-cli/core/dbio/env"
+	"github.com/slingdata-io/sling-cli/core/dbio/iop"
+	"github.com/spf13/cast"
+)
+
+// unionColumns pre-scans the schema of every file, and returns the unified
+// columns, matched by name with widened types. The columns of Parquet and
+// Avro files are read from their footer or header, while the columns of CSV
+// and JSON files are inferred from a sample of rows. No stream is kept open,
+// the files are read again when streamed.
+func unionColumns(fs FileSysClient, paths []string) (columns iop.Columns, err error) {
+	for _, path := range paths {
+		if strings.HasSuffix(path, "/") {
+			continue
+		}
+
+		cols, err := scanColumns(fs, path)
+		if err != nil {
+			return nil, g.Error(err, "could not scan schema of %s", path)
+		}
+		g.Trace("scanned columns of %s: %s", path, g.Marshal(cols.Types()))
+
+		columns = columns.Union(cols)
+	}
+
+	g.Debug("using union of %d columns from %d files", len(columns), len(paths))
+
+	return columns, nil
+}
+
+// scanColumns returns the columns of the file, from its schema when the
+// format has one, otherwise from the first rows sampled
+func scanColumns(fs FileSysClient, uri string) (columns iop.Columns, err error) {
+	fileFormat := FileType(strings.ToLower(cast.ToString(fs.GetProp("FORMAT"))))
+	if fileFormat == FileTypeNone {
+		fileFormat = InferFileFormat(uri)
+	}
+
+	switch fileFormat {
+	case FileTypeParquet:
+		if fs.FsType() == dbio.TypeFileLocal && fs.GetProp("ENCRYPTION") == "" {
+			file, err := os.Open(cleanLocalFilePath(uri))
+			if err != nil {
+				return nil, g.Error(err, "Unable to open "+uri)
+			}
+			return iop.ParquetArrowColumns(file)
+		}
+
+		reader, err := getReader(fs.Self(), uri)
+		if err != nil {
+			return nil, g.Error(err, "could not get reader")
+		}
+
+		// the footer is at the end, so other files are downloaded first
+		tempPath := path.Join(env.GetTempFolder(), g.NewTsID("parquet.temp")+".parquet")
+		defer os.Remove(tempPath)
+
+		file, err := os.Create(tempPath)
+		if err != nil {
+			return nil, g.Error(err, "Unable to create temp file: "+tempPath)
+		} else if _, err = io.Copy(file, reader); err != nil {
+			file.Close()
+			return nil, g.Error(err, "Unable to write to temp file: "+tempPath)
+		}
+
+		return iop.ParquetArrowColumns(file)
+
+	case FileTypeAvro:
+		reader, err := getReader(fs.Self(), uri)
+		if err != nil {
+			return nil, g.Error(err, "could not get reader")
+		}
+		if closer, ok := reader.(io.Closer); ok {
+			defer closer.Close()
+		}
+
+		return iop.AvroColumns(reader)
+	}
+
+	ds, err := fs.Self().GetDatastream(uri)
+	if err != nil {
+		return nil, g.Error(err, "could not read stream")
+	}
+	defer ds.Context.Cancel() // only the sample is needed
+
+	if err = ds.WaitReady(); err != nil {
+		return nil, g.Error(err, "could not infer columns")
+	}
+
+	return ds.Columns, nil
+}
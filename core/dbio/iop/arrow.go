@@ -0,0 +1,490 @@
+package iop
+
+import (
+	"io"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/apache/arrow/go/v16/arrow"
+	"github.com/apache/arrow/go/v16/arrow/array"
+	"github.com/apache/arrow/go/v16/arrow/decimal128"
+	"github.com/apache/arrow/go/v16/arrow/ipc"
+	"github.com/apache/arrow/go/v16/arrow/memory"
+	"github.com/flarco/g"
+	"github.com/samber/lo"
+	"github.com/spf13/cast"
+)
+
+// arrowFileMagic is the signature at the start of an Arrow IPC file (Feather V2)
+const arrowFileMagic = "ARROW1"
+
+// arrowBatchSize is the number of rows per record batch when writing
+var arrowBatchSize = 10000
+
+// ArrowReader is an Arrow IPC reader object. It reads both
+// the streaming format and the file format (Feather V2).
+type ArrowReader struct {
+	Schema *arrow.Schema
+
+	columns    Columns
+	nextRecord func() (arrow.Record, error)
+	record     arrow.Record
+	rowIndex   int
+}
+
+// NewArrowReader creates a new Arrow IPC reader. If the reader is seekable
+// and starts with the file signature, the file format is used. Otherwise
+// the streaming format is assumed.
+func NewArrowReader(reader io.Reader) (a *ArrowReader, err error) {
+	a = &ArrowReader{}
+
+	if ras, ok := reader.(ipc.ReadAtSeeker); ok {
+		magic := make([]byte, len(arrowFileMagic))
+		if n, _ := ras.ReadAt(magic, 0); n == len(magic) && string(magic) == arrowFileMagic {
+			fr, err := ipc.NewFileReader(ras)
+			if err != nil {
+				return nil, g.Error(err, "could not open arrow file reader")
+			}
+			a.Schema = fr.Schema()
+			a.nextRecord = fr.Read
+			a.columns = NewColumnsFromArrowSchema(a.Schema)
+			return a, nil
+		}
+	}
+
+	sr, err := ipc.NewReader(reader)
+	if err != nil {
+		return nil, g.Error(err, "could not open arrow stream reader")
+	}
+
+	a.Schema = sr.Schema()
+	a.nextRecord = func() (arrow.Record, error) {
+		if sr.Next() {
+			return sr.Record(), nil
+		} else if err := sr.Err(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+	a.columns = NewColumnsFromArrowSchema(a.Schema)
+
+	return a, nil
+}
+
+// Columns returns the columns mapped from the arrow schema
+func (a *ArrowReader) Columns() Columns {
+	return a.columns
+}
+
+func (a *ArrowReader) nextFunc(it *Iterator) bool {
+	// recover from panic
+	defer func() {
+		if r := recover(); r != nil {
+			g.Warn("recovered from panic: %#v\n%s", r, string(debug.Stack()))
+			err := g.Error("panic occurred! %#v", r)
+			it.Context.CaptureErr(err)
+		}
+	}()
+
+	for a.record == nil || a.rowIndex >= int(a.record.NumRows()) {
+		record, err := a.nextRecord()
+		if err == io.EOF {
+			return false
+		} else if err != nil {
+			it.Context.CaptureErr(g.Error(err, "could not read arrow record"))
+			return false
+		}
+		a.record = record
+		a.rowIndex = 0
+	}
+
+	row := make([]any, a.record.NumCols())
+	for i, arr := range a.record.Columns() {
+		row[i] = arrowValue(arr, a.rowIndex)
+	}
+	it.Row = row
+	a.rowIndex++
+
+	return true
+}
+
+// arrowValue returns the go value at index i of the array
+func arrowValue(arr arrow.Array, i int) any {
+	if arr.IsNull(i) {
+		return nil
+	}
+
+	switch a := arr.(type) {
+	case *array.Boolean:
+		return a.Value(i)
+	case *array.Int8:
+		return int64(a.Value(i))
+	case *array.Int16:
+		return int64(a.Value(i))
+	case *array.Int32:
+		return int64(a.Value(i))
+	case *array.Int64:
+		return a.Value(i)
+	case *array.Uint8:
+		return int64(a.Value(i))
+	case *array.Uint16:
+		return int64(a.Value(i))
+	case *array.Uint32:
+		return int64(a.Value(i))
+	case *array.Uint64:
+		return a.Value(i)
+	case *array.Float16:
+		return float64(a.Value(i).Float32())
+	case *array.Float32:
+		return float64(a.Value(i))
+	case *array.Float64:
+		return a.Value(i)
+	case *array.Decimal128:
+		return a.Value(i).ToString(a.DataType().(*arrow.Decimal128Type).Scale)
+	case *array.Decimal256:
+		return a.Value(i).ToString(a.DataType().(*arrow.Decimal256Type).Scale)
+	case *array.String:
+		return strings.Clone(a.Value(i))
+	case *array.LargeString:
+		return strings.Clone(a.Value(i))
+	case *array.Binary:
+		return append([]byte{}, a.Value(i)...)
+	case *array.LargeBinary:
+		return append([]byte{}, a.Value(i)...)
+	case *array.FixedSizeBinary:
+		return append([]byte{}, a.Value(i)...)
+	case *array.Date32:
+		return a.Value(i).ToTime()
+	case *array.Date64:
+		return a.Value(i).ToTime()
+	case *array.Timestamp:
+		dt := a.DataType().(*arrow.TimestampType)
+		return a.Value(i).ToTime(dt.Unit)
+	case *array.Time32:
+		dt := a.DataType().(*arrow.Time32Type)
+		return a.Value(i).ToTime(dt.Unit).Format("15:04:05.999999999")
+	case *array.Time64:
+		dt := a.DataType().(*arrow.Time64Type)
+		return a.Value(i).ToTime(dt.Unit).Format("15:04:05.999999999")
+	}
+
+	// nested types (list, struct, map...) as json
+	return g.Marshal(arr.GetOneForMarshal(i))
+}
+
+// NewColumnsFromArrowSchema maps an arrow schema to columns
+func NewColumnsFromArrowSchema(schema *arrow.Schema) (cols Columns) {
+	cols = make(Columns, len(schema.Fields()))
+
+	for i, field := range schema.Fields() {
+		col := Column{
+			Name:     field.Name,
+			Position: i + 1,
+			DbType:   field.Type.String(),
+			Sourced:  true,
+			Metadata: map[string]string{"arrowType": field.Type.String()},
+		}
+
+		switch field.Type.ID() {
+		case arrow.BOOL:
+			col.Type = BoolType
+		case arrow.INT8, arrow.INT16, arrow.UINT8:
+			col.Type = SmallIntType
+		case arrow.INT32, arrow.UINT16:
+			col.Type = IntegerType
+		case arrow.INT64, arrow.UINT32, arrow.UINT64:
+			col.Type = BigIntType
+		case arrow.FLOAT16, arrow.FLOAT32, arrow.FLOAT64:
+			col.Type = FloatType
+		case arrow.DECIMAL128, arrow.DECIMAL256:
+			col.Type = DecimalType
+			if dt, ok := field.Type.(arrow.DecimalType); ok {
+				col.DbPrecision = cast.ToInt(dt.GetPrecision())
+				col.DbScale = cast.ToInt(dt.GetScale())
+			}
+		case arrow.STRING, arrow.LARGE_STRING:
+			col.Type = StringType
+		case arrow.BINARY, arrow.LARGE_BINARY, arrow.FIXED_SIZE_BINARY:
+			col.Type = BinaryType
+		case arrow.DATE32, arrow.DATE64:
+			col.Type = DateType
+		case arrow.TIMESTAMP:
+			col.Type = DatetimeType
+			if dt, ok := field.Type.(*arrow.TimestampType); ok {
+				if dt.TimeZone != "" {
+					col.Type = TimestampzType
+				}
+				col.DbPrecision = map[arrow.TimeUnit]int{
+					arrow.Second: 0, arrow.Millisecond: 3,
+					arrow.Microsecond: 6, arrow.Nanosecond: 9,
+				}[dt.Unit]
+			}
+		case arrow.TIME32, arrow.TIME64:
+			col.Type = TimeType
+		case arrow.LIST, arrow.LARGE_LIST, arrow.FIXED_SIZE_LIST, arrow.STRUCT, arrow.MAP:
+			col.Type = JsonType
+		default:
+			col.Type = StringType
+		}
+
+		cols[i] = col
+	}
+
+	return cols
+}
+
+// ArrowSchema maps the columns to an arrow schema
+func (cols Columns) ArrowSchema() *arrow.Schema {
+	fields := make([]arrow.Field, len(cols))
+
+	for i, col := range cols {
+		var dt arrow.DataType
+
+		switch {
+		case col.Type.IsBool():
+			dt = arrow.FixedWidthTypes.Boolean
+		case col.Type.IsInteger():
+			dt = arrow.PrimitiveTypes.Int64
+		case col.Type == FloatType:
+			dt = arrow.PrimitiveTypes.Float64
+		case col.Type == DecimalType:
+			precision, scale := col.DbPrecision, col.DbScale
+			if !col.Sourced || precision == 0 {
+				precision = lo.Ternary(precision == 0, 28, precision)
+				scale = lo.Ternary(scale == 0, 9, scale)
+			}
+			precision = lo.Ternary(precision > 38, 38, precision)
+			scale = lo.Ternary(scale > precision, precision, scale)
+			dt = &arrow.Decimal128Type{Precision: int32(precision), Scale: int32(scale)}
+		case col.Type.IsDate():
+			dt = arrow.FixedWidthTypes.Date32
+		case col.Type.IsDatetime():
+			unit := arrow.Microsecond
+			switch col.DbPrecision {
+			case 1, 2, 3:
+				unit = arrow.Millisecond
+			case 7, 8, 9:
+				unit = arrow.Nanosecond
+			}
+			dt = &arrow.TimestampType{Unit: unit, TimeZone: lo.Ternary(col.Type == TimestampzType, "UTC", "")}
+		case col.Type == BinaryType:
+			dt = arrow.BinaryTypes.Binary
+		default:
+			dt = arrow.BinaryTypes.String
+		}
+
+		fields[i] = arrow.Field{Name: col.Name, Type: dt, Nullable: true}
+	}
+
+	return arrow.NewSchema(fields, nil)
+}
+
+// arrowRecordWriter is either an IPC stream writer or file writer
+type arrowRecordWriter interface {
+	Write(rec arrow.Record) error
+	Close() error
+}
+
+// arrowPosWriter tracks the position of the underlying writer,
+// since the IPC file writer needs to know its current offset
+type arrowPosWriter struct {
+	w   io.Writer
+	pos int64
+}
+
+func (pw *arrowPosWriter) Write(p []byte) (n int, err error) {
+	n, err = pw.w.Write(p)
+	pw.pos += int64(n)
+	return
+}
+
+func (pw *arrowPosWriter) Seek(offset int64, whence int) (int64, error) {
+	if offset == 0 && whence == io.SeekCurrent {
+		return pw.pos, nil
+	}
+	return pw.pos, g.Error("arrow writer does not support seeking")
+}
+
+// ArrowWriter writes rows into the Arrow IPC stream or file (Feather V2) format
+type ArrowWriter struct {
+	columns Columns
+	schema  *arrow.Schema
+	builder *array.RecordBuilder
+	writer  arrowRecordWriter
+	posW    *arrowPosWriter
+	count   int
+}
+
+// NewArrowWriter creates a new Arrow writer. When fileFormat is true, the
+// IPC file format (Feather V2) is written, otherwise the streaming format.
+// ZSTD or LZ4 compression is applied internally to the record batches.
+func NewArrowWriter(w io.Writer, columns Columns, fileFormat bool, compression CompressorType) (aw *ArrowWriter, err error) {
+	aw = &ArrowWriter{
+		columns: columns,
+		schema:  columns.ArrowSchema(),
+		posW:    &arrowPosWriter{w: w},
+	}
+	aw.builder = array.NewRecordBuilder(memory.DefaultAllocator, aw.schema)
+
+	opts := []ipc.Option{ipc.WithSchema(aw.schema)}
+	switch compression {
+	case ZStandardCompressorType:
+		opts = append(opts, ipc.WithZstd())
+	case Lz4CompressorType:
+		opts = append(opts, ipc.WithLZ4())
+	}
+
+	if fileFormat {
+		aw.writer, err = ipc.NewFileWriter(aw.posW, opts...)
+		if err != nil {
+			return nil, g.Error(err, "could not create arrow file writer")
+		}
+	} else {
+		aw.writer = ipc.NewWriter(aw.posW, opts...)
+	}
+
+	return aw, nil
+}
+
+// BytesWritten returns the number of bytes written so far
+func (aw *ArrowWriter) BytesWritten() int64 {
+	return aw.posW.pos
+}
+
+// WriteRow appends a row, a record batch is written once the batch size is reached
+func (aw *ArrowWriter) WriteRow(row []any) (err error) {
+	for i, col := range aw.columns {
+		var val any
+		if i < len(row) {
+			val = row[i]
+		}
+
+		err = appendArrowValue(aw.builder.Field(i), val)
+		if err != nil {
+			return g.Error(err, "could not append value for column %s", col.Name)
+		}
+	}
+
+	aw.count++
+	if aw.count >= arrowBatchSize {
+		return aw.flush()
+	}
+
+	return nil
+}
+
+func (aw *ArrowWriter) flush() (err error) {
+	if aw.count == 0 {
+		return nil
+	}
+
+	rec := aw.builder.NewRecord()
+	defer rec.Release()
+	aw.count = 0
+
+	err = aw.writer.Write(rec)
+	if err != nil {
+		return g.Error(err, "could not write arrow record")
+	}
+	return nil
+}
+
+// Close flushes the remaining rows and closes the writer
+func (aw *ArrowWriter) Close() (err error) {
+	defer aw.builder.Release()
+
+	err = aw.flush()
+	if err != nil {
+		return err
+	}
+
+	err = aw.writer.Close()
+	if err != nil {
+		return g.Error(err, "could not close arrow writer")
+	}
+	return nil
+}
+
+// appendArrowValue appends the value to the provided builder
+func appendArrowValue(builder array.Builder, val any) (err error) {
+	if val == nil {
+		builder.AppendNull()
+		return nil
+	}
+
+	switch b := builder.(type) {
+	case *array.BooleanBuilder:
+		v, err := cast.ToBoolE(val)
+		if err != nil {
+			return err
+		}
+		b.Append(v)
+	case *array.Int64Builder:
+		v, err := cast.ToInt64E(val)
+		if err != nil {
+			return err
+		}
+		b.Append(v)
+	case *array.Float64Builder:
+		v, err := cast.ToFloat64E(val)
+		if err != nil {
+			return err
+		}
+		b.Append(v)
+	case *array.Decimal128Builder:
+		dt := b.Type().(*arrow.Decimal128Type)
+		v, err := decimal128.FromString(cast.ToString(val), dt.Precision, dt.Scale)
+		if err != nil {
+			return g.Error(err, "could not convert decimal %#v", val)
+		}
+		b.Append(v)
+	case *array.Date32Builder:
+		t, err := arrowTime(val)
+		if err != nil {
+			return err
+		}
+		b.Append(arrow.Date32FromTime(t))
+	case *array.TimestampBuilder:
+		t, err := arrowTime(val)
+		if err != nil {
+			return err
+		}
+		v, err := arrow.TimestampFromTime(t, b.Type().(*arrow.TimestampType).Unit)
+		if err != nil {
+			return err
+		}
+		b.Append(v)
+	case *array.BinaryBuilder:
+		switch v := val.(type) {
+		case []byte:
+			b.Append(v)
+		default:
+			b.Append([]byte(cast.ToString(v)))
+		}
+	case *array.StringBuilder:
+		switch v := val.(type) {
+		case string:
+			b.Append(v)
+		case []byte:
+			b.Append(string(v))
+		case time.Time:
+			b.Append(v.Format(time.RFC3339Nano))
+		case map[string]any, []any:
+			b.Append(g.Marshal(v))
+		default:
+			b.Append(cast.ToString(v))
+		}
+	default:
+		return g.Error("unhandled arrow builder type: %T", builder)
+	}
+
+	return nil
+}
+
+func arrowTime(val any) (t time.Time, err error) {
+	if t, ok := val.(time.Time); ok {
+		return t, nil
+	}
+	return cast.ToTimeE(val)
+}
@@ -0,0 +1,109 @@
+package iop
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow/go/v16/arrow"
+	"github.com/samber/lo"
+	"github.com/spf13/cast"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArrowSchema(t *testing.T) {
+	columns := NewColumns(
+		Columns{
+			{Name: "col_bool", Type: BoolType},
+			{Name: "col_bigint", Type: BigIntType},
+			{Name: "col_float", Type: FloatType},
+			{Name: "col_decimal", Type: DecimalType, DbPrecision: 10, DbScale: 2, Sourced: true},
+			{Name: "col_string", Type: StringType},
+			{Name: "col_binary", Type: BinaryType},
+			{Name: "col_date", Type: DateType},
+			{Name: "col_datetime", Type: DatetimeType},
+			{Name: "col_timestampz", Type: TimestampzType},
+		}...,
+	)
+
+	schema := columns.ArrowSchema()
+	assert.Equal(t, arrow.BOOL, schema.Field(0).Type.ID())
+	assert.Equal(t, arrow.INT64, schema.Field(1).Type.ID())
+	assert.Equal(t, arrow.FLOAT64, schema.Field(2).Type.ID())
+	assert.Equal(t, &arrow.Decimal128Type{Precision: 10, Scale: 2}, schema.Field(3).Type)
+	assert.Equal(t, arrow.STRING, schema.Field(4).Type.ID())
+	assert.Equal(t, arrow.BINARY, schema.Field(5).Type.ID())
+	assert.Equal(t, arrow.DATE32, schema.Field(6).Type.ID())
+	assert.Equal(t, &arrow.TimestampType{Unit: arrow.Microsecond}, schema.Field(7).Type)
+	assert.Equal(t, &arrow.TimestampType{Unit: arrow.Microsecond, TimeZone: "UTC"}, schema.Field(8).Type)
+
+	// and back
+	columns2 := NewColumnsFromArrowSchema(schema)
+	assert.Equal(t, columns.Names(), columns2.Names())
+	for i, col := range columns2 {
+		assert.Equal(t, columns[i].Type, col.Type, col.Name)
+	}
+	assert.Equal(t, 10, columns2[3].DbPrecision)
+	assert.Equal(t, 2, columns2[3].DbScale)
+}
+
+func TestArrowReadWrite(t *testing.T) {
+	columns := NewColumns(
+		Columns{
+			{Name: "id", Type: BigIntType},
+			{Name: "name", Type: StringType},
+			{Name: "amount", Type: DecimalType, DbPrecision: 10, DbScale: 2, Sourced: true},
+			{Name: "rating", Type: FloatType},
+			{Name: "active", Type: BoolType},
+			{Name: "created_at", Type: DatetimeType},
+		}...,
+	)
+
+	ts := time.Date(2023, 5, 1, 12, 30, 0, 0, time.UTC)
+	rows := [][]any{
+		{int64(1), "alpha", "12.34", 1.5, true, ts},
+		{int64(2), nil, "-0.50", nil, false, nil},
+		{int64(3), "gamma", nil, 3.25, nil, ts.Add(time.Hour)},
+	}
+
+	for i, fileFormat := range []bool{false, true, false, true} {
+		// use a small batch size to write multiple record batches
+		arrowBatchSize = 2
+		compression := lo.Ternary(i < 2, NoneCompressorType, Lz4CompressorType)
+
+		buf := &bytes.Buffer{}
+		aw, err := NewArrowWriter(buf, columns, fileFormat, compression)
+		if !assert.NoError(t, err) {
+			return
+		}
+		for _, row := range rows {
+			assert.NoError(t, aw.WriteRow(row))
+		}
+		assert.NoError(t, aw.Close())
+		assert.Equal(t, int64(buf.Len()), aw.BytesWritten())
+		assert.Equal(t, fileFormat, bytes.HasPrefix(buf.Bytes(), []byte(arrowFileMagic)))
+
+		ds := NewDatastream(nil)
+		err = ds.ConsumeArrowReader(bytes.NewReader(buf.Bytes()))
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		data, err := ds.Collect(0)
+		assert.NoError(t, err)
+		assert.Len(t, data.Rows, 3)
+		assert.Equal(t, columns.Names(), data.Columns.Names())
+		if len(data.Rows) == 3 {
+			assert.EqualValues(t, 1, data.Rows[0][0])
+			assert.Equal(t, "alpha", data.Rows[0][1])
+			assert.Equal(t, "12.34", cast.ToString(data.Rows[0][2]))
+			assert.Equal(t, 1.5, cast.ToFloat64(data.Rows[0][3]))
+			assert.Equal(t, true, cast.ToBool(data.Rows[0][4]))
+			assert.Equal(t, ts, data.Rows[0][5].(time.Time).UTC())
+			assert.Nil(t, data.Rows[1][1])
+			assert.Nil(t, data.Rows[1][5])
+			assert.Nil(t, data.Rows[2][2])
+		}
+	}
+	arrowBatchSize = 10000
+}
@@ -33,6 +33,18 @@ func NewAvroStream(reader io.ReadSeeker, columns Columns) (a *Avro, err error) {
 	return
 }
 
+// AvroColumns returns the columns of the avro file,
+// read from its header without reading any records
+func AvroColumns(reader io.Reader) (columns Columns, err error) {
+	ar, err := goavro.NewOCFReader(reader)
+	if err != nil {
+		return nil, g.Error(err, "could not read avro reader")
+	}
+
+	a := &Avro{Reader: ar, codec: ar.Codec()}
+	return a.Columns(), nil
+}
+
 func (a *Avro) Columns() Columns {
 
 	typeMap := map[string]ColumnType{
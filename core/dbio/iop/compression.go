@@ -3,15 +3,20 @@ package iop
 import (
 	"archive/zip"
 	"bufio"
+	"bytes"
+	"compress/bzip2"
 	"compress/gzip"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
 
+	"github.com/andybalholm/brotli"
 	"github.com/flarco/g"
 	"github.com/klauspost/compress/s2"
 	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+	"github.com/ulikunitz/xz"
 )
 
 // Compressor implements differnt kind of compression
@@ -38,6 +43,14 @@ const (
 	SnappyCompressorType CompressorType = "SNAPPY"
 	// ZStandardCompressorType is for ZStandard
 	ZStandardCompressorType CompressorType = "ZSTD"
+	// Bzip2CompressorType is for Bzip2 decompression
+	Bzip2CompressorType CompressorType = "BZIP2"
+	// Lz4CompressorType is for LZ4 compression
+	Lz4CompressorType CompressorType = "LZ4"
+	// XzCompressorType is for XZ compression
+	XzCompressorType CompressorType = "XZ"
+	// BrotliCompressorType is for Brotli compression
+	BrotliCompressorType CompressorType = "BROTLI"
 )
 
 // CompressorTypePtr returns a pointer to the CompressorType value passed in.
@@ -114,6 +127,14 @@ func NewCompressor(cpType CompressorType) Compressor {
 		compressor = &SnappyCompressor{cpType: cpType, suffix: ".snappy"}
 	case ZStandardCompressorType:
 		compressor = &ZStandardCompressor{cpType: cpType, suffix: ".zst"}
+	case Bzip2CompressorType:
+		compressor = &Bzip2Compressor{cpType: cpType, suffix: ".bz2"}
+	case Lz4CompressorType:
+		compressor = &Lz4Compressor{cpType: cpType, suffix: ".lz4"}
+	case XzCompressorType:
+		compressor = &XzCompressor{cpType: cpType, suffix: ".xz"}
+	case BrotliCompressorType:
+		compressor = &BrotliCompressor{cpType: cpType, suffix: ".br"}
 	default:
 		compressor = &NoneCompressor{cpType: NoneCompressorType, suffix: ""}
 	}
@@ -258,26 +279,184 @@ func (cp *ZStandardCompressor) Suffix() string {
 	return cp.suffix
 }
 
+type Bzip2Compressor struct {
+	Compressor
+	cpType CompressorType
+	suffix string
+}
+
+// Compress is not supported for bzip2, the returned reader errors
+func (cp *Bzip2Compressor) Compress(reader io.Reader) io.Reader {
+	pr, pw := io.Pipe()
+	pw.CloseWithError(g.Error("bzip2 compression is not supported, only decompression"))
+	return pr
+}
+
+// Decompress uses bzip2 to decompress
+func (cp *Bzip2Compressor) Decompress(reader io.Reader) (bReader io.Reader, err error) {
+	return bzip2.NewReader(reader), nil
+}
+
+func (cp *Bzip2Compressor) Suffix() string {
+	return cp.suffix
+}
+
+type Lz4Compressor struct {
+	Compressor
+	cpType CompressorType
+	suffix string
+}
+
+// Compress uses lz4 to compress
+func (cp *Lz4Compressor) Compress(reader io.Reader) io.Reader {
+	pr, pw := io.Pipe()
+	w := lz4.NewWriter(pw)
+	go func() {
+		_, err := io.Copy(w, reader)
+		if err != nil {
+			g.LogError(g.Error(err, "could not compress stream with lz4"))
+		}
+		w.Close()
+		pw.Close()
+	}()
+
+	return pr
+}
+
+// Decompress uses lz4 to decompress
+func (cp *Lz4Compressor) Decompress(reader io.Reader) (lReader io.Reader, err error) {
+	return lz4.NewReader(reader), nil
+}
+
+func (cp *Lz4Compressor) Suffix() string {
+	return cp.suffix
+}
+
+type XzCompressor struct {
+	Compressor
+	cpType CompressorType
+	suffix string
+}
+
+// Compress uses xz to compress
+func (cp *XzCompressor) Compress(reader io.Reader) io.Reader {
+	pr, pw := io.Pipe()
+	go func() {
+		// the writer writes the stream header on creation
+		w, err := xz.NewWriter(pw)
+		if err != nil {
+			pw.CloseWithError(g.Error(err, "could not create xz writer"))
+			return
+		}
+
+		_, err = io.Copy(w, reader)
+		if err != nil {
+			g.LogError(g.Error(err, "could not compress stream with xz"))
+		}
+		w.Close()
+		pw.Close()
+	}()
+
+	return pr
+}
+
+// Decompress uses xz to decompress
+func (cp *XzCompressor) Decompress(reader io.Reader) (xReader io.Reader, err error) {
+	xReader, err = xz.NewReader(reader)
+	if err != nil {
+		return reader, g.Error(err, "Error using xz decompressor")
+	}
+
+	return xReader, nil
+}
+
+func (cp *XzCompressor) Suffix() string {
+	return cp.suffix
+}
+
+type BrotliCompressor struct {
+	Compressor
+	cpType CompressorType
+	suffix string
+}
+
+// Compress uses brotli to compress
+func (cp *BrotliCompressor) Compress(reader io.Reader) io.Reader {
+	pr, pw := io.Pipe()
+	w := brotli.NewWriterLevel(pw, brotli.BestSpeed)
+	go func() {
+		_, err := io.Copy(w, reader)
+		if err != nil {
+			g.LogError(g.Error(err, "could not compress stream with brotli"))
+		}
+		w.Close()
+		pw.Close()
+	}()
+
+	return pr
+}
+
+// Decompress uses brotli to decompress. Brotli streams have no magic
+// bytes, so they are not auto-detected
+func (cp *BrotliCompressor) Decompress(reader io.Reader) (bReader io.Reader, err error) {
+	return brotli.NewReader(reader), nil
+}
+
+func (cp *BrotliCompressor) Suffix() string {
+	return cp.suffix
+}
+
+// magic bytes of the compression formats which can be auto-detected
+var compressorMagicBytes = []struct {
+	cpType CompressorType
+	offset int
+	magic  []byte
+}{
+	{GzipCompressorType, 0, []byte{0x1F, 0x8B}},                          // https://stackoverflow.com/a/28332019
+	{ZStandardCompressorType, 0, []byte{0x28, 0xB5, 0x2F, 0xFD}},         // zstd frame
+	{XzCompressorType, 0, []byte{0xFD, '7', 'z', 'X', 'Z', 0x00}},        // xz stream header
+	{Lz4CompressorType, 0, []byte{0x04, 0x22, 0x4D, 0x18}},               // lz4 frame
+	{Bzip2CompressorType, 4, []byte{0x31, 0x41, 0x59, 0x26, 0x53, 0x59}}, // bzip2 block after "BZh[1-9]"
+	{Bzip2CompressorType, 4, []byte{0x17, 0x72, 0x45, 0x38, 0x50, 0x90}}, // empty bzip2 stream
+}
+
+// DetectCompressor returns the compression type from the magic bytes
+// at the start of a stream, or NoneCompressorType
+func DetectCompressor(testBytes []byte) CompressorType {
+	for _, mb := range compressorMagicBytes {
+		if !bytes.HasPrefix(testBytes[min(mb.offset, len(testBytes)):], mb.magic) {
+			continue
+		}
+		if mb.cpType == Bzip2CompressorType {
+			if !bytes.HasPrefix(testBytes, []byte("BZh")) || testBytes[3] < '1' || testBytes[3] > '9' {
+				continue
+			}
+		}
+		return mb.cpType
+	}
+	return NoneCompressorType
+}
+
 // AutoDecompress auto detexts compression to decompress. Otherwise return same reader
 func AutoDecompress(reader io.Reader) (gReader io.Reader, err error) {
 
 	bReader := bufio.NewReader(reader)
-	testBytes, err := bReader.Peek(2)
-	if err != nil {
+	testBytes, err := bReader.Peek(10)
+	if err != nil && len(testBytes) < 2 {
 		// return bReader, g.Error(err, "Error Peeking")
 		return bReader, nil
 	}
 
-	// https://stackoverflow.com/a/28332019
-	if testBytes[0] == 31 && testBytes[1] == 139 {
-		// is gzip
-		gReader, err = gzip.NewReader(bReader)
-		if err != nil {
-			return bReader, g.Error(err, "Error using gzip.NewReader")
-		}
-	} else {
-		gReader = bReader
+	cpType := DetectCompressor(testBytes)
+	if cpType == NoneCompressorType {
+		return bReader, nil
+	}
+
+	g.Trace("auto-detected %s compression", cpType)
+	gReader, err = NewCompressor(cpType).Decompress(bReader)
+	if err != nil {
+		return bReader, g.Error(err, "Error decompressing %s stream", cpType)
 	}
 
-	return gReader, err
+	return gReader, nil
 }
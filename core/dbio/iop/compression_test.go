@@ -1,6 +1,7 @@
 package iop
 
 import (
+	"encoding/hex"
 	"io"
 	"strings"
 	"testing"
@@ -45,4 +46,61 @@ func TestCompression(t *testing.T) {
 	g.AssertNoError(t, err)
 	assert.Equal(t, value, string(result))
 
+	// lz4, xz, brotli
+	for _, cpType := range []CompressorType{Lz4CompressorType, XzCompressorType, BrotliCompressorType} {
+		reader = strings.NewReader(value)
+		cp = NewCompressor(cpType)
+		cReader = cp.Compress(reader)
+		dReader, err = cp.Decompress(cReader)
+		g.AssertNoError(t, err)
+		result, err = io.ReadAll(dReader)
+		g.AssertNoError(t, err)
+		assert.Equal(t, value, string(result), cpType)
+	}
+
+	// bzip2 only decompresses
+	_, err = io.ReadAll(NewCompressor(Bzip2CompressorType).Compress(strings.NewReader(value)))
+	assert.Error(t, err)
+
+	assert.Equal(t, ".bz2", NewCompressor(Bzip2CompressorType).Suffix())
+	assert.Equal(t, ".lz4", NewCompressor(Lz4CompressorType).Suffix())
+	assert.Equal(t, ".xz", NewCompressor(XzCompressorType).Suffix())
+	assert.Equal(t, ".br", NewCompressor(BrotliCompressorType).Suffix())
+}
+
+func TestAutoDecompress(t *testing.T) {
+	value := "id,name\n1,Fred\n"
+
+	// generated with python's bz2.compress
+	bzip2Bytes, _ := hex.DecodeString("425a68393141592653593c98bba4000005dd8000100004200001002623100020002200d1908069a6802e2ace71416af1772453850903c98bba40")
+	assert.Equal(t, Bzip2CompressorType, DetectCompressor(bzip2Bytes))
+	reader, err := AutoDecompress(strings.NewReader(string(bzip2Bytes)))
+	if assert.NoError(t, err) {
+		result, err := io.ReadAll(reader)
+		assert.NoError(t, err)
+		assert.Equal(t, value, string(result))
+	}
+
+	for _, cpType := range []CompressorType{GzipCompressorType, ZStandardCompressorType, Lz4CompressorType, XzCompressorType} {
+		compressed, err := io.ReadAll(NewCompressor(cpType).Compress(strings.NewReader(value)))
+		g.AssertNoError(t, err)
+		assert.Equal(t, cpType, DetectCompressor(compressed))
+
+		reader, err := AutoDecompress(strings.NewReader(string(compressed)))
+		if assert.NoError(t, err, cpType) {
+			result, err := io.ReadAll(reader)
+			assert.NoError(t, err)
+			assert.Equal(t, value, string(result), cpType)
+		}
+	}
+
+	// plain text is left as is, even if starting like bzip2
+	for _, plain := range []string{value, "BZh9 not compressed", "a"} {
+		assert.Equal(t, NoneCompressorType, DetectCompressor([]byte(plain)))
+		reader, err := AutoDecompress(strings.NewReader(plain))
+		if assert.NoError(t, err) {
+			result, _ := io.ReadAll(reader)
+			assert.Equal(t, plain, string(result))
+		}
+	}
 }
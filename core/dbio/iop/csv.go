@@ -24,6 +24,7 @@ type CSV struct {
 	Path            string
 	NoHeader        bool
 	Delimiter       rune
+	Dialect         CsvDialect
 	FieldsPerRecord int
 	Columns         []Column
 	File            *os.File
@@ -209,6 +210,7 @@ func (c *CSV) getReader(delimiter string) (*csv.Reader, error) {
 		err = nil
 		reader2 = readerDecompr
 	}
+	testBytes, reader2 = stripBOM(testBytes, reader2)
 
 	// g.Trace("testBytes:\n%s", string(testBytes))
 
@@ -246,7 +248,7 @@ func (c *CSV) getReader(delimiter string) (*csv.Reader, error) {
 	deli := ','
 
 	if c.FieldsPerRecord == 0 {
-		deli, numCols, err = detectDelimiter(delimiter, testBytes)
+		deli, numCols, err = detectDelimiter(delimiter, testBytes, c.Dialect)
 		if err != nil {
 			return r, g.Error(err, "could not detect delimiter")
 		} else if !c.NoDebug && deli != ',' {
@@ -258,6 +260,15 @@ func (c *CSV) getReader(delimiter string) (*csv.Reader, error) {
 		}
 	}
 
+	if c.Delimiter != 0 {
+		deli = c.Delimiter
+	}
+
+	// convert a custom quoting dialect into the standard one
+	if !c.Dialect.standardQuoting() {
+		reader3 = c.Dialect.NewReader(reader3, deli)
+	}
+
 	// inject dummy header if none present
 	if c.NoHeader && numCols > 0 {
 		header := strings.Join(CreateDummyFields(numCols), string(deli))
@@ -270,6 +281,9 @@ func (c *CSV) getReader(delimiter string) (*csv.Reader, error) {
 	r.LazyQuotes = true
 	r.ReuseRecord = true
 	r.FieldsPerRecord = c.FieldsPerRecord
+	if c.Dialect.standardQuoting() {
+		r.Comment = c.Dialect.Comment // else dropped by the dialect reader
+	}
 	// r.TrimLeadingSpace = true
 	// r.TrailingComma = true
 	if c.Delimiter != 0 {
@@ -493,7 +507,10 @@ func detectCarrRet(testBytes []byte) (needsCleanUp bool) {
 	return
 }
 
-func detectDelimiter(delimiter string, testBytes []byte) (bestDeli rune, numCols int, err error) {
+// detectDelimiter detects the delimiter from the first bytes of a file, or
+// counts the columns with the provided delimiter. The quote and comment
+// characters of the dialect are used to parse the lines.
+func detectDelimiter(delimiter string, testBytes []byte, dialect CsvDialect) (bestDeli rune, numCols int, err error) {
 	bestDeli = ','
 	deliSuggested := false
 	if delimiter != "" {
@@ -526,9 +543,19 @@ func detectDelimiter(delimiter string, testBytes []byte) (bestDeli rune, numCols
 		var csvErr error
 		var row, prevRow []string
 		RowNumCols := []int{}
-		csvR := csv.NewReader(strings.NewReader(testString))
+		testReader := io.Reader(strings.NewReader(testString))
+		if !dialect.standardQuoting() {
+			// convert to the standard dialect, which drops comment lines
+			converted, _ := io.ReadAll(dialect.NewReader(testReader, d))
+			testReader = bytes.NewReader(converted)
+		}
+
+		csvR := csv.NewReader(testReader)
 		csvR.LazyQuotes = true
 		csvR.Comma = d
+		if dialect.standardQuoting() {
+			csvR.Comment = dialect.Comment
+		}
 		for {
 			row, csvErr = csvR.Read()
 			if csvErr == io.EOF {
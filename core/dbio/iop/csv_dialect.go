@@ -0,0 +1,309 @@
+package iop
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+
+	"github.com/flarco/g"
+	"github.com/flarco/g/csv"
+)
+
+// utf8BOM is the UTF-8 byte order mark
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// CsvDialect holds the quoting, escaping and line settings of a CSV file.
+// The default dialect quotes with `"`, escapes quotes by doubling them
+// and only quotes fields when needed.
+type CsvDialect struct {
+	Quote    rune   // quote character, `"` by default
+	Escape   rune   // escape character within quoted fields, 0 to double the quote
+	Comment  rune   // lines starting with this character are skipped when reading
+	QuoteAll bool   // quote all non-null fields when writing
+	UseCRLF  bool   // use \r\n as the line terminator when writing
+	NullAs   string // string written for null values
+	BOM      bool   // write a UTF-8 byte order mark
+}
+
+// csvDialect returns the CSV dialect of the stream config
+func (sc *streamConfig) csvDialect() (d CsvDialect) {
+	firstRune := func(s string) rune {
+		if s == "" {
+			return 0
+		}
+		return []rune(s)[0]
+	}
+
+	d = CsvDialect{
+		Quote:    firstRune(sc.Quote),
+		Escape:   firstRune(sc.Escape),
+		Comment:  firstRune(sc.Comment),
+		QuoteAll: sc.QuoteAll,
+		UseCRLF:  strings.EqualFold(sc.LineEnding, "crlf"),
+		NullAs:   sc.NullAs,
+		BOM:      sc.BOM,
+	}
+	return d.normalize()
+}
+
+func (d CsvDialect) normalize() CsvDialect {
+	if d.Quote == 0 {
+		d.Quote = '"'
+	}
+	if d.Escape == d.Quote {
+		d.Escape = 0 // same as doubling the quote
+	}
+	return d
+}
+
+// standardQuoting returns true if the quote and escape characters are the default ones
+func (d CsvDialect) standardQuoting() bool {
+	d = d.normalize()
+	return d.Quote == '"' && d.Escape == 0
+}
+
+// NewReader returns a reader which converts the dialect into the standard
+// CSV dialect, quoting every field with `"` and doubling quotes, so that
+// it can be parsed by csv.Reader. Comment lines are dropped.
+func (d CsvDialect) NewReader(reader io.Reader, delimiter rune) io.Reader {
+	d = d.normalize()
+	pr, pw := io.Pipe()
+
+	go func() {
+		const (
+			lineStart = iota
+			fieldStart
+			unquoted
+			quoted
+		)
+
+		br := bufio.NewReader(reader)
+		bw := bufio.NewWriter(pw)
+		state := lineStart
+
+		writeContent := func(r rune) {
+			if r == '"' {
+				bw.WriteString(`""`)
+			} else {
+				bw.WriteRune(r)
+			}
+		}
+
+		var err error
+		for {
+			var r rune
+			r, _, err = br.ReadRune()
+			if err != nil {
+				break
+			}
+
+			switch state {
+			case lineStart, fieldStart:
+				if state == lineStart && d.Comment != 0 && r == d.Comment {
+					_, err = br.ReadString('\n')
+					if err != nil {
+						break
+					}
+					continue
+				} else if state == lineStart && (r == '\n' || r == '\r') {
+					bw.WriteRune(r) // blank line
+					continue
+				}
+
+				bw.WriteByte('"')
+				if r == d.Quote {
+					state = quoted
+				} else {
+					state = unquoted
+					br.UnreadRune()
+				}
+			case unquoted:
+				switch {
+				case r == delimiter:
+					bw.WriteByte('"')
+					bw.WriteRune(r)
+					state = fieldStart
+				case r == '\n':
+					bw.WriteString("\"\n")
+					state = lineStart
+				case r == '\r':
+					if next, _ := br.Peek(1); len(next) == 1 && next[0] == '\n' {
+						continue // line ending is handled with \n
+					}
+					writeContent(r)
+				default:
+					writeContent(r) // escapes only apply within quotes
+				}
+			case quoted:
+				switch {
+				case d.Escape != 0 && r == d.Escape:
+					if next, _, err := br.ReadRune(); err == nil {
+						writeContent(next)
+					} else {
+						writeContent(r)
+					}
+				case r == d.Quote:
+					if next, _ := br.Peek(len(string(d.Quote))); d.Escape == 0 && string(next) == string(d.Quote) {
+						br.ReadRune()
+						writeContent(r) // doubled quote
+					} else {
+						state = unquoted // closing quote, trailing characters are kept
+					}
+				default:
+					writeContent(r)
+				}
+			}
+
+			if err != nil {
+				break
+			}
+		}
+
+		switch state {
+		case fieldStart:
+			bw.WriteString(`""`)
+		case unquoted, quoted:
+			bw.WriteByte('"')
+		}
+
+		if err == io.EOF {
+			err = nil
+		}
+		if flushErr := bw.Flush(); err == nil {
+			err = flushErr
+		}
+		pw.CloseWithError(err)
+	}()
+
+	return pr
+}
+
+// CsvWriter writes CSV records with a dialect
+type CsvWriter struct {
+	Comma   rune
+	Dialect CsvDialect
+	w       *bufio.Writer
+	std     *csv.Writer // for the standard dialect
+	started bool
+}
+
+// NewCsvWriter returns a new CsvWriter
+func NewCsvWriter(w io.Writer, comma rune, dialect CsvDialect) *CsvWriter {
+	dialect = dialect.normalize()
+	cw := &CsvWriter{Comma: comma, Dialect: dialect, w: bufio.NewWriterSize(w, 40960)}
+	if dialect.standardQuoting() && !dialect.QuoteAll && dialect.NullAs == "" {
+		cw.std = csv.NewWriter(cw.w)
+		cw.std.Comma = comma
+		cw.std.UseCRLF = dialect.UseCRLF
+	}
+	return cw
+}
+
+// Write writes a single CSV record. The fields flagged in nulls are
+// written as the dialect null string, unquoted.
+// It returns the total number of bytes written.
+func (w *CsvWriter) Write(record []string, nulls []bool) (tbw int, err error) {
+	if !w.started {
+		w.started = true
+		if w.Dialect.BOM {
+			bw, err := w.w.Write(utf8BOM)
+			tbw = tbw + bw
+			if err != nil {
+				return tbw, err
+			}
+		}
+	}
+
+	if w.std != nil {
+		bw, err := w.std.Write(record)
+		return tbw + bw, err
+	}
+
+	newLine := "\n"
+	if w.Dialect.UseCRLF {
+		newLine = "\r\n"
+	}
+
+	var buf strings.Builder
+	for n, field := range record {
+		if n > 0 {
+			buf.WriteRune(w.Comma)
+		}
+
+		if n < len(nulls) && nulls[n] {
+			buf.WriteString(w.Dialect.NullAs)
+			continue
+		} else if !w.fieldNeedsQuotes(field) {
+			buf.WriteString(field)
+			continue
+		}
+
+		buf.WriteRune(w.Dialect.Quote)
+		for _, r := range field {
+			switch {
+			case r == w.Dialect.Quote:
+				if w.Dialect.Escape != 0 {
+					buf.WriteRune(w.Dialect.Escape)
+				} else {
+					buf.WriteRune(w.Dialect.Quote)
+				}
+				buf.WriteRune(r)
+			case w.Dialect.Escape != 0 && r == w.Dialect.Escape:
+				buf.WriteRune(r)
+				buf.WriteRune(r)
+			case r == '\r' && w.Dialect.UseCRLF:
+				// written with \n
+			case r == '\n':
+				buf.WriteString(newLine)
+			default:
+				buf.WriteRune(r)
+			}
+		}
+		buf.WriteRune(w.Dialect.Quote)
+	}
+	buf.WriteString(newLine)
+
+	bw, err := w.w.WriteString(buf.String())
+	tbw = tbw + bw
+	if err != nil {
+		return tbw, g.Error(err, "could not write csv record")
+	}
+	return tbw, nil
+}
+
+// Flush writes any buffered data to the underlying io.Writer
+func (w *CsvWriter) Flush() error {
+	if w.std != nil {
+		w.std.Flush()
+	}
+	return w.w.Flush()
+}
+
+// fieldNeedsQuotes reports whether the field must be enclosed in quotes
+func (w *CsvWriter) fieldNeedsQuotes(field string) bool {
+	switch {
+	case w.Dialect.QuoteAll:
+		return true
+	case field == "":
+		return false
+	case field == `\.` || field == w.Dialect.NullAs:
+		return true // postgres end of data marker, or ambiguous with nulls
+	}
+
+	for _, r := range field {
+		if r == w.Comma || r == w.Dialect.Quote || r == '\r' || r == '\n' || (w.Dialect.Escape != 0 && r == w.Dialect.Escape) {
+			return true
+		}
+	}
+	return false
+}
+
+// stripBOM removes a leading UTF-8 byte order mark
+func stripBOM(testBytes []byte, reader io.Reader) ([]byte, io.Reader) {
+	if !bytes.HasPrefix(testBytes, utf8BOM) {
+		return testBytes, reader
+	}
+	io.CopyN(io.Discard, reader, int64(len(utf8BOM)))
+	return testBytes[len(utf8BOM):], reader
+}
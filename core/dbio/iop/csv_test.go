@@ -208,17 +208,17 @@ cal,cal
 cao;daf
 "fa",da
 ra<d|da`
-	deli, numCols, err := detectDelimiter(",", []byte(testString))
+	deli, numCols, err := detectDelimiter(",", []byte(testString), CsvDialect{})
 	assert.NoError(t, err) // since delimiter is specified, will retun no error
 	assert.Equal(t, string(','), string(deli))
 	assert.Equal(t, 2, numCols)
 
-	deli, numCols, err = detectDelimiter("\t", []byte(testString))
+	deli, numCols, err = detectDelimiter("\t", []byte(testString), CsvDialect{})
 	assert.NoError(t, err)
 	assert.Equal(t, "\t", string(deli))
 	assert.Equal(t, 1, numCols)
 
-	deli, numCols, err = detectDelimiter("", []byte(testString))
+	deli, numCols, err = detectDelimiter("", []byte(testString), CsvDialect{})
 	assert.Error(t, err)
 	assert.Equal(t, string(','), string(deli))
 	assert.Equal(t, 2, numCols)
@@ -229,8 +229,93 @@ cao|daf
 "fa"|da
 ra<d|da`
 
-	deli, numCols, err = detectDelimiter("", []byte(testString))
+	deli, numCols, err = detectDelimiter("", []byte(testString), CsvDialect{})
 	assert.NoError(t, err)
 	assert.Equal(t, string('|'), string(deli))
 	assert.Equal(t, 2, numCols)
+
+	// comment lines and delimiters within custom quotes are ignored
+	testString = `# exported, with; some | notes
+col1;col2;col3
+'a,b';c;d
+'e|f';g;h
+i;j;k`
+
+	deli, numCols, err = detectDelimiter("", []byte(testString), CsvDialect{Quote: '\'', Comment: '#'})
+	assert.NoError(t, err)
+	assert.Equal(t, string(';'), string(deli))
+	assert.Equal(t, 3, numCols)
+}
+
+func TestCsvDialect(t *testing.T) {
+	readRows := func(input string, config map[string]string) [][]any {
+		ds := NewDatastream(nil)
+		ds.SetConfig(config)
+		err := ds.ConsumeCsvReader(strings.NewReader(input))
+		if !assert.NoError(t, err) {
+			return nil
+		}
+		data, err := ds.Collect(0)
+		assert.NoError(t, err)
+		return data.Rows
+	}
+
+	// single quotes with backslash escapes, comments and a BOM
+	input := "\xEF\xBB\xBFid;name;note\n# a comment\n1;'O\\'Brien';'a;b'\n2;'say \"hi\"';'x\\\\y'\n3;plain;'multi\r\nline'\n"
+	rows := readRows(input, map[string]string{"delimiter": ";", "quote": "'", "escape": `\`, "comment": "#"})
+	if assert.Len(t, rows, 3) {
+		assert.Equal(t, "O'Brien", rows[0][1])
+		assert.Equal(t, "a;b", rows[0][2])
+		assert.Equal(t, `say "hi"`, rows[1][1])
+		assert.Equal(t, `x\y`, rows[1][2])
+		assert.Equal(t, "plain", rows[2][1])
+		assert.Equal(t, "multi\nline", rows[2][2])
+	}
+
+	// doubled custom quote
+	rows = readRows("id,name\n1,'it''s'\n", map[string]string{"quote": "'"})
+	if assert.Len(t, rows, 1) {
+		assert.Equal(t, "it's", rows[0][1])
+	}
+
+	// comment with the standard quoting
+	rows = readRows("\xEF\xBB\xBFid,name\n#skip,me\n1,\"a\"\"b\"\n", map[string]string{"comment": "#"})
+	if assert.Len(t, rows, 1) {
+		assert.Equal(t, `a"b`, rows[0][1])
+	}
+
+	writeCsv := func(config map[string]string) string {
+		data := NewDataset(NewColumns(Column{Name: "id", Type: IntegerType}, Column{Name: "name", Type: StringType}))
+		data.Inferred = true
+		data.Append([]any{1, "O'Brien"})
+		data.Append([]any{2, nil})
+		data.Append([]any{3, `a\b`})
+		ds := data.Stream()
+		ds.SetConfig(config)
+		var output string
+		for br := range ds.NewCsvReaderChnl(0, 0) {
+			b, err := io.ReadAll(br.Reader)
+			assert.NoError(t, err)
+			output = output + string(b)
+		}
+		return output
+	}
+
+	output := writeCsv(map[string]string{"quote": "'", "escape": `\`, "quote_all": "true", "null_as": `\N`, "line_ending": "crlf", "bom": "true"})
+	assert.Equal(t, "\xEF\xBB\xBF'id','name'\r\n'1','O\\'Brien'\r\n'2',\\N\r\n'3','a\\\\b'\r\n", output)
+
+	output = writeCsv(map[string]string{"null_as": "NULL"})
+	assert.Equal(t, "id,name\n1,O'Brien\n2,NULL\n3,a\\b\n", output)
+
+	output = writeCsv(map[string]string{"line_ending": "crlf"})
+	assert.Equal(t, "id,name\r\n1,O'Brien\r\n2,\r\n3,a\\b\r\n", output)
+
+	// round trip
+	output = writeCsv(map[string]string{"quote": "'", "escape": `\`, "null_as": `\N`})
+	rows = readRows(output, map[string]string{"quote": "'", "escape": `\`, "null_if": `\N`})
+	if assert.Len(t, rows, 3) {
+		assert.Equal(t, "O'Brien", rows[0][1])
+		assert.Nil(t, rows[1][1])
+		assert.Equal(t, `a\b`, rows[2][1])
+	}
 }
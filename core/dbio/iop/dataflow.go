@@ -26,6 +26,10 @@ type Dataflow struct {
 	Ready           bool
 	Inferred        bool
 	FsURL           string
+	FsPaths         []string          // files read, for file sources
+	ChildStreams    chan *ChildStream // normalized nested arrays, sent as they appear
+	SingerState     string            // latest state of a Singer tap, to persist once loaded
+	SingerStatePath string            // state file of the Singer stream
 	OnColumnChanged func(col Column) error
 	OnColumnAdded   func(col Column) error
 	readyChn        chan struct{}
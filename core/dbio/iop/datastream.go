@@ -10,6 +10,7 @@ import (
 	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	arrowCompress "github.com/apache/arrow/go/v16/parquet/compress"
@@ -28,6 +29,9 @@ import (
 
 var (
 	jsoniter = jit.ConfigCompatibleWithStandardLibrary
+
+	// rowSeq numbers rows across all streams, in the order they are read
+	rowSeq atomic.Uint64
 )
 
 // Datastream is a stream of rows
@@ -60,6 +64,7 @@ type Datastream struct {
 	paused        bool
 	pauseChan     chan struct{}
 	unpauseChan   chan struct{}
+	normalizer    *normalizer
 }
 
 type schemaChg struct {
@@ -79,6 +84,7 @@ type Metadata struct {
 	LoadedAt  KeyValue `json:"loaded_at"`
 	RowNum    KeyValue `json:"row_num"`
 	RowID     KeyValue `json:"row_id"`
+	RowSeq    KeyValue `json:"row_seq"`
 }
 
 // AsMap return as map
@@ -101,6 +107,7 @@ type Iterator struct {
 	dsBufferI   int // -1 means ds is not buffered
 	nextFunc    func(it *Iterator) bool
 	limitCnt    uint64 // to not check for df limit each cycle
+	sampled     bool   // whether nextFunc returns sampled rows
 }
 
 // NewDatastream return a new datastream
@@ -294,6 +301,10 @@ func (ds *Datastream) Close() {
 			}
 		}
 
+		if ds.normalizer != nil {
+			ds.normalizer.Close() // ends the child streams
+		}
+
 		for _, f := range ds.deferFuncs {
 			f()
 		}
@@ -430,6 +441,19 @@ func (ds *Datastream) Collect(limit int) (Dataset, error) {
 	return data, nil
 }
 
+// ChildStreams returns the channel of the streams of the nested arrays of
+// records, split with the normalize option (such as `items`). The child
+// streams are sent as they appear, and need to be consumed concurrently
+// with the stream. The channel is closed once the stream is consumed.
+func (ds *Datastream) ChildStreams() chan *ChildStream {
+	if ds.normalizer == nil {
+		streamCh := make(chan *ChildStream)
+		close(streamCh)
+		return streamCh
+	}
+	return ds.normalizer.Streams()
+}
+
 // Err return the error if any
 func (ds *Datastream) Err() (err error) {
 	return ds.Context.Err()
@@ -443,6 +467,11 @@ func (ds *Datastream) Start() (err error) {
 		return g.Error(err, "need to define iterator")
 	}
 
+	if sample := ds.Sp.config.Sample; sample != nil && !ds.it.sampled {
+		ds.it.nextFunc = sampleNextFunc(sample, ds.it.nextFunc)
+		ds.it.sampled = true
+	}
+
 loop:
 	for ds.it.next() {
 		select {
@@ -542,6 +571,10 @@ loop:
 			}
 			ds.Columns = append(ds.Columns, col)
 			metaValuesMap[col.Position-1] = func(it *Iterator) any {
+				// rows of the buffer were all counted when sampled
+				if it.dsBufferI > -1 && it.Counter == uint64(len(ds.Buffer)) {
+					return uint64(it.dsBufferI)
+				}
 				return it.Counter
 			}
 		}
@@ -563,6 +596,20 @@ loop:
 				}
 			}
 		}
+
+		// unlike row_num, keeps increasing from one stream to the next
+		if ds.Metadata.RowSeq.Key != "" {
+			ds.Metadata.RowSeq.Key = ensureName(ds.Metadata.RowSeq.Key)
+			col := Column{
+				Name:     ds.Metadata.RowSeq.Key,
+				Type:     BigIntType,
+				Position: len(ds.Columns) + 1,
+			}
+			ds.Columns = append(ds.Columns, col)
+			metaValuesMap[col.Position-1] = func(it *Iterator) any {
+				return rowSeq.Add(1)
+			}
+		}
 	}
 
 	// setMetaValues sets mata column values
@@ -764,7 +811,7 @@ func (ds *Datastream) ConsumeXmlReader(reader io.Reader) (err error) {
 
 // ConsumeCsvReader uses the provided reader to stream rows
 func (ds *Datastream) ConsumeCsvReader(reader io.Reader) (err error) {
-	c := CSV{Reader: reader, NoHeader: !ds.config.Header, FieldsPerRecord: ds.config.FieldsPerRec}
+	c := CSV{Reader: reader, NoHeader: !ds.config.Header, FieldsPerRecord: ds.config.FieldsPerRec, Dialect: ds.config.csvDialect()}
 
 	r, err := c.getReader(ds.config.Delimiter)
 	if err != nil {
@@ -885,6 +932,82 @@ func (ds *Datastream) ConsumeParquetReader(reader io.Reader) (err error) {
 	return ds.ConsumeParquetReaderSeeker(file)
 }
 
+// ConsumeArrowReaderSeeker uses the provided reader to stream rows
+// from the Arrow IPC file (Feather V2) or stream format
+func (ds *Datastream) ConsumeArrowReaderSeeker(reader io.ReadSeeker) (err error) {
+	a, err := NewArrowReader(reader)
+	if err != nil {
+		return g.Error(err, "could create arrow stream")
+	}
+
+	ds.Columns = a.Columns()
+	ds.Inferred = ds.Columns.Sourced()
+	ds.it = ds.NewIterator(ds.Columns, a.nextFunc)
+
+	err = ds.Start()
+	if err != nil {
+		return g.Error(err, "could start datastream")
+	}
+
+	return
+}
+
+// ConsumeArrowReader uses the provided reader to stream rows.
+// The Arrow IPC stream format is read directly, while the file
+// format (Feather V2) is first written to a temp file.
+func (ds *Datastream) ConsumeArrowReader(reader io.Reader) (err error) {
+	reader2, err := AutoDecompress(reader)
+	if err != nil {
+		return g.Error(err, "Could not decompress reader")
+	}
+
+	magic, reader3, err := g.Peek(reader2, len(arrowFileMagic))
+	if err != nil {
+		return g.Error(err, "could not peek arrow reader")
+	}
+
+	if string(magic) != arrowFileMagic {
+		a, err := NewArrowReader(reader3)
+		if err != nil {
+			return g.Error(err, "could create arrow stream")
+		}
+
+		ds.Columns = a.Columns()
+		ds.Inferred = ds.Columns.Sourced()
+		ds.it = ds.NewIterator(ds.Columns, a.nextFunc)
+
+		err = ds.Start()
+		if err != nil {
+			return g.Error(err, "could start datastream")
+		}
+		return nil
+	}
+
+	// need to write to temp file prior
+	tempDir := env.GetTempFolder()
+	arrowPath := path.Join(tempDir, g.NewTsID("arrow.temp")+".arrow")
+	ds.Defer(func() { os.Remove(arrowPath) })
+
+	file, err := os.Create(arrowPath)
+	if err != nil {
+		return g.Error(err, "Unable to create temp file: "+arrowPath)
+	}
+
+	g.Debug("downloading to temp file on disk: %s", arrowPath)
+	bw, err := io.Copy(file, reader3)
+	if err != nil {
+		return g.Error(err, "Unable to write to temp file: "+arrowPath)
+	}
+	g.Debug("wrote %d bytes to %s", bw, arrowPath)
+
+	_, err = file.Seek(0, 0) // reset to beginning
+	if err != nil {
+		return g.Error(err, "Unable to seek to beginning of temp file: "+arrowPath)
+	}
+
+	return ds.ConsumeArrowReaderSeeker(file)
+}
+
 // ConsumeAvroReaderSeeker uses the provided reader to stream rows
 func (ds *Datastream) ConsumeAvroReaderSeeker(reader io.ReadSeeker) (err error) {
 	a, err := NewAvroStream(reader, Columns{})
@@ -1135,6 +1258,48 @@ func (ds *Datastream) Shape(columns Columns) (nDs *Datastream, err error) {
 	return ds, err
 }
 
+// ShapeByName returns a stream with the provided columns, with the values
+// matched by column name. Missing columns are null, and values are cast
+// to the types of the provided columns.
+func (ds *Datastream) ShapeByName(columns Columns) (nDs *Datastream, err error) {
+	if err = ds.WaitReady(); err != nil {
+		return ds, g.Error(err, "could not wait for stream")
+	}
+
+	shaper, err := ds.Columns.MakeShaper(columns)
+	if err != nil {
+		return ds, g.Error(err, "could not make shaper")
+	} else if shaper == nil {
+		shaper = &Shaper{Func: func(row []any) []any { return row }}
+	}
+
+	rows := MakeRowsChan()
+	nextFunc := func(it *Iterator) bool {
+		for it.Row = range rows {
+			return true
+		}
+		return false
+	}
+	// columns of inferred formats (such as CSV) are not sourced, so that
+	// their types can still change when values do not match the sample
+	nDs = NewDatastreamIt(ds.Context.Ctx, columns.Clone(), nextFunc)
+	nDs.Inferred = true
+
+	go func() {
+		defer close(rows)
+		for row := range ds.Rows() {
+			rows <- shaper.Func(row)
+		}
+	}()
+
+	err = nDs.Start()
+	if err != nil {
+		ds.Context.CaptureErr(err)
+	}
+
+	return nDs, err
+}
+
 // Map applies the provided function to every row
 // and returns the result
 func (ds *Datastream) Map(newColumns Columns, transf func([]any) []any) (nDs *Datastream) {
@@ -1281,6 +1446,26 @@ type BatchReader struct {
 	Counter int
 }
 
+// newCsvWriter returns a csv writer with the delimiter and dialect of the stream
+func (ds *Datastream) newCsvWriter(w io.Writer) *CsvWriter {
+	comma := ','
+	if ds.config.Delimiter != "" {
+		comma = []rune(ds.config.Delimiter)[0]
+	}
+	return NewCsvWriter(w, comma, ds.config.csvDialect())
+}
+
+// csvRow converts the values to csv strings, flagging the nulls
+func (ds *Datastream) csvRow(row0 []any, columns Columns) (row []string, nulls []bool) {
+	row = make([]string, len(row0))
+	nulls = make([]bool, len(row0))
+	for i, val := range row0 {
+		row[i] = ds.Sp.CastToString(i, val, columns[i].Type)
+		nulls[i] = val == nil
+	}
+	return row, nulls
+}
+
 // NewCsvReaderChnl provides a channel of readers as the limit is reached
 // each channel flows as fast as the consumer consumes
 func (ds *Datastream) NewCsvReaderChnl(rowLimit int, bytesLimit int64) (readerChn chan *BatchReader) {
@@ -1298,7 +1483,7 @@ func (ds *Datastream) NewCsvReaderChnl(rowLimit int, bytesLimit int64) (readerCh
 	_ = mux
 
 	go func() {
-		var w *csv.Writer
+		var w *CsvWriter
 		var br *BatchReader
 
 		defer close(readerChn)
@@ -1310,14 +1495,10 @@ func (ds *Datastream) NewCsvReaderChnl(rowLimit int, bytesLimit int64) (readerCh
 
 			// new reader
 			pipeR, pipeW = io.Pipe()
-			w = csv.NewWriter(pipeW)
-			w.Comma = ','
-			if ds.config.Delimiter != "" {
-				w.Comma = []rune(ds.config.Delimiter)[0]
-			}
+			w = ds.newCsvWriter(pipeW)
 
 			if ds.config.Header {
-				bw, err := w.Write(batch.Columns.Names(true, true))
+				bw, err := w.Write(batch.Columns.Names(true, true), nil)
 				tbw = tbw + cast.ToInt64(bw)
 				if err != nil {
 					err = g.Error(err, "error writing header")
@@ -1348,13 +1529,10 @@ func (ds *Datastream) NewCsvReaderChnl(rowLimit int, bytesLimit int64) (readerCh
 				// g.PP(batch.Columns.MakeRec(row0))
 				br.Counter++
 				// convert to csv string
-				row := make([]string, len(row0))
-				for i, val := range row0 {
-					row[i] = ds.Sp.CastToString(i, val, batch.Columns[i].Type)
-				}
+				row, nulls := ds.csvRow(row0, batch.Columns)
 				mux.Lock()
 
-				bw, err := w.Write(row)
+				bw, err := w.Write(row, nulls)
 				tbw = tbw + cast.ToInt64(bw)
 				if err != nil {
 					ds.Context.CaptureErr(g.Error(err, "error writing row"))
@@ -1383,13 +1561,14 @@ func (ds *Datastream) NewCsvReaderChnl(rowLimit int, bytesLimit int64) (readerCh
 	return readerChn
 }
 
-func (ds *Datastream) NewJsonReaderChnl(rowLimit int, bytesLimit int64) (readerChn chan *io.PipeReader) {
-	readerChn = make(chan *io.PipeReader, 100)
+func (ds *Datastream) NewJsonReaderChnl(rowLimit int, bytesLimit int64) (readerChn chan *BatchReader) {
+	readerChn = make(chan *BatchReader, 100)
 
 	pipe := g.NewPipe()
 	firstRec := true
 
-	readerChn <- pipe.Reader
+	br := &BatchReader{ds.CurrentBatch, ds.Columns, pipe.Reader, 0}
+	readerChn <- br
 	tbw := int64(0)
 
 	go func() {
@@ -1405,6 +1584,7 @@ func (ds *Datastream) NewJsonReaderChnl(rowLimit int, bytesLimit int64) (readerC
 
 			for row0 := range batch.Rows {
 				c++
+				br.Counter++
 
 				rec := g.M()
 				for i, val := range row0 {
@@ -1444,7 +1624,8 @@ func (ds *Datastream) NewJsonReaderChnl(rowLimit int, bytesLimit int64) (readerC
 					c = 0
 					firstRec = true
 					pipe = g.NewPipe()
-					readerChn <- pipe.Reader
+					br = &BatchReader{batch, batch.Columns, pipe.Reader, 0}
+					readerChn <- br
 					bw, _ := pipe.Writer.Write([]byte("["))
 					tbw = tbw + cast.ToInt64(bw)
 				}
@@ -1460,12 +1641,13 @@ func (ds *Datastream) NewJsonReaderChnl(rowLimit int, bytesLimit int64) (readerC
 
 // NewJsonLinesReaderChnl provides a channel of readers as the limit is reached
 // each channel flows as fast as the consumer consumes
-func (ds *Datastream) NewJsonLinesReaderChnl(rowLimit int, bytesLimit int64) (readerChn chan *io.PipeReader) {
-	readerChn = make(chan *io.PipeReader, 100)
+func (ds *Datastream) NewJsonLinesReaderChnl(rowLimit int, bytesLimit int64) (readerChn chan *BatchReader) {
+	readerChn = make(chan *BatchReader, 100)
 
 	pipe := g.NewPipe()
 
-	readerChn <- pipe.Reader
+	br := &BatchReader{ds.CurrentBatch, ds.Columns, pipe.Reader, 0}
+	readerChn <- br
 	tbw := int64(0)
 
 	go func() {
@@ -1478,6 +1660,7 @@ func (ds *Datastream) NewJsonLinesReaderChnl(rowLimit int, bytesLimit int64) (re
 
 			for row0 := range batch.Rows {
 				c++
+				br.Counter++
 
 				rec := g.M()
 				for i, val := range row0 {
@@ -1508,7 +1691,8 @@ func (ds *Datastream) NewJsonLinesReaderChnl(rowLimit int, bytesLimit int64) (re
 					// new reader
 					c = 0
 					pipe = g.NewPipe()
-					readerChn <- pipe.Reader
+					br = &BatchReader{batch, batch.Columns, pipe.Reader, 0}
+					readerChn <- br
 				}
 			}
 		}
@@ -1518,6 +1702,96 @@ func (ds *Datastream) NewJsonLinesReaderChnl(rowLimit int, bytesLimit int64) (re
 	return readerChn
 }
 
+// NewXmlReaderChnl provides a channel of readers as the limit is reached
+// each channel flows as fast as the consumer consumes
+func (ds *Datastream) NewXmlReaderChnl(rowLimit int, bytesLimit int64) (readerChn chan *BatchReader) {
+	readerChn = make(chan *BatchReader, 100)
+
+	pipeR, pipeW := io.Pipe()
+
+	tbw := int64(0)
+
+	go func() {
+		var br *BatchReader
+		xw := newXmlWriter(ds.Sp)
+
+		defer close(readerChn)
+
+		closePipe := func() {
+			if br != nil {
+				pipeW.Write(xw.Footer())
+			}
+			pipeW.Close()
+			br = nil
+		}
+
+		nextPipe := func(batch *Batch) error {
+			closePipe() // close the prior reader
+			tbw = 0     // reset
+
+			// new reader
+			pipeR, pipeW = io.Pipe()
+			br = &BatchReader{batch, batch.Columns, pipeR, 0}
+			readerChn <- br
+
+			bw, err := pipeW.Write(xw.Header())
+			tbw = tbw + cast.ToInt64(bw)
+			if err != nil {
+				err = g.Error(err, "error writing xml header")
+				ds.Context.Cancel()
+				pipeW.Close()
+				return err
+			}
+
+			return nil
+		}
+
+		for batch := range ds.BatchChan {
+
+			if batch.ColumnsChanged() || batch.IsFirst() {
+				xw.SetColumns(batch.Columns)
+			}
+
+			if batch.IsFirst() {
+				err := nextPipe(batch)
+				if err != nil {
+					ds.Context.CaptureErr(err)
+					return
+				}
+			}
+
+			for row := range batch.Rows {
+				if br == nil {
+					// limit was reached, open next reader
+					err := nextPipe(batch)
+					if err != nil {
+						ds.Context.CaptureErr(err)
+						return
+					}
+				}
+				br.Counter++
+
+				bw, err := pipeW.Write(xw.Row(row))
+				tbw = tbw + cast.ToInt64(bw)
+				if err != nil {
+					ds.Context.CaptureErr(g.Error(err, "error writing row"))
+					ds.Context.Cancel()
+					pipeW.Close()
+					return
+				}
+
+				if (rowLimit > 0 && br.Counter >= rowLimit) || (bytesLimit > 0 && tbw >= bytesLimit) {
+					closePipe()
+				}
+			}
+		}
+
+		closePipe()
+	}()
+
+	return readerChn
+}
+
 // NewParquetArrowReaderChnl provides a channel of readers as the limit is reached
 // each channel flows as fast as the consumer consumes
 // WARN: Not using this one since it doesn't write Decimals properly.
@@ -1559,6 +1833,8 @@ func (ds *Datastream) NewParquetArrowReaderChnl(rowLimit int, bytesLimit int64,
 				codec = arrowCompress.Codecs.Zstd
 			case GzipCompressorType:
 				codec = arrowCompress.Codecs.Gzip
+			case BrotliCompressorType:
+				codec = arrowCompress.Codecs.Brotli // lz4 is not supported by the arrow writer
 			case NoneCompressorType:
 				codec = arrowCompress.Codecs.Uncompressed
 			}
@@ -1653,10 +1929,23 @@ func (ds *Datastream) NewParquetReaderChnl(rowLimit int, bytesLimit int64, compr
 				codec = &parquet.Zstd
 			case GzipCompressorType:
 				codec = &parquet.Gzip
+			case Lz4CompressorType:
+				codec = &parquet.Lz4Raw
+			case BrotliCompressorType:
+				codec = &parquet.Brotli
 			case NoneCompressorType:
 				codec = &parquet.Uncompressed
 			}
 
+			// force the precision of timestamps if specified
+			if precision := ds.config.TimestampPrecision; precision > 0 {
+				for i := range batch.Columns {
+					if batch.Columns[i].IsDatetime() {
+						batch.Columns[i].DbPrecision = precision
+					}
+				}
+			}
+
 			pw, err = NewParquetWriter(pipeW, batch.Columns, codec)
 			if err != nil {
 				return g.Error(err, "could not create parquet writer")
@@ -1706,6 +1995,96 @@ func (ds *Datastream) NewParquetReaderChnl(rowLimit int, bytesLimit int64, compr
 	return readerChn
 }
 
+// NewArrowReaderChnl provides a channel of readers as the limit is reached
+// each channel flows as fast as the consumer consumes.
+// When fileFormat is true, the IPC file format (Feather V2) is written.
+func (ds *Datastream) NewArrowReaderChnl(rowLimit int, bytesLimit int64, fileFormat bool, compression CompressorType) (readerChn chan *BatchReader) {
+	readerChn = make(chan *BatchReader, 100)
+
+	pipeR, pipeW := io.Pipe()
+
+	go func() {
+		var aw *ArrowWriter
+		var br *BatchReader
+		var err error
+
+		defer close(readerChn)
+
+		closeWriter := func() error {
+			if aw != nil {
+				if err := aw.Close(); err != nil {
+					return g.Error(err, "could not close arrow writer")
+				}
+			}
+			return nil
+		}
+
+		nextPipe := func(batch *Batch) error {
+			if err := closeWriter(); err != nil {
+				return err
+			}
+
+			pipeW.Close() // close the prior reader?
+
+			// new reader
+			pipeR, pipeW = io.Pipe()
+
+			br = &BatchReader{batch, batch.Columns, pipeR, 0}
+			readerChn <- br
+
+			aw, err = NewArrowWriter(pipeW, batch.Columns, fileFormat, compression)
+			if err != nil {
+				return g.Error(err, "could not create arrow writer")
+			}
+
+			return nil
+		}
+
+		for batch := range ds.BatchChan {
+			if batch.ColumnsChanged() || batch.IsFirst() {
+				err := nextPipe(batch)
+				if err != nil {
+					ds.Context.CaptureErr(err)
+					ds.Context.Cancel()
+					pipeW.Close()
+					return
+				}
+			}
+
+			for row := range batch.Rows {
+
+				err := aw.WriteRow(row)
+				if err != nil {
+					ds.Context.CaptureErr(g.Error(err, "error writing row"))
+					ds.Context.Cancel()
+					pipeW.Close()
+					return
+				}
+
+				br.Counter++
+
+				if (rowLimit > 0 && br.Counter >= rowLimit) || (bytesLimit > 0 && aw.BytesWritten() >= bytesLimit) {
+					err = nextPipe(batch)
+					if err != nil {
+						ds.Context.CaptureErr(err)
+						ds.Context.Cancel()
+						pipeW.Close()
+						return
+					}
+				}
+			}
+		}
+
+		if err := closeWriter(); err != nil {
+			ds.Context.CaptureErr(err)
+		}
+		pipeW.Close()
+
+	}()
+
+	return readerChn
+}
+
 // NewCsvReader creates a Reader with limit. If limit == 0, then read all rows.
 func (ds *Datastream) NewCsvReader(rowLimit int, bytesLimit int64) *io.PipeReader {
 	pipeR, pipeW := io.Pipe()
@@ -1737,14 +2116,10 @@ func (ds *Datastream) NewCsvReader(rowLimit int, bytesLimit int64) *io.PipeReade
 		}
 
 		c := 0 // local counter
-		w := csv.NewWriter(pipeW)
-		w.Comma = ','
-		if ds.config.Delimiter != "" {
-			w.Comma = []rune(ds.config.Delimiter)[0]
-		}
+		w := ds.newCsvWriter(pipeW)
 
 		if ds.config.Header {
-			bw, err := w.Write(batch.Columns.Names(true, true))
+			bw, err := w.Write(batch.Columns.Names(true, true), nil)
 			tbw = tbw + cast.ToInt64(bw)
 			if err != nil {
 				ds.Context.CaptureErr(g.Error(err, "error writing header"))
@@ -1755,11 +2130,8 @@ func (ds *Datastream) NewCsvReader(rowLimit int, bytesLimit int64) *io.PipeReade
 		for row0 := range batch.Rows {
 			c++
 			// convert to csv string
-			row := make([]string, len(row0))
-			for i, val := range row0 {
-				row[i] = ds.Sp.CastToString(i, val, ds.Columns[i].Type)
-			}
-			bw, err := w.Write(row)
+			row, nulls := ds.csvRow(row0, ds.Columns)
+			bw, err := w.Write(row, nulls)
 			tbw = tbw + cast.ToInt64(bw)
 			if err != nil {
 				ds.Context.CaptureErr(g.Error(err, "error writing row"))
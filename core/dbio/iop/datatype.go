@@ -535,6 +535,55 @@ func (cols Columns) Add(newCols Columns, overwrite bool) (col2 Columns, added Co
 	return cols, added
 }
 
+// Union returns the columns with the columns of otherCols added, matched by
+// name. The types of matched columns are widened to hold the values of both.
+func (cols Columns) Union(otherCols Columns) (newCols Columns) {
+	newCols = cols.Clone()
+	fieldMap := newCols.FieldMap(true)
+	for _, col := range otherCols {
+		if i, ok := fieldMap[strings.ToLower(col.Name)]; ok {
+			newCols[i].Type = WidenType(newCols[i].Type, col.Type)
+			newCols[i].Sourced = newCols[i].Sourced && col.Sourced // inferred if any is inferred
+			newCols[i].Stats.MaxLen = lo.Max([]int{newCols[i].Stats.MaxLen, col.Stats.MaxLen})
+			newCols[i].Stats.MaxDecLen = lo.Max([]int{newCols[i].Stats.MaxDecLen, col.Stats.MaxDecLen})
+			continue
+		}
+
+		col.Position = len(newCols) + 1
+		fieldMap[strings.ToLower(col.Name)] = len(newCols)
+		newCols = append(newCols, col)
+	}
+	return newCols
+}
+
+// WidenType returns the narrowest type able to hold
+// the values of both types
+func WidenType(t1, t2 ColumnType) ColumnType {
+	intRank := map[ColumnType]int{SmallIntType: 1, IntegerType: 2, BigIntType: 3}
+
+	switch {
+	case t1 == t2 || t2 == "":
+		return t1
+	case t1 == "":
+		return t2
+	case t1.IsInteger() && t2.IsInteger():
+		return lo.Ternary(intRank[t1] > intRank[t2], t1, t2)
+	case t1.IsNumber() && t2.IsNumber():
+		// decimal, so that integers above 2^53 keep their precision
+		return DecimalType
+	case t1.IsDatetime() && t2.IsDatetime():
+		if t1 == TimestampzType || t2 == TimestampzType {
+			return TimestampzType
+		} else if t1 == DatetimeType || t2 == DatetimeType {
+			return DatetimeType
+		}
+		return TimestampType
+	case g.In(t1, TextType, JsonType) || g.In(t2, TextType, JsonType):
+		return TextType
+	}
+	return StringType
+}
+
 // IsSimilarTo returns true if has same number of columns
 // and contains the same columns, but may be in different order
 func (cols Columns) IsSimilarTo(otherCols Columns) bool {
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/flarco/g"
+	"github.com/samber/lo"
 	"github.com/spf13/cast"
 	"github.com/stretchr/testify/assert"
 )
@@ -338,3 +339,31 @@ func TestParseString(t *testing.T) {
 	val := sp.ParseString("1697104406")
 	assert.Equal(t, int64(1697104406), val)
 }
+
+func TestColumnsUnion(t *testing.T) {
+	cols1 := NewColumns(
+		Column{Name: "id", Type: SmallIntType, Sourced: true},
+		Column{Name: "amount", Type: IntegerType, Sourced: true},
+		Column{Name: "created", Type: DateType},
+	)
+	cols2 := NewColumns(
+		Column{Name: "created", Type: TimestampzType},
+		Column{Name: "ID", Type: BigIntType, Sourced: true},
+		Column{Name: "amount", Type: DecimalType},
+		Column{Name: "note", Type: StringType},
+	)
+
+	union := cols1.Union(cols2)
+	assert.Equal(t, []string{"id", "amount", "created", "note"}, union.Names())
+	assert.Equal(t, []ColumnType{BigIntType, DecimalType, TimestampzType, StringType}, lo.Map(union, func(c Column, i int) ColumnType { return c.Type }))
+	assert.Equal(t, IntegerType, cols1[1].Type) // not modified
+	assert.True(t, union[0].Sourced)
+	assert.False(t, union[1].Sourced) // inferred in cols2
+
+	assert.Equal(t, DecimalType, WidenType(BigIntType, FloatType))
+	assert.Equal(t, DecimalType, WidenType(FloatType, DecimalType))
+	assert.Equal(t, StringType, WidenType(BoolType, IntegerType))
+	assert.Equal(t, TextType, WidenType(JsonType, StringType))
+	assert.Equal(t, DatetimeType, WidenType(DateType, DatetimeType))
+	assert.Equal(t, IntegerType, WidenType(IntegerType, ""))
+}
@@ -0,0 +1,484 @@
+package iop
+
+import (
+	"bufio"
+	"encoding/csv"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/flarco/g"
+	"github.com/spf13/cast"
+	"gopkg.in/yaml.v2"
+)
+
+var fixedWidthListRegex = regexp.MustCompile(`^\d+(\s*,\s*\d+)*$`)
+
+// FixedWidthField is the position of a field in a fixed-width line
+type FixedWidthField struct {
+	Name  string     `json:"name" yaml:"name"`
+	Start int        `json:"start" yaml:"start"` // 1-based position of first char, 0 to follow the prior field
+	Width int        `json:"width" yaml:"width"`
+	Type  ColumnType `json:"type" yaml:"type"`
+	Align string     `json:"align" yaml:"align"` // left or right, used when writing
+}
+
+// End returns the 0-based position after the last char
+func (f FixedWidthField) End() int {
+	return f.Start - 1 + f.Width
+}
+
+// FixedWidthLayout is the list of fields of a fixed-width line
+type FixedWidthLayout []FixedWidthField
+
+// ParseFixedWidthLayout parses a fixed-width layout spec. The spec can be
+// a comma-separated list of widths (e.g. `10,5,8`), a JSON array of widths
+// or of fields (name, start, width, type, align), or the path of a layout
+// file (.json, .yaml or .csv with a header of field keys)
+func ParseFixedWidthLayout(spec string) (layout FixedWidthLayout, err error) {
+	spec = strings.TrimSpace(spec)
+
+	switch {
+	case spec == "":
+		return nil, g.Error("fixed-width layout not provided")
+	case fixedWidthListRegex.MatchString(spec):
+		for _, width := range strings.Split(spec, ",") {
+			layout = append(layout, FixedWidthField{Width: cast.ToInt(strings.TrimSpace(width))})
+		}
+	case strings.HasPrefix(spec, "["):
+		layout, err = parseFixedWidthItems([]byte(spec))
+		if err != nil {
+			return nil, g.Error(err, "could not parse fixed-width layout")
+		}
+	default:
+		layout, err = readFixedWidthLayoutFile(strings.TrimPrefix(spec, "file://"))
+		if err != nil {
+			return nil, g.Error(err, "could not read fixed-width layout file: %s", spec)
+		}
+	}
+
+	return layout.normalize()
+}
+
+// parseFixedWidthItems parses a JSON or YAML array of widths or fields
+func parseFixedWidthItems(data []byte) (layout FixedWidthLayout, err error) {
+	var items []any
+	if err = yaml.Unmarshal(data, &items); err != nil {
+		// may be an object with a `fields` key
+		obj := map[string][]any{}
+		if err2 := yaml.Unmarshal(data, &obj); err2 != nil || obj["fields"] == nil {
+			return nil, g.Error(err, "invalid layout")
+		}
+		items, err = obj["fields"], nil
+	}
+
+	for i, item := range items {
+		switch v := item.(type) {
+		case map[any]any, map[string]any:
+			m := cast.ToStringMap(v)
+			layout = append(layout, FixedWidthField{
+				Name:  cast.ToString(m["name"]),
+				Start: cast.ToInt(m["start"]),
+				Width: cast.ToInt(m["width"]),
+				Type:  ColumnType(cast.ToString(m["type"])),
+				Align: cast.ToString(m["align"]),
+			})
+		default:
+			width, err := cast.ToIntE(v)
+			if err != nil {
+				return nil, g.Error(err, "invalid width for field #%d: %#v", i+1, v)
+			}
+			layout = append(layout, FixedWidthField{Width: width})
+		}
+	}
+
+	return layout, nil
+}
+
+func readFixedWidthLayoutFile(path string) (layout FixedWidthLayout, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, g.Error(err, "could not read file")
+	}
+
+	if strings.ToLower(filepath.Ext(path)) != ".csv" {
+		return parseFixedWidthItems(data)
+	}
+
+	records, err := csv.NewReader(strings.NewReader(string(data))).ReadAll()
+	if err != nil {
+		return nil, g.Error(err, "could not parse csv layout")
+	} else if len(records) == 0 {
+		return nil, g.Error("empty csv layout")
+	}
+
+	header := records[0]
+	for i := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(header[i]))
+	}
+
+	for _, record := range records[1:] {
+		m := map[string]string{}
+		for i, val := range record {
+			if i < len(header) {
+				m[header[i]] = strings.TrimSpace(val)
+			}
+		}
+		layout = append(layout, FixedWidthField{
+			Name:  m["name"],
+			Start: cast.ToInt(m["start"]),
+			Width: cast.ToInt(m["width"]),
+			Type:  ColumnType(m["type"]),
+			Align: m["align"],
+		})
+	}
+
+	return layout, nil
+}
+
+// normalize validates the fields and sets missing start positions
+func (layout FixedWidthLayout) normalize() (FixedWidthLayout, error) {
+	if len(layout) == 0 {
+		return nil, g.Error("fixed-width layout has no fields")
+	}
+
+	pos := 1
+	for i, field := range layout {
+		if field.Width <= 0 {
+			return nil, g.Error("invalid width (%d) for fixed-width field #%d", field.Width, i+1)
+		} else if field.Start < 0 {
+			return nil, g.Error("invalid start (%d) for fixed-width field #%d", field.Start, i+1)
+		}
+		if field.Start == 0 {
+			field.Start = pos
+		}
+		field.Align = strings.ToLower(strings.TrimSpace(field.Align))
+		if !g.In(field.Align, "", "left", "right") {
+			return nil, g.Error("invalid align (%s) for fixed-width field #%d", field.Align, i+1)
+		}
+		layout[i] = field
+		pos = field.End() + 1
+	}
+
+	return layout, nil
+}
+
+// HasNames returns true if all fields are named
+func (layout FixedWidthLayout) HasNames() bool {
+	for _, field := range layout {
+		if strings.TrimSpace(field.Name) == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// Split slices a line into the trimmed values of the fields.
+// Positions are in characters, missing trailing chars yield empty values
+func (layout FixedWidthLayout) Split(line string) (values []string) {
+	runes := []rune(strings.TrimRight(line, "\r\n"))
+	values = make([]string, len(layout))
+	for i, field := range layout {
+		start, end := field.Start-1, field.End()
+		if start >= len(runes) {
+			continue
+		}
+		if end > len(runes) {
+			end = len(runes)
+		}
+		values[i] = strings.TrimSpace(string(runes[start:end]))
+	}
+	return values
+}
+
+// Line renders the values as a fixed-width line, padding
+// and truncating each value to the width of its field.
+// Number fields are right-aligned unless specified otherwise
+func (layout FixedWidthLayout) Line(values []string, types []ColumnType) string {
+	lineWidth := 0
+	for _, field := range layout {
+		if field.End() > lineWidth {
+			lineWidth = field.End()
+		}
+	}
+
+	runes := []rune(strings.Repeat(" ", lineWidth))
+	for i, field := range layout {
+		if i >= len(values) {
+			break
+		}
+
+		align := field.Align
+		if align == "" {
+			align = "left"
+			if i < len(types) && types[i].IsNumber() {
+				align = "right"
+			}
+		}
+
+		valRunes := []rune(strings.NewReplacer("\r", " ", "\n", " ").Replace(values[i]))
+		if len(valRunes) > field.Width {
+			valRunes = valRunes[:field.Width]
+		}
+
+		offset := field.Start - 1
+		if align == "right" {
+			offset = offset + field.Width - len(valRunes)
+		}
+		copy(runes[offset:], valRunes)
+	}
+	return string(runes)
+}
+
+// Columns returns the columns matching the layout fields. Unnamed
+// fields are named from the header row if provided, else by position
+func (layout FixedWidthLayout) Columns(header []string) (columns Columns) {
+	names := make([]string, len(layout))
+	for i, field := range layout {
+		names[i] = strings.TrimSpace(field.Name)
+		if names[i] == "" && i < len(header) {
+			names[i] = header[i]
+		}
+		if names[i] == "" {
+			names[i] = g.F("col_%03d", i+1)
+		}
+	}
+	return NewColumnsFromFields(CleanHeaderRow(names)...)
+}
+
+// matchColumns returns the layout reordered to match the provided columns,
+// by name if the layout is named, else by position
+func (layout FixedWidthLayout) matchColumns(columns Columns) (FixedWidthLayout, error) {
+	if !layout.HasNames() {
+		if len(layout) != len(columns) {
+			return nil, g.Error("fixed-width layout has %d fields, but stream has %d columns", len(layout), len(columns))
+		}
+		return layout, nil
+	}
+
+	fieldMap := map[string]FixedWidthField{}
+	for _, field := range layout {
+		fieldMap[strings.ToLower(strings.TrimSpace(field.Name))] = field
+	}
+
+	matched := make(FixedWidthLayout, len(columns))
+	for i, col := range columns {
+		field, ok := fieldMap[strings.ToLower(col.Name)]
+		if !ok {
+			return nil, g.Error("column '%s' not found in fixed-width layout", col.Name)
+		}
+		matched[i] = field
+	}
+	return matched, nil
+}
+
+// ConsumeFixedWidthReader uses the provided reader to stream rows
+// of fixed-width lines, sliced according to the layout option
+func (ds *Datastream) ConsumeFixedWidthReader(reader io.Reader) (err error) {
+	layout, err := ParseFixedWidthLayout(ds.config.Layout)
+	if err != nil {
+		err = g.Error(err, "could not parse fixed-width layout")
+		ds.Context.CaptureErr(err)
+		return err
+	}
+
+	reader2, err := AutoDecompress(reader)
+	if err != nil {
+		err = g.Error(err, "could not decompress reader")
+		ds.Context.CaptureErr(err)
+		return err
+	}
+
+	scanner := bufio.NewScanner(reader2)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+
+	nextLine := func() (line string, ok bool) {
+		for scanner.Scan() {
+			line = strings.TrimRight(scanner.Text(), "\r")
+			if ds.config.SkipBlankLines && strings.TrimSpace(line) == "" {
+				continue
+			}
+			return line, true
+		}
+		if scanner.Err() != nil {
+			ds.Context.CaptureErr(g.Error(scanner.Err(), "Error reading file"))
+		}
+		return "", false
+	}
+
+	line0, ok := nextLine()
+	if !ok {
+		if err = ds.Context.Err(); err != nil {
+			return err
+		}
+		g.Debug("fixed-width stream provided is empty")
+		ds.SetReady()
+		ds.Close()
+		return nil
+	}
+
+	// the first line is a header if the layout is unnamed and header is set,
+	// or if it matches the names of the layout
+	var header []string
+	values0 := layout.Split(line0)
+	isHeader := ds.config.Header && !layout.HasNames()
+	if layout.HasNames() {
+		isHeader = true
+		for i, field := range layout {
+			if !strings.EqualFold(values0[i], strings.TrimSpace(field.Name)) {
+				isHeader = false
+				break
+			}
+		}
+	}
+	if isHeader {
+		header = values0
+	}
+
+	ds.Columns = layout.Columns(header)
+
+	// apply the types of the layout, unless provided in columns option
+	for i, field := range layout {
+		if field.Type == "" {
+			continue
+		}
+		if _, found := ds.Sp.config.Columns.FieldMap(true)[strings.ToLower(ds.Columns[i].Name)]; !found {
+			ds.Sp.config.Columns = append(ds.Sp.config.Columns, Column{Name: ds.Columns[i].Name, Type: field.Type})
+		}
+	}
+
+	pendingLine := !isHeader // first line is data
+	nextFunc := func(it *Iterator) bool {
+		line := line0
+		if pendingLine {
+			pendingLine = false
+		} else if line, ok = nextLine(); !ok {
+			return false
+		}
+
+		values := layout.Split(line)
+		it.Row = make([]any, len(values))
+		for i, val := range values {
+			if val == "" && !it.ds.Columns[i].IsString() {
+				it.Row[i] = nil
+			} else {
+				it.Row[i] = val
+			}
+		}
+
+		return true
+	}
+
+	ds.it = ds.NewIterator(ds.Columns, nextFunc)
+
+	err = ds.Start()
+	if err != nil {
+		return g.Error(err, "could start datastream")
+	}
+
+	return
+}
+
+// NewFixedWidthReaderChnl provides a channel of readers of fixed-width
+// lines, as the limit is reached. Each field is padded or truncated
+// to the width of the layout option
+func (ds *Datastream) NewFixedWidthReaderChnl(rowLimit int, bytesLimit int64) (readerChn chan *BatchReader) {
+	readerChn = make(chan *BatchReader, 100)
+
+	pipeR, pipeW := io.Pipe()
+
+	tbw := int64(0)
+
+	go func() {
+		var br *BatchReader
+		var layout FixedWidthLayout
+		var types []ColumnType
+
+		defer close(readerChn)
+
+		baseLayout, err := ParseFixedWidthLayout(ds.config.Layout)
+		if err != nil {
+			ds.Context.CaptureErr(g.Error(err, "could not parse fixed-width layout"))
+			ds.Context.Cancel()
+			return
+		}
+
+		nextPipe := func(batch *Batch) error {
+			pipeW.Close() // close the prior reader
+			tbw = 0       // reset
+
+			// new reader
+			pipeR, pipeW = io.Pipe()
+			br = &BatchReader{batch, batch.Columns, pipeR, 0}
+			readerChn <- br
+
+			if ds.config.Header {
+				bw, err := pipeW.Write([]byte(layout.Line(batch.Columns.Names(), nil) + "\n"))
+				tbw = tbw + cast.ToInt64(bw)
+				if err != nil {
+					err = g.Error(err, "error writing header")
+					ds.Context.Cancel()
+					pipeW.Close()
+					return err
+				}
+			}
+
+			return nil
+		}
+
+		for batch := range ds.BatchChan {
+
+			if batch.ColumnsChanged() || batch.IsFirst() {
+				layout, err = baseLayout.matchColumns(batch.Columns)
+				if err != nil {
+					ds.Context.CaptureErr(err)
+					ds.Context.Cancel()
+					pipeW.Close()
+					return
+				}
+				types = make([]ColumnType, len(batch.Columns))
+				for i, col := range batch.Columns {
+					types[i] = col.Type
+				}
+
+				err = nextPipe(batch)
+				if err != nil {
+					ds.Context.CaptureErr(err)
+					return
+				}
+			}
+
+			for row := range batch.Rows {
+				br.Counter++
+
+				values := make([]string, len(row))
+				for i, val := range row {
+					values[i] = ds.Sp.CastToString(i, val, batch.Columns[i].Type)
+				}
+
+				bw, err := pipeW.Write([]byte(layout.Line(values, types) + "\n"))
+				tbw = tbw + cast.ToInt64(bw)
+				if err != nil {
+					ds.Context.CaptureErr(g.Error(err, "error writing row"))
+					ds.Context.Cancel()
+					pipeW.Close()
+					return
+				}
+
+				if (rowLimit > 0 && br.Counter >= rowLimit) || (bytesLimit > 0 && tbw >= bytesLimit) {
+					err = nextPipe(batch)
+					if err != nil {
+						ds.Context.CaptureErr(err)
+						return
+					}
+				}
+			}
+		}
+
+		pipeW.Close()
+	}()
+
+	return readerChn
+}
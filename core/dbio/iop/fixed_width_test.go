@@ -0,0 +1,107 @@
+package iop
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cast"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFixedWidthLayout(t *testing.T) {
+	layout, err := ParseFixedWidthLayout("3, 5,2")
+	if assert.NoError(t, err) {
+		assert.Len(t, layout, 3)
+		assert.Equal(t, []int{1, 4, 9}, []int{layout[0].Start, layout[1].Start, layout[2].Start})
+		assert.False(t, layout.HasNames())
+	}
+
+	layout, err = ParseFixedWidthLayout(`[{"name": "id", "width": 4, "type": "integer"}, {"name": "name", "start": 6, "width": 6, "align": "Right"}]`)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 1, layout[0].Start)
+		assert.Equal(t, 6, layout[1].Start)
+		assert.Equal(t, "right", layout[1].Align)
+		assert.Equal(t, IntegerType, layout[0].Type)
+		assert.True(t, layout.HasNames())
+	}
+
+	folder := t.TempDir()
+	csvPath := filepath.Join(folder, "layout.csv")
+	os.WriteFile(csvPath, []byte("Name,Start,Width\nid,1,4\nname,5,10\n"), 0644)
+	layout, err = ParseFixedWidthLayout(csvPath)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "name", layout[1].Name)
+		assert.Equal(t, 10, layout[1].Width)
+	}
+
+	yamlPath := filepath.Join(folder, "layout.yaml")
+	os.WriteFile(yamlPath, []byte("fields:\n  - name: id\n    width: 4\n  - name: name\n    width: 10\n"), 0644)
+	layout, err = ParseFixedWidthLayout("file://" + yamlPath)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 5, layout[1].Start)
+	}
+
+	_, err = ParseFixedWidthLayout("")
+	assert.Error(t, err)
+	_, err = ParseFixedWidthLayout("[3, 0]")
+	assert.Error(t, err)
+
+	layout, _ = ParseFixedWidthLayout("3,5")
+	assert.Equal(t, []string{"ab", "cdé"}, layout.Split(" ab  cdé  xyz"))
+	assert.Equal(t, []string{"ab", ""}, layout.Split("ab"))
+	assert.Equal(t, "123   45", layout.Line([]string{"1234567", "45"}, []ColumnType{StringType, IntegerType}))
+	assert.Equal(t, "ab cd   ", layout.Line([]string{"ab", "cd"}, nil))
+}
+
+func TestFixedWidthReadWrite(t *testing.T) {
+	layout := `[{"name": "id", "width": 4}, {"name": "name", "width": 8}, {"name": "amount", "width": 8}]`
+	input := strings.Join([]string{
+		"id  name    amount  ",
+		"1   Fred    10.5",
+		"",
+		"2   Barney  200     ",
+		"3           ",
+	}, "\n")
+
+	ds := NewDatastream(nil)
+	ds.SetConfig(map[string]string{"layout": layout, "skip_blank_lines": "true"})
+	err := ds.ConsumeFixedWidthReader(strings.NewReader(input))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	data, err := ds.Collect(0)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, []string{"id", "name", "amount"}, data.Columns.Names())
+	if assert.Len(t, data.Rows, 3) {
+		assert.Equal(t, "Fred", data.Rows[0][1])
+		assert.EqualValues(t, 200, cast.ToFloat64(data.Rows[1][2]))
+		assert.Nil(t, data.Rows[2][2])
+	}
+	assert.True(t, data.Columns[0].IsInteger())
+	assert.True(t, data.Columns[2].IsNumber())
+
+	// write back, without header and with truncation
+	data.Rows[0][1] = "Frederick the Great"
+	ds = data.Stream()
+	ds.SetConfig(map[string]string{"layout": layout, "header": "false"})
+
+	var output string
+	for br := range ds.NewFixedWidthReaderChnl(0, 0) {
+		b, err := io.ReadAll(br.Reader)
+		assert.NoError(t, err)
+		output = output + string(b)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(output, "\n"), "\n")
+	if assert.Len(t, lines, 3) {
+		assert.Equal(t, "   1Frederic    10.5", lines[0])
+		assert.Equal(t, "   3                ", lines[2])
+	}
+}
@@ -0,0 +1,362 @@
+package iop
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flarco/g"
+	"github.com/google/uuid"
+	"github.com/spf13/cast"
+	"syreclabs.com/go/faker"
+)
+
+// GenerateOptions is the specification of a synthetic dataset
+type GenerateOptions struct {
+	Rows    int64            `json:"rows" yaml:"rows"`
+	Seed    *int64           `json:"seed,omitempty" yaml:"seed,omitempty"`
+	Columns []GenerateColumn `json:"columns" yaml:"columns"`
+}
+
+// GenerateDistribution is the distribution of generated values
+type GenerateDistribution string
+
+const (
+	GenerateDistributionUniform     GenerateDistribution = "uniform"
+	GenerateDistributionNormal      GenerateDistribution = "normal"
+	GenerateDistributionExponential GenerateDistribution = "exponential"
+	GenerateDistributionSequence    GenerateDistribution = "sequence"
+)
+
+// GenerateColumn is the specification of a generated column.
+// Values are taken from Values if provided, else from the Faker
+// generator if provided, else drawn from the Distribution between
+// Min and Max, according to the Type.
+type GenerateColumn struct {
+	Name         string               `json:"name" yaml:"name"`
+	Type         ColumnType           `json:"type,omitempty" yaml:"type,omitempty"`
+	Faker        string               `json:"faker,omitempty" yaml:"faker,omitempty"`
+	Values       []any                `json:"values,omitempty" yaml:"values,omitempty"`
+	Distribution GenerateDistribution `json:"distribution,omitempty" yaml:"distribution,omitempty"`
+	Min          any                  `json:"min,omitempty" yaml:"min,omitempty"`
+	Max          any                  `json:"max,omitempty" yaml:"max,omitempty"`
+	Mean         *float64             `json:"mean,omitempty" yaml:"mean,omitempty"`
+	StdDev       *float64             `json:"std_dev,omitempty" yaml:"std_dev,omitempty"`
+	NullRate     float64              `json:"null_rate,omitempty" yaml:"null_rate,omitempty"`
+	Cardinality  int                  `json:"cardinality,omitempty" yaml:"cardinality,omitempty"`
+	PrimaryKey   bool                 `json:"primary_key,omitempty" yaml:"primary_key,omitempty"`
+}
+
+// fakerMux serializes the faker generators, which share a global source
+var fakerMux sync.Mutex
+
+// fakerFuncs are the faker generators available by name
+var fakerFuncs = map[string]func() any{
+	"name":         func() any { return faker.Name().Name() },
+	"first_name":   func() any { return faker.Name().FirstName() },
+	"last_name":    func() any { return faker.Name().LastName() },
+	"job_title":    func() any { return faker.Name().Title() },
+	"email":        func() any { return faker.Internet().Email() },
+	"username":     func() any { return faker.Internet().UserName() },
+	"url":          func() any { return faker.Internet().Url() },
+	"domain":       func() any { return faker.Internet().DomainName() },
+	"ipv4":         func() any { return faker.Internet().IpV4Address() },
+	"ipv6":         func() any { return faker.Internet().IpV6Address() },
+	"phone":        func() any { return faker.PhoneNumber().PhoneNumber() },
+	"company":      func() any { return faker.Company().Name() },
+	"address":      func() any { return faker.Address().StreetAddress() },
+	"city":         func() any { return faker.Address().City() },
+	"state":        func() any { return faker.Address().State() },
+	"country":      func() any { return faker.Address().Country() },
+	"country_code": func() any { return faker.Address().CountryCode() },
+	"zip_code":     func() any { return faker.Address().ZipCode() },
+	"latitude":     func() any { return faker.Address().Latitude() },
+	"longitude":    func() any { return faker.Address().Longitude() },
+	"product":      func() any { return faker.Commerce().ProductName() },
+	"color":        func() any { return faker.Commerce().Color() },
+	"price":        func() any { return faker.Commerce().Price() },
+	"word":         func() any { return faker.Lorem().Word() },
+	"sentence":     func() any { return faker.Lorem().Sentence(8) },
+	"paragraph":    func() any { return faker.Lorem().Paragraph(3) },
+}
+
+// generateColumn generates the values of a column
+type generateColumn struct {
+	GenerateColumn
+	rand     *rand.Rand
+	min, max float64
+	pool     []any // distinct values, when cardinality is set
+	gen      func(i int64) any
+}
+
+// NewGenerateDatastream returns a datastream of synthetic rows
+// following the options. Rows are generated as the datastream
+// is consumed, once started.
+func NewGenerateDatastream(ctx context.Context, opts GenerateOptions) (ds *Datastream, err error) {
+	if len(opts.Columns) == 0 {
+		return nil, g.Error("no columns provided to generate")
+	} else if opts.Rows < 0 {
+		return nil, g.Error("invalid number of rows to generate: %d", opts.Rows)
+	}
+
+	seed := time.Now().UnixNano()
+	if opts.Seed != nil {
+		seed = *opts.Seed
+	}
+	r := rand.New(rand.NewSource(seed))
+
+	columns := make(Columns, len(opts.Columns))
+	generators := make([]*generateColumn, len(opts.Columns))
+	for i, spec := range opts.Columns {
+		gc, err := newGenerateColumn(spec, r)
+		if err != nil {
+			return nil, g.Error(err, "invalid spec for column %s", spec.Name)
+		}
+		generators[i] = gc
+		columns[i] = Column{Name: spec.Name, Position: i + 1, Type: gc.Type}
+	}
+
+	if keys := generateKeys(opts.Columns); len(keys) > 0 {
+		if err = columns.SetKeys(PrimaryKey, keys...); err != nil {
+			return nil, g.Error(err, "could not set primary key")
+		}
+	}
+
+	nextFunc := func(it *Iterator) bool {
+		if int64(it.Counter) >= opts.Rows {
+			return false
+		}
+
+		it.Row = make([]any, len(generators))
+		for i, gc := range generators {
+			it.Row[i] = gc.value(int64(it.Counter))
+		}
+		return true
+	}
+
+	ds = NewDatastreamIt(ctx, columns, nextFunc)
+	ds.Inferred = true
+	return ds, nil
+}
+
+// generateKeys returns the names of the primary key columns
+func generateKeys(specs []GenerateColumn) (names []string) {
+	for _, spec := range specs {
+		if spec.PrimaryKey {
+			names = append(names, spec.Name)
+		}
+	}
+	return names
+}
+
+func newGenerateColumn(spec GenerateColumn, r *rand.Rand) (gc *generateColumn, err error) {
+	gc = &generateColumn{GenerateColumn: spec, rand: r}
+
+	if spec.Name == "" {
+		return nil, g.Error("column name is required")
+	} else if spec.NullRate < 0 || spec.NullRate > 1 {
+		return nil, g.Error("null_rate must be between 0 and 1")
+	} else if spec.Cardinality < 0 {
+		return nil, g.Error("cardinality must be positive")
+	}
+
+	gc.Type = ColumnType(strings.ToLower(string(spec.Type)))
+	gc.Faker = strings.ToLower(spec.Faker)
+	gc.Distribution = GenerateDistribution(strings.ToLower(string(spec.Distribution)))
+	if gc.Distribution == "" {
+		gc.Distribution = GenerateDistributionUniform
+	}
+
+	if gc.Type == "" {
+		gc.Type = StringType
+		if len(spec.Values) > 0 {
+			gc.Type = NewDataset(nil).Sp.GetType(spec.Values[0])
+		} else if gc.Distribution == GenerateDistributionSequence {
+			gc.Type = BigIntType
+		}
+	}
+	if !gc.Type.IsValid() {
+		return nil, g.Error("invalid column type: %s", gc.Type)
+	}
+
+	switch gc.Distribution {
+	case GenerateDistributionUniform, GenerateDistributionNormal,
+		GenerateDistributionExponential, GenerateDistributionSequence:
+	default:
+		return nil, g.Error("invalid distribution: %s", gc.Distribution)
+	}
+
+	if err = gc.setRange(); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case len(spec.Values) > 0:
+		gc.gen = func(i int64) any {
+			if gc.Distribution == GenerateDistributionSequence {
+				return spec.Values[i%int64(len(spec.Values))]
+			}
+			return spec.Values[gc.index(len(spec.Values))]
+		}
+	case gc.Faker == "uuid":
+		gc.gen = func(i int64) any {
+			id, _ := uuid.NewRandomFromReader(gc.rand)
+			return id.String()
+		}
+	case gc.Faker != "":
+		fakerFunc, ok := fakerFuncs[gc.Faker]
+		if !ok {
+			return nil, g.Error("invalid faker generator: %s", spec.Faker)
+		}
+		gc.gen = func(i int64) any { return gc.fake(fakerFunc) }
+	default:
+		gc.gen = gc.typeValue
+	}
+
+	if spec.Cardinality > 0 {
+		gc.pool = make([]any, spec.Cardinality)
+		seen := map[string]bool{}
+		for i := int64(0); i < int64(spec.Cardinality); i++ {
+			val := gc.gen(i)
+			for tries := 0; seen[cast.ToString(val)] && tries < 100; tries++ {
+				val = gc.gen(i) // try for a distinct value
+			}
+			seen[cast.ToString(val)] = true
+			gc.pool[i] = val
+		}
+	}
+
+	return gc, nil
+}
+
+// setRange sets the range of the values drawn from the distribution.
+// Dates are ranged as unix seconds, and default to the last year.
+func (gc *generateColumn) setRange() (err error) {
+	toFloat := func(val any) (float64, error) {
+		if gc.Type.IsDate() || gc.Type.IsDatetime() {
+			t, err := cast.ToTimeE(val)
+			return float64(t.Unix()), err
+		}
+		return cast.ToFloat64E(val)
+	}
+
+	switch {
+	case gc.Type.IsDate() || gc.Type.IsDatetime():
+		now := time.Now().UTC().Truncate(time.Second)
+		gc.min, gc.max = float64(now.AddDate(-1, 0, 0).Unix()), float64(now.Unix())
+	case gc.Type.IsNumber() && gc.Distribution == GenerateDistributionSequence:
+		gc.min, gc.max = 1, math.MaxInt64 // ids
+	case gc.Type.IsNumber():
+		gc.min, gc.max = 0, 1000
+	case gc.Distribution == GenerateDistributionSequence:
+		gc.min, gc.max = 1, math.MaxInt64
+	default:
+		gc.min, gc.max = 0, 1
+	}
+
+	if gc.Min != nil {
+		if gc.min, err = toFloat(gc.Min); err != nil {
+			return g.Error(err, "invalid min value: %v", gc.Min)
+		}
+	}
+	if gc.Max != nil {
+		if gc.max, err = toFloat(gc.Max); err != nil {
+			return g.Error(err, "invalid max value: %v", gc.Max)
+		}
+	}
+	if gc.min > gc.max {
+		return g.Error("min value is greater than max value")
+	}
+	return nil
+}
+
+// value returns the value of the column for the row index
+func (gc *generateColumn) value(i int64) any {
+	if gc.NullRate > 0 && gc.rand.Float64() < gc.NullRate {
+		return nil
+	} else if len(gc.pool) > 0 {
+		return gc.pool[gc.index(len(gc.pool))]
+	}
+	return gc.gen(i)
+}
+
+// sample draws a number from the distribution, within the range
+func (gc *generateColumn) sample(i int64) float64 {
+	var val float64
+	switch gc.Distribution {
+	case GenerateDistributionSequence:
+		step := 1.0
+		if gc.Type.IsDate() {
+			step = 86400 // a day
+		}
+		return gc.min + float64(i)*step
+	case GenerateDistributionNormal:
+		mean, stdDev := (gc.min+gc.max)/2, (gc.max-gc.min)/6
+		if gc.Mean != nil {
+			mean = *gc.Mean
+		}
+		if gc.StdDev != nil {
+			stdDev = *gc.StdDev
+		}
+		val = mean + gc.rand.NormFloat64()*stdDev
+	case GenerateDistributionExponential:
+		mean := (gc.max - gc.min) / 4
+		if gc.Mean != nil {
+			mean = *gc.Mean - gc.min
+		}
+		val = gc.min + gc.rand.ExpFloat64()*mean
+	default:
+		val = gc.min + gc.rand.Float64()*(gc.max-gc.min)
+	}
+	return math.Max(gc.min, math.Min(gc.max, val))
+}
+
+// index draws the index of a list value from the distribution
+func (gc *generateColumn) index(n int) int {
+	var ratio float64
+	switch gc.Distribution {
+	case GenerateDistributionNormal:
+		ratio = 0.5 + gc.rand.NormFloat64()/6
+	case GenerateDistributionExponential:
+		ratio = gc.rand.ExpFloat64() / 4
+	default:
+		ratio = gc.rand.Float64()
+	}
+	i := int(ratio * float64(n))
+	return int(math.Max(0, math.Min(float64(n-1), float64(i))))
+}
+
+// typeValue generates a value of the column type
+func (gc *generateColumn) typeValue(i int64) any {
+	switch {
+	case gc.Type.IsBool():
+		return gc.rand.Float64() < 0.5
+	case gc.Type.IsInteger():
+		return int64(math.Round(gc.sample(i)))
+	case gc.Type.IsNumber():
+		return math.Round(gc.sample(i)*100) / 100
+	case gc.Type.IsDate():
+		return time.Unix(int64(gc.sample(i)), 0).UTC().Truncate(24 * time.Hour)
+	case gc.Type.IsDatetime():
+		return time.Unix(int64(gc.sample(i)), 0).UTC()
+	case gc.Type.IsJSON():
+		return g.Marshal(g.M("id", i, "value", gc.fake(fakerFuncs["word"])))
+	case gc.Distribution == GenerateDistributionSequence:
+		return g.F("%s_%d", gc.Name, int64(gc.sample(i)))
+	default:
+		return gc.fake(fakerFuncs["word"])
+	}
+}
+
+// fake returns a value of the faker generator. Faker draws from a global
+// source, seeded from the stream source for every value, so that streams
+// are reproducible.
+func (gc *generateColumn) fake(fakerFunc func() any) any {
+	fakerMux.Lock()
+	defer fakerMux.Unlock()
+	faker.Seed(gc.rand.Int63())
+	return fakerFunc()
+}
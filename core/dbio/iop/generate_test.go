@@ -0,0 +1,80 @@
+package iop
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/flarco/g"
+	"github.com/spf13/cast"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateDatastream(t *testing.T) {
+	opts := GenerateOptions{
+		Rows: 500,
+		Seed: g.Int64(42),
+		Columns: []GenerateColumn{
+			{Name: "id", Distribution: GenerateDistributionSequence, PrimaryKey: true},
+			{Name: "email", Faker: "email"},
+			{Name: "status", Values: []any{"new", "active", "closed"}},
+			{Name: "amount", Type: DecimalType, Distribution: GenerateDistributionNormal, Min: 10, Max: 20},
+			{Name: "code", Type: IntegerType, Cardinality: 5, Min: 1, Max: 1000},
+			{Name: "created", Type: DateType, Min: "2024-01-01", Max: "2024-01-31"},
+			{Name: "note", Faker: "sentence", NullRate: 1},
+			{Name: "label", Type: StringType},
+		},
+	}
+
+	ds, err := NewGenerateDatastream(context.Background(), opts)
+	require.NoError(t, err)
+	require.NoError(t, ds.Start())
+	data, err := ds.Collect(0)
+	require.NoError(t, err)
+
+	assert.Len(t, data.Rows, 500)
+	assert.Equal(t, []string{"id"}, data.Columns.GetKeys(PrimaryKey).Names())
+	assert.Equal(t, BigIntType, data.Columns[0].Type)
+	assert.Equal(t, StringType, data.Columns[2].Type)
+
+	codes := map[int]bool{}
+	for i, row := range data.Rows {
+		assert.EqualValues(t, i+1, cast.ToInt(row[0]))
+		assert.Contains(t, cast.ToString(row[1]), "@")
+		assert.Contains(t, []any{"new", "active", "closed"}, row[2])
+		amount := cast.ToFloat64(row[3])
+		assert.True(t, amount >= 10 && amount <= 20, "amount %f out of range", amount)
+		codes[cast.ToInt(row[4])] = true
+		created := cast.ToTime(row[5])
+		assert.False(t, created.Before(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)))
+		assert.False(t, created.After(time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)))
+		assert.Nil(t, row[6])
+	}
+	assert.LessOrEqual(t, len(codes), 5)
+
+	// same seed, same values, while other streams draw faker values
+	other, err := NewGenerateDatastream(context.Background(), GenerateOptions{Rows: 500, Columns: opts.Columns[1:2]})
+	require.NoError(t, err)
+	require.NoError(t, other.Start())
+	go other.Collect(0)
+
+	ds, err = NewGenerateDatastream(context.Background(), opts)
+	require.NoError(t, err)
+	require.NoError(t, ds.Start())
+	data2, err := ds.Collect(0)
+	require.NoError(t, err)
+	assert.Equal(t, data.Rows[10][3], data2.Rows[10][3])
+	assert.Equal(t, data.ColValuesStr(1), data2.ColValuesStr(1))
+	assert.Equal(t, data.ColValuesStr(7), data2.ColValuesStr(7))
+
+	// invalid specs
+	_, err = NewGenerateDatastream(context.Background(), GenerateOptions{Rows: 1})
+	assert.Error(t, err)
+	_, err = NewGenerateDatastream(context.Background(), GenerateOptions{Rows: 1, Columns: []GenerateColumn{{Name: "a", Faker: "unknown"}}})
+	assert.Error(t, err)
+	_, err = NewGenerateDatastream(context.Background(), GenerateOptions{Rows: 1, Columns: []GenerateColumn{{Name: "a", Type: IntegerType, Min: 5, Max: 1}}})
+	assert.Error(t, err)
+	_, err = NewGenerateDatastream(context.Background(), GenerateOptions{Rows: 1, Columns: []GenerateColumn{{Name: "a", Distribution: "zipf"}}})
+	assert.Error(t, err)
+}
@@ -26,6 +26,8 @@ type jsonStream struct {
 	jmespath string
 	flatten  bool
 	buffer   chan []interface{}
+
+	normalizer *normalizer // splits nested arrays into child streams
 }
 
 func NewJSONStream(ds *Datastream, decoder decoderLike, flatten bool, jmespath string) *jsonStream {
@@ -38,7 +40,11 @@ func NewJSONStream(ds *Datastream, decoder decoderLike, flatten bool, jmespath s
 		buffer:    make(chan []interface{}, 100000),
 		sp:        NewStreamProcessor(),
 	}
-	if !flatten {
+	if ds.Sp.config.Normalize {
+		js.flatten = true
+		js.normalizer = newNormalizer(ds)
+		ds.normalizer = js.normalizer
+	} else if !flatten {
 		col := &Column{Position: 1, Name: "data", Type: JsonType}
 		js.ColumnMap[col.Name] = col
 		js.addColumn(*col)
@@ -174,6 +180,10 @@ func (js *jsonStream) parseRecords(records []map[string]interface{}) {
 			continue
 		}
 
+		if js.normalizer != nil {
+			rec = js.normalizer.Normalize(rec)
+		}
+
 		newRec, _ := flat.Flatten(rec, &flat.Options{Delimiter: "__", Safe: true})
 		keys := lo.Keys(newRec)
 		sort.Strings(keys)
@@ -0,0 +1,298 @@
+package iop
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/flarco/g"
+	"github.com/shopspring/decimal"
+	"github.com/spf13/cast"
+)
+
+// LookupMissing is the behavior when a row has no match in the reference dataset
+type LookupMissing string
+
+const (
+	LookupMissingNull    LookupMissing = "null"
+	LookupMissingDefault LookupMissing = "default"
+	LookupMissingReject  LookupMissing = "reject"
+)
+
+// LookupOptions enrich the rows of a stream with the columns of a
+// reference dataset, matched on keys. The reference is loaded in memory once.
+type LookupOptions struct {
+	Conn      string            `json:"conn,omitempty" yaml:"conn,omitempty"`             // connection of the reference, local file if blank
+	Object    string            `json:"object,omitempty" yaml:"object,omitempty"`         // table, sql query or file path
+	Keys      map[string]string `json:"keys,omitempty" yaml:"keys,omitempty"`             // stream column -> reference column
+	Columns   []string          `json:"columns,omitempty" yaml:"columns,omitempty"`       // reference columns to add, all non-key columns by default
+	OnMissing LookupMissing     `json:"on_missing,omitempty" yaml:"on_missing,omitempty"` // null (default), default or reject
+	Defaults  map[string]any    `json:"defaults,omitempty" yaml:"defaults,omitempty"`     // values added on missing match, with on_missing: default
+}
+
+// Validate checks the lookup options
+func (o *LookupOptions) Validate() error {
+	o.OnMissing = LookupMissing(strings.ToLower(string(o.OnMissing)))
+	if o.OnMissing == "" {
+		o.OnMissing = LookupMissingNull
+	}
+
+	switch {
+	case o.Object == "":
+		return g.Error("lookup requires an object (table, query or file)")
+	case len(o.Keys) == 0:
+		return g.Error("lookup requires at least one key")
+	case !g.In(o.OnMissing, LookupMissingNull, LookupMissingDefault, LookupMissingReject):
+		return g.Error("invalid lookup on_missing '%s'. Expected null, default or reject", o.OnMissing)
+	case len(o.Defaults) > 0 && o.OnMissing != LookupMissingDefault:
+		g.Warn("lookup defaults are ignored unless on_missing is 'default'")
+	}
+
+	if len(o.Columns) > 0 {
+		columns := map[string]bool{}
+		for _, col := range o.Columns {
+			columns[strings.ToLower(col)] = true
+		}
+		for col := range o.Defaults {
+			if !columns[strings.ToLower(col)] {
+				return g.Error("lookup default '%s' is not one of the lookup columns", col)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Lookup is a reference dataset indexed by its key values
+type Lookup struct {
+	Columns Columns // columns added to the stream
+
+	keys      []string // stream key columns, sorted
+	numeric   []bool   // whether each key is compared as a number
+	ref       Dataset
+	refKeyIdx []int
+	colIdx    []int
+	index     map[string][]any
+	nulls     []any
+	defaults  []any
+	reject    bool
+	err       error
+	mux       sync.Mutex
+}
+
+// NewLookup indexes the reference dataset on the keys of the options.
+// When a key has several reference rows, the first one is used.
+func NewLookup(opts *LookupOptions, ref Dataset) (l *Lookup, err error) {
+	if err = opts.Validate(); err != nil {
+		return nil, g.Error(err, "invalid lookup options")
+	}
+
+	l = &Lookup{
+		ref:    ref,
+		reject: opts.OnMissing == LookupMissingReject,
+	}
+
+	refFieldMap := ref.Columns.FieldMap(true)
+	l.keys = make([]string, 0, len(opts.Keys))
+	for key := range opts.Keys {
+		l.keys = append(l.keys, key)
+	}
+	sort.Strings(l.keys)
+	for _, key := range l.keys {
+		i, ok := refFieldMap[strings.ToLower(opts.Keys[key])]
+		if !ok {
+			return nil, g.Error("lookup key '%s' not found in reference columns", opts.Keys[key])
+		}
+		l.refKeyIdx = append(l.refKeyIdx, i)
+		l.numeric = append(l.numeric, ref.Columns[i].IsNumber())
+	}
+
+	// columns to add, all non-key columns by default
+	if len(opts.Columns) == 0 {
+		for i := range ref.Columns {
+			if !g.In(i, l.refKeyIdx...) {
+				l.colIdx = append(l.colIdx, i)
+			}
+		}
+	} else {
+		for _, name := range opts.Columns {
+			i, ok := refFieldMap[strings.ToLower(name)]
+			if !ok {
+				return nil, g.Error("lookup column '%s' not found in reference columns", name)
+			}
+			l.colIdx = append(l.colIdx, i)
+		}
+	}
+
+	defaults := map[string]any{}
+	for k, v := range opts.Defaults {
+		defaults[strings.ToLower(k)] = v
+	}
+
+	for _, i := range l.colIdx {
+		l.Columns = append(l.Columns, Column{Name: ref.Columns[i].Name, Type: ref.Columns[i].Type})
+		l.nulls = append(l.nulls, nil)
+		l.defaults = append(l.defaults, defaults[strings.ToLower(ref.Columns[i].Name)])
+	}
+	if opts.OnMissing != LookupMissingDefault {
+		l.defaults = l.nulls
+	}
+
+	if duplicates := l.indexRows(); duplicates > 0 {
+		g.Warn("lookup reference has %d duplicate keys, using the first row of each", duplicates)
+	}
+
+	return l, nil
+}
+
+// indexRows indexes the reference rows by key, returning the number of
+// duplicate keys
+func (l *Lookup) indexRows() (duplicates int) {
+	l.index = map[string][]any{}
+	for _, row := range l.ref.Rows {
+		key, ok := l.lookupKey(row, l.refKeyIdx)
+		if !ok {
+			continue // null keys never match
+		} else if _, exists := l.index[key]; exists {
+			duplicates++
+			continue
+		}
+
+		vals := make([]any, len(l.colIdx))
+		for j, i := range l.colIdx {
+			if i < len(row) {
+				vals[j] = row[i]
+			}
+		}
+		l.index[key] = vals
+	}
+	return duplicates
+}
+
+// Len returns the number of keys of the reference dataset
+func (l *Lookup) Len() int {
+	return len(l.index)
+}
+
+// Err returns the error of a rejected row, if any
+func (l *Lookup) Err() error {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	return l.err
+}
+
+// Apply returns the stream with the lookup columns appended to each row.
+// With on_missing: reject, the stream stops at the first row without match.
+func (l *Lookup) Apply(ds *Datastream) (nDs *Datastream, err error) {
+	fieldMap := ds.Columns.FieldMap(true)
+
+	keyIdx := make([]int, len(l.keys))
+	reindex := false
+	for j, key := range l.keys {
+		i, ok := fieldMap[strings.ToLower(key)]
+		if !ok {
+			return nil, g.Error("lookup key '%s' not found in stream columns", key)
+		}
+		keyIdx[j] = i
+
+		// compare as numbers if either side is numeric
+		if !l.numeric[j] && ds.Columns[i].IsNumber() {
+			l.numeric[j] = true
+			reindex = true
+		}
+	}
+	if reindex {
+		l.indexRows()
+	}
+
+	newColumns := ds.Columns.Clone()
+	for _, col := range l.Columns {
+		if _, ok := fieldMap[strings.ToLower(col.Name)]; ok {
+			return nil, g.Error("lookup column '%s' already exists in stream", col.Name)
+		}
+		col.Position = len(newColumns) + 1
+		newColumns = append(newColumns, col)
+	}
+
+	rows := MakeRowsChan()
+	nextFunc := func(it *Iterator) bool {
+		for it.Row = range rows {
+			return true
+		}
+		return false
+	}
+	nDs = NewDatastreamIt(ds.Context.Ctx, newColumns, nextFunc)
+	ds.it.IsCasted = true
+	ds.Inferred = true
+
+	nCols := len(ds.Columns)
+	go func() {
+		defer close(rows)
+		rejected := false
+		for batch0 := range ds.BatchChan {
+			for row := range batch0.Rows {
+				if rejected {
+					continue // drain the source, without emitting
+				}
+
+				key, ok := l.lookupKey(row, keyIdx)
+				vals, found := l.index[key]
+				if !ok || !found {
+					if l.reject {
+						l.rejectRow(ds, row, keyIdx)
+						rejected = true
+						continue
+					}
+					vals = l.defaults
+				}
+
+				newRow := make([]any, nCols+len(vals))
+				copy(newRow, row)
+				copy(newRow[nCols:], vals)
+				rows <- newRow
+			}
+		}
+	}()
+
+	if err = nDs.Start(); err != nil {
+		return nil, g.Error(err, "could not start lookup stream")
+	}
+
+	return nDs, nil
+}
+
+// rejectRow records the error of a row without match and stops the stream
+func (l *Lookup) rejectRow(ds *Datastream, row []any, keyIdx []int) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	if l.err != nil {
+		return
+	}
+
+	keyVals := make([]string, len(keyIdx))
+	for j, i := range keyIdx {
+		if i < len(row) {
+			keyVals[j] = cast.ToString(row[i])
+		}
+	}
+	l.err = g.Error("no lookup match for %s = %s", strings.Join(l.keys, ", "), strings.Join(keyVals, ", "))
+	ds.Context.CaptureErr(l.err)
+}
+
+// lookupKey returns the index key of the row, false if a key value is null.
+// Numeric keys are normalized, so that 1, 1.0 and "1.00" match.
+func (l *Lookup) lookupKey(row []any, keyIdx []int) (string, bool) {
+	vals := make([]string, len(keyIdx))
+	for j, i := range keyIdx {
+		if i >= len(row) || row[i] == nil {
+			return "", false
+		}
+		vals[j] = cast.ToString(row[i])
+		if l.numeric[j] {
+			if d, err := decimal.NewFromString(strings.TrimSpace(vals[j])); err == nil {
+				vals[j] = d.String()
+			}
+		}
+	}
+	return strings.Join(vals, "\x00"), true
+}
@@ -0,0 +1,130 @@
+package iop
+
+import (
+	"context"
+	"testing"
+
+	"github.com/spf13/cast"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookup(t *testing.T) {
+	ref := NewDataset(NewColumnsFromFields("code", "country", "region"))
+	ref.Append([]any{"US", "United States", "Americas"})
+	ref.Append([]any{"FR", "France", "Europe"})
+	ref.Append([]any{"FR", "France (dup)", "Europe"})
+
+	orders := func() *Datastream {
+		data := NewDataset(NewColumnsFromFields("id", "country_code"))
+		data.Append([]any{1, "FR"})
+		data.Append([]any{2, "US"})
+		data.Append([]any{3, "JP"})
+		data.Append([]any{4, nil})
+
+		i := 0
+		nextFunc := func(it *Iterator) bool {
+			if i >= len(data.Rows) {
+				return false
+			}
+			it.Row = data.Rows[i]
+			i++
+			return true
+		}
+		ds := NewDatastreamIt(context.Background(), data.Columns, nextFunc)
+		require.NoError(t, ds.Start())
+		return ds
+	}
+
+	enrich := func(opts LookupOptions) (data Dataset, lookup *Lookup, err error) {
+		lookup, err = NewLookup(&opts, ref)
+		require.NoError(t, err)
+
+		nDs, err := lookup.Apply(orders())
+		if err != nil {
+			return
+		}
+		data, err = nDs.Collect(0)
+		return
+	}
+
+	// all non-key columns, nulls on missing match
+	data, _, err := enrich(LookupOptions{Object: "countries.csv", Keys: map[string]string{"country_code": "code"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"id", "country_code", "country", "region"}, data.Columns.Names())
+	if assert.Len(t, data.Rows, 4) {
+		assert.Equal(t, "France", data.Rows[0][2]) // first row of duplicate key
+		assert.Equal(t, "Americas", data.Rows[1][3])
+		assert.Nil(t, data.Rows[2][2])
+		assert.Nil(t, data.Rows[3][2])
+	}
+
+	// selected columns with defaults
+	data, _, err = enrich(LookupOptions{
+		Object:    "countries.csv",
+		Keys:      map[string]string{"country_code": "code"},
+		Columns:   []string{"region"},
+		OnMissing: "default",
+		Defaults:  map[string]any{"region": "Unknown"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"id", "country_code", "region"}, data.Columns.Names())
+	regions := []string{}
+	for _, row := range data.Rows {
+		regions = append(regions, cast.ToString(row[2]))
+	}
+	assert.Equal(t, []string{"Europe", "Americas", "Unknown", "Unknown"}, regions)
+
+	// reject, without emitting the rejected row
+	data, lookup, _ := enrich(LookupOptions{Object: "countries.csv", Keys: map[string]string{"country_code": "code"}, OnMissing: "reject"})
+	if assert.Error(t, lookup.Err()) {
+		assert.Contains(t, lookup.Err().Error(), "no lookup match for country_code = JP")
+	}
+	for _, row := range data.Rows {
+		assert.NotEqual(t, "JP", row[1])
+	}
+
+	// numeric keys match whatever their formatting
+	priceRef := NewDataset(Columns{{Name: "amount", Type: DecimalType}, {Name: "tier", Type: StringType}})
+	priceRef.Append([]any{1.0, "low"})
+	priceRef.Append([]any{250, "high"})
+	lookup, err = NewLookup(&LookupOptions{Object: "tiers.csv", Keys: map[string]string{"price": "amount"}}, priceRef)
+	require.NoError(t, err)
+	prices := NewDataset(Columns{{Name: "price", Type: StringType}})
+	prices.Append([]any{"1.00"})
+	prices.Append([]any{"250.0"})
+	i := 0
+	pricesDs := NewDatastreamIt(context.Background(), prices.Columns, func(it *Iterator) bool {
+		if i >= len(prices.Rows) {
+			return false
+		}
+		it.Row = prices.Rows[i]
+		i++
+		return true
+	})
+	require.NoError(t, pricesDs.Start())
+	nDs, err := lookup.Apply(pricesDs)
+	require.NoError(t, err)
+	data, err = nDs.Collect(0)
+	require.NoError(t, err)
+	tiers := []string{}
+	for _, row := range data.Rows {
+		tiers = append(tiers, cast.ToString(row[1]))
+	}
+	assert.Equal(t, []string{"low", "high"}, tiers)
+
+	// unknown key columns
+	_, err = NewLookup(&LookupOptions{Object: "countries.csv", Keys: map[string]string{"country_code": "iso"}}, ref)
+	assert.Error(t, err)
+	lookup, err = NewLookup(&LookupOptions{Object: "countries.csv", Keys: map[string]string{"cc": "code"}}, ref)
+	require.NoError(t, err)
+	_, err = lookup.Apply(orders())
+	assert.Error(t, err)
+
+	// validation
+	assert.Error(t, (&LookupOptions{Keys: map[string]string{"a": "b"}}).Validate())
+	assert.Error(t, (&LookupOptions{Object: "ref"}).Validate())
+	assert.Error(t, (&LookupOptions{Object: "ref", Keys: map[string]string{"a": "b"}, OnMissing: "skip"}).Validate())
+	assert.Error(t, (&LookupOptions{Object: "ref", Keys: map[string]string{"a": "b"}, Columns: []string{"c"}, Defaults: map[string]any{"d": 1}}).Validate())
+	assert.NoError(t, (&LookupOptions{Object: "ref", Keys: map[string]string{"a": "b"}}).Validate())
+}
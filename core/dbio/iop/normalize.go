@@ -0,0 +1,285 @@
+package iop
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/flarco/g"
+	"github.com/nqd/flat"
+	"github.com/samber/lo"
+	"github.com/spf13/cast"
+)
+
+const (
+	// NormalizeIDColumn is the generated key of normalized records
+	NormalizeIDColumn = "_sling_id"
+	// NormalizeParentIDColumn is the key of the parent of child records
+	NormalizeParentIDColumn = "_sling_parent_id"
+	// NormalizeIndexColumn is the position of child records in the parent array
+	NormalizeIndexColumn = "_sling_index"
+)
+
+// normalizer splits the nested arrays of objects of records into child
+// streams, named after the path of the array, such as `items` or
+// `items__options`. Each record gets a generated key, which is a hash of
+// its position and content, and child records reference their parent key.
+// The child streams are announced as they appear, and must be consumed
+// concurrently with the parent stream.
+type normalizer struct {
+	ds       *Datastream // the parent stream
+	count    uint64      // the number of normalized records
+	children map[string]*childStream
+	streamCh chan *ChildStream
+	closed   bool
+	mux      sync.Mutex
+}
+
+// ChildStream is the stream of the records of a nested array
+type ChildStream struct {
+	Name string
+	*Datastream
+}
+
+// childStream holds the columns of a child stream, and the rows
+// received before the child stream is consumed by a dataflow
+type childStream struct {
+	*ChildStream
+	columns   Columns
+	columnMap map[string]int
+	pending   [][]any
+	notify    chan struct{}
+	rows      chan []any
+	done      chan struct{}
+	mux       sync.Mutex
+}
+
+func newNormalizer(ds *Datastream) *normalizer {
+	return &normalizer{
+		ds:       ds,
+		children: map[string]*childStream{},
+		streamCh: make(chan *ChildStream, 1000),
+	}
+}
+
+// Normalize generates the key of the record, and moves its nested
+// arrays of objects into child streams. The record number is part of
+// the key, so that identical records get distinct keys.
+func (n *normalizer) Normalize(rec map[string]any) map[string]any {
+	n.count++
+	id := g.MD5(cast.ToString(n.count), g.Marshal(rec))
+	n.extractChildren(rec, "", id)
+	rec[NormalizeIDColumn] = id
+	return rec
+}
+
+// extractChildren moves the arrays of objects of the record (and of its
+// nested objects) into child records. The prefix is the child name prefix.
+func (n *normalizer) extractChildren(rec map[string]any, prefix, parentID string) {
+	for _, key := range lo.Keys(rec) {
+		name := prefix + key
+		switch value := rec[key].(type) {
+		case map[string]any:
+			n.extractChildren(value, name+"__", parentID)
+		case map[any]any:
+			nested := toStringMap(value)
+			rec[key] = nested
+			n.extractChildren(nested, name+"__", parentID)
+		case []any:
+			if !lo.SomeBy(value, isObject) {
+				continue // keep arrays of values
+			}
+			for i, item := range value {
+				child, ok := item.(map[string]any)
+				if m, isAnyMap := item.(map[any]any); isAnyMap {
+					child, ok = toStringMap(m), true
+				}
+				if !ok {
+					child = map[string]any{"data": item}
+				}
+				n.addChild(name, parentID, i, child)
+			}
+			delete(rec, key)
+		}
+	}
+}
+
+// addChild normalizes the child record, and sends it to its child stream
+func (n *normalizer) addChild(name, parentID string, index int, rec map[string]any) {
+	id := g.MD5(parentID, name, cast.ToString(index), g.Marshal(rec))
+	n.extractChildren(rec, name+"__", id)
+
+	flatRec, _ := flat.Flatten(rec, &flat.Options{Delimiter: "__", Safe: true})
+	for key, value := range flatRec {
+		if arr, ok := value.([]any); ok {
+			flatRec[key] = g.Marshal(arr) // cast arrays as string
+		}
+	}
+
+	n.mux.Lock()
+	if n.closed {
+		n.mux.Unlock()
+		return
+	}
+
+	child, ok := n.children[name]
+	if !ok {
+		child = &childStream{columnMap: map[string]int{}}
+		for _, colName := range []string{NormalizeParentIDColumn, NormalizeIndexColumn, NormalizeIDColumn} {
+			child.addColumn(colName)
+		}
+	}
+
+	keys := lo.Keys(flatRec)
+	sort.Strings(keys)
+	colsToAdd := Columns{}
+	for _, key := range keys {
+		if col, added := child.addColumn(key); added {
+			colsToAdd = append(colsToAdd, col)
+		}
+	}
+
+	if !ok {
+		n.startChild(name, child)
+	} else if len(colsToAdd) > 0 {
+		mux := child.Context.Mux
+		if df := child.Df(); df != nil {
+			mux = df.Context.Mux
+		}
+		mux.Lock()
+		child.AddColumns(colsToAdd, false)
+		mux.Unlock()
+	}
+
+	row := make([]any, len(child.columns))
+	row[0], row[1], row[2] = parentID, index, id
+	for key, value := range flatRec {
+		row[child.columnMap[strings.ToLower(key)]] = value
+	}
+	n.mux.Unlock()
+
+	n.send(child, row)
+}
+
+func (cs *childStream) addColumn(name string) (col Column, added bool) {
+	if _, ok := cs.columnMap[strings.ToLower(name)]; ok {
+		return col, false
+	}
+	col = Column{Name: name, Type: StringType, Position: len(cs.columns) + 1}
+	cs.columnMap[strings.ToLower(name)] = len(cs.columns)
+	cs.columns = append(cs.columns, col)
+	return col, true
+}
+
+// startChild starts the datastream of a new child, and announces it.
+// The column types are inferred from the rows.
+func (n *normalizer) startChild(name string, child *childStream) {
+	child.notify = make(chan struct{}, 1)
+	child.rows = MakeRowsChan()
+	child.done = make(chan struct{})
+
+	nextFunc := func(it *Iterator) bool {
+		for {
+			child.mux.Lock()
+			if len(child.pending) > 0 {
+				it.Row, child.pending = child.pending[0], child.pending[1:]
+				child.mux.Unlock()
+				return true
+			}
+			child.pending = nil
+			child.mux.Unlock()
+
+			select {
+			case it.Row = <-child.rows:
+				return true
+			case <-child.notify:
+			case <-child.done:
+				child.mux.Lock()
+				empty := len(child.pending) == 0
+				child.mux.Unlock()
+				if empty {
+					return false
+				}
+			}
+		}
+	}
+
+	ds := NewDatastreamIt(n.ds.Context.Ctx, child.columns.Clone(), nextFunc)
+	ds.SafeInference = true
+	child.ChildStream = &ChildStream{Name: name, Datastream: ds}
+	n.children[name] = child
+
+	go func() {
+		if err := ds.Start(); err != nil {
+			ds.Context.CaptureErr(err)
+		}
+	}()
+
+	select {
+	case n.streamCh <- child.ChildStream:
+	case <-n.ds.Context.Ctx.Done():
+	}
+}
+
+// send sends the row to the child stream. Until the child stream is
+// consumed by a dataflow, the rows are held, since the child streams are
+// only consumed once the parent stream is returned. Then the parent
+// stream waits on its child streams.
+func (n *normalizer) send(child *childStream, row []any) {
+	child.mux.Lock()
+	if child.Df() == nil {
+		child.pending = append(child.pending, row)
+		child.mux.Unlock()
+		select {
+		case child.notify <- struct{}{}:
+		default:
+		}
+		return
+	}
+	child.mux.Unlock()
+
+	select {
+	case child.rows <- row:
+	case <-child.done:
+	case <-child.Context.Ctx.Done():
+	case <-n.ds.Context.Ctx.Done():
+	}
+}
+
+// Streams returns the channel of the child streams, which is
+// closed once the parent stream is consumed
+func (n *normalizer) Streams() chan *ChildStream {
+	return n.streamCh
+}
+
+// Close ends the child streams, once their held rows are read
+func (n *normalizer) Close() {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+
+	if n.closed {
+		return
+	}
+	n.closed = true
+
+	for _, child := range n.children {
+		close(child.done)
+	}
+	close(n.streamCh)
+}
+
+func isObject(value any) bool {
+	switch value.(type) {
+	case map[string]any, map[any]any:
+		return true
+	}
+	return false
+}
+
+func toStringMap(m map[any]any) map[string]any {
+	newMap := map[string]any{}
+	for k, v := range m {
+		newMap[cast.ToString(k)] = v
+	}
+	return newMap
+}
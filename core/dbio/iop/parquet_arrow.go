@@ -76,6 +76,20 @@ func NewParquetArrowReader(reader *os.File, selected []string) (p *ParquetArrowR
 	return
 }
 
+// ParquetArrowColumns returns the columns of the parquet file,
+// read from its footer without reading any rows. The file is closed.
+func ParquetArrowColumns(reader *os.File) (columns Columns, err error) {
+	defer reader.Close()
+
+	r, err := file.NewParquetReader(reader)
+	if err != nil {
+		return nil, g.Error(err, "could not open parquet reader")
+	}
+
+	p := &ParquetArrowReader{Reader: r}
+	return p.Columns(), nil
+}
+
 func (p *ParquetArrowReader) Columns() Columns {
 	s := p.Reader.MetaData().Schema
 	cols := make(Columns, s.NumColumns())
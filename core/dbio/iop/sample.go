@@ -0,0 +1,115 @@
+package iop
+
+import (
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/flarco/g"
+)
+
+// SampleOptions selects a sample of the rows of a stream.
+// Only one of Fraction, Size or First is expected.
+type SampleOptions struct {
+	Fraction float64 `json:"fraction,omitempty" yaml:"fraction,omitempty"` // probability of keeping each row (bernoulli)
+	Size     int     `json:"size,omitempty" yaml:"size,omitempty"`         // number of rows kept at random (reservoir)
+	First    int     `json:"first,omitempty" yaml:"first,omitempty"`       // number of first rows kept, per file
+	Seed     *int64  `json:"seed,omitempty" yaml:"seed,omitempty"`
+}
+
+// Validate checks the sample options
+func (so *SampleOptions) Validate() error {
+	set := 0
+	for _, isSet := range []bool{so.Fraction != 0, so.Size != 0, so.First != 0} {
+		if isSet {
+			set++
+		}
+	}
+
+	switch {
+	case set == 0:
+		return g.Error("sample requires one of fraction, size or first")
+	case set > 1:
+		return g.Error("sample accepts only one of fraction, size or first")
+	case so.Fraction < 0 || so.Fraction > 1:
+		return g.Error("sample fraction must be between 0 and 1")
+	case so.Size < 0 || so.First < 0:
+		return g.Error("sample size must be positive")
+	}
+	return nil
+}
+
+// Percent returns the fraction as a percentage, for TABLESAMPLE clauses
+func (so *SampleOptions) Percent() float64 {
+	return so.Fraction * 100
+}
+
+// sampleRow is a row kept by the reservoir, with its position
+type sampleRow struct {
+	index int
+	row   []any
+}
+
+// sampleNextFunc wraps the next function of an iterator,
+// so that only the sampled rows are returned
+func sampleNextFunc(so *SampleOptions, nextFunc func(it *Iterator) bool) func(it *Iterator) bool {
+	seed := time.Now().UnixNano()
+	if so.Seed != nil {
+		seed = *so.Seed
+	}
+	r := rand.New(rand.NewSource(seed))
+
+	switch {
+	case so.Fraction > 0:
+		return func(it *Iterator) bool {
+			for nextFunc(it) {
+				if r.Float64() < so.Fraction {
+					return true
+				}
+			}
+			return false
+		}
+	case so.First > 0:
+		count := 0
+		return func(it *Iterator) bool {
+			if count >= so.First {
+				return false
+			}
+			count++
+			return nextFunc(it)
+		}
+	case so.Size > 0:
+		var reservoir []sampleRow
+		filled := false
+		return func(it *Iterator) bool {
+			if !filled {
+				// algorithm R, keeps each row with equal probability
+				for i := 0; nextFunc(it); i++ {
+					row := append([]any{}, it.Row...)
+					if i < so.Size {
+						reservoir = append(reservoir, sampleRow{i, row})
+					} else if j := r.Intn(i + 1); j < so.Size {
+						reservoir[j] = sampleRow{i, row}
+					}
+				}
+				if it.Context.Err() != nil {
+					return false
+				}
+
+				// keep the original order
+				sort.Slice(reservoir, func(i, j int) bool {
+					return reservoir[i].index < reservoir[j].index
+				})
+				filled = true
+			}
+
+			if len(reservoir) == 0 {
+				return false
+			}
+			it.Row = reservoir[0].row
+			reservoir = reservoir[1:]
+			return true
+		}
+	}
+	return nextFunc
+}
@@ -0,0 +1,69 @@
+package iop
+
+import (
+	"context"
+	"testing"
+
+	"github.com/flarco/g"
+	"github.com/spf13/cast"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSample(t *testing.T) {
+	sampleData := func(sample SampleOptions) *Dataset {
+		data := NewDataset(NewColumnsFromFields("id", "name"))
+		for i := 1; i <= 1000; i++ {
+			data.Append([]any{i, g.F("name%d", i)})
+		}
+
+		i := 0
+		nextFunc := func(it *Iterator) bool {
+			if i >= len(data.Rows) {
+				return false
+			}
+			it.Row = data.Rows[i]
+			i++
+			return true
+		}
+
+		ds := NewDatastreamIt(context.Background(), data.Columns, nextFunc)
+		ds.SetConfig(map[string]string{"sample": g.Marshal(sample)})
+		require.NoError(t, ds.Start())
+
+		sampled, err := ds.Collect(0)
+		require.NoError(t, err)
+		return &sampled
+	}
+
+	ids := func(data *Dataset) (ids []int) {
+		for _, row := range data.Rows {
+			ids = append(ids, cast.ToInt(row[0]))
+		}
+		return ids
+	}
+
+	// bernoulli
+	data := sampleData(SampleOptions{Fraction: 0.1, Seed: g.Int64(7)})
+	assert.Greater(t, len(data.Rows), 50)
+	assert.Less(t, len(data.Rows), 150)
+	assert.Equal(t, ids(data), ids(sampleData(SampleOptions{Fraction: 0.1, Seed: g.Int64(7)})))
+
+	// reservoir, in the original order
+	data = sampleData(SampleOptions{Size: 20})
+	if assert.Len(t, data.Rows, 20) {
+		sampledIDs := ids(data)
+		assert.IsIncreasing(t, sampledIDs)
+		assert.Greater(t, sampledIDs[19], 20) // not only the head
+	}
+
+	// first rows
+	data = sampleData(SampleOptions{First: 5})
+	assert.Equal(t, []int{1, 2, 3, 4, 5}, ids(data))
+
+	// validation
+	assert.Error(t, (&SampleOptions{}).Validate())
+	assert.Error(t, (&SampleOptions{Fraction: 0.5, Size: 10}).Validate())
+	assert.Error(t, (&SampleOptions{Fraction: 1.5}).Validate())
+	assert.NoError(t, (&SampleOptions{Size: 10}).Validate())
+}
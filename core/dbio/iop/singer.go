@@ -0,0 +1,430 @@
+package iop
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flarco/g"
+	"github.com/spf13/cast"
+)
+
+// SingerMessageType is the type of a Singer protocol message
+type SingerMessageType string
+
+const (
+	SingerMessageSchema SingerMessageType = "SCHEMA"
+	SingerMessageRecord SingerMessageType = "RECORD"
+	SingerMessageState  SingerMessageType = "STATE"
+)
+
+// SingerMessage is a message of the Singer protocol, as a line of JSON.
+// See https://github.com/singer-io/getting-started/blob/master/docs/SPEC.md
+type SingerMessage struct {
+	Type          SingerMessageType `json:"type"`
+	Stream        string            `json:"stream,omitempty"`
+	Schema        json.RawMessage   `json:"schema,omitempty"`
+	KeyProperties []string          `json:"key_properties,omitempty"`
+	Record        map[string]any    `json:"record,omitempty"`
+	TimeExtracted string            `json:"time_extracted,omitempty"`
+	Value         json.RawMessage   `json:"value,omitempty"` // state
+}
+
+// SingerParser parses the Singer messages of a tap output into a
+// datastream per stream. The records of every stream are pushed into its
+// datastream, so all the datastreams need to be consumed concurrently.
+// Streams not selected are skipped, or rejected with SelectedOnly.
+type SingerParser struct {
+	Context      *g.Context
+	Select       []string          // names of the streams to parse, all if empty
+	SelectedOnly bool              // fail on the streams not selected
+	State        string            // the value of the latest STATE message
+	Config       map[string]string // the stream processing options
+
+	streams map[string]*singerStream
+}
+
+type singerStream struct {
+	ds    *Datastream
+	rows  chan []any
+	index map[string]int // lower column name to index
+}
+
+// NewSingerParser creates a Singer parser
+func NewSingerParser(ctx context.Context, selectStreams ...string) *SingerParser {
+	c := g.NewContext(ctx)
+	return &SingerParser{
+		Context: &c,
+		Select:  selectStreams,
+		streams: map[string]*singerStream{},
+	}
+}
+
+// Parse reads the messages of the reader until EOF. A datastream is sent
+// into the channel for the first SCHEMA message of every selected stream.
+// It returns once all the datastreams are sent.
+func (sp *SingerParser) Parse(reader io.Reader, dsCh chan *Datastream) (err error) {
+	var wg sync.WaitGroup
+	defer func() {
+		for _, stream := range sp.streams {
+			close(stream.rows)
+		}
+		wg.Wait()
+	}()
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 100*1024*1024)
+
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		msg := SingerMessage{}
+		decoder := json.NewDecoder(bytes.NewReader(line))
+		decoder.UseNumber() // keep the precision of large numbers
+		if err = decoder.Decode(&msg); err != nil {
+			return g.Error(err, "invalid singer message: %s", truncateString(string(line), 200))
+		}
+
+		switch msg.Type {
+		case SingerMessageSchema:
+			if !sp.selected(msg.Stream) {
+				if sp.SelectedOnly {
+					return g.Error("singer stream %s was not selected, select a single stream with a catalog", msg.Stream)
+				}
+				continue
+			} else if _, ok := sp.streams[msg.Stream]; ok {
+				g.Debug("ignoring new schema of singer stream %s", msg.Stream)
+				continue
+			}
+
+			stream, err := sp.newStream(msg)
+			if err != nil {
+				return g.Error(err, "could not create singer stream %s", msg.Stream)
+			}
+			sp.streams[msg.Stream] = stream
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := stream.ds.Start(); err != nil {
+					stream.ds.Context.CaptureErr(err)
+				}
+				dsCh <- stream.ds
+			}()
+		case SingerMessageRecord:
+			if !sp.selected(msg.Stream) {
+				continue
+			}
+
+			stream, ok := sp.streams[msg.Stream]
+			if !ok {
+				return g.Error("received record of singer stream %s before its schema", msg.Stream)
+			}
+
+			row := make([]any, len(stream.ds.Columns))
+			for key, value := range msg.Record {
+				i, ok := stream.index[strings.ToLower(key)]
+				if !ok {
+					continue // not in schema
+				}
+				switch vt := value.(type) {
+				case json.Number:
+					value = vt.String()
+				case map[string]any, []any:
+					value = g.Marshal(value)
+				}
+				row[i] = value
+			}
+
+			select {
+			case <-sp.Context.Ctx.Done():
+				return sp.Context.Err()
+			case stream.rows <- row:
+			}
+		case SingerMessageState:
+			sp.State = string(msg.Value)
+		default:
+			g.Trace("ignoring singer message of type %s", msg.Type)
+		}
+	}
+
+	if err = scanner.Err(); err != nil {
+		return g.Error(err, "could not read singer messages")
+	}
+
+	return nil
+}
+
+// Streams returns the names of the parsed streams
+func (sp *SingerParser) Streams() (names []string) {
+	for name := range sp.streams {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (sp *SingerParser) selected(stream string) bool {
+	if len(sp.Select) == 0 {
+		return true
+	}
+	for _, name := range sp.Select {
+		if strings.EqualFold(name, stream) {
+			return true
+		}
+	}
+	return false
+}
+
+// newStream creates the datastream of the stream schema
+func (sp *SingerParser) newStream(msg SingerMessage) (stream *singerStream, err error) {
+	columns, typed, err := SingerSchemaColumns(msg.Schema)
+	if err != nil {
+		return nil, g.Error(err, "could not parse schema")
+	}
+	if len(msg.KeyProperties) > 0 {
+		if err = columns.SetKeys(PrimaryKey, msg.KeyProperties...); err != nil {
+			return nil, g.Error(err, "could not set key properties")
+		}
+	}
+
+	stream = &singerStream{
+		rows:  make(chan []any, 1000),
+		index: map[string]int{},
+	}
+	for i, col := range columns {
+		stream.index[strings.ToLower(col.Name)] = i
+	}
+
+	nextFunc := func(it *Iterator) bool {
+		for it.Row = range stream.rows {
+			return true
+		}
+		return false
+	}
+
+	stream.ds = NewDatastreamIt(sp.Context.Ctx, columns, nextFunc)
+	if sp.Config != nil {
+		stream.ds.SetConfig(sp.Config)
+	}
+	stream.ds.Inferred = typed // infer the types missing from the schema
+	stream.ds.SafeInference = true
+	stream.ds.Metadata.StreamURL.Value = msg.Stream
+
+	return stream, nil
+}
+
+// SingerSchemaColumns returns the columns of the JSON schema of a stream,
+// in the order of the properties. It returns false if some property
+// types could not be determined.
+func SingerSchemaColumns(schema json.RawMessage) (columns Columns, typed bool, err error) {
+	var properties json.RawMessage
+	if err = json.Unmarshal(schema, &struct {
+		Properties *json.RawMessage `json:"properties"`
+	}{&properties}); err != nil {
+		return nil, false, g.Error(err, "invalid schema")
+	}
+
+	names, err := jsonObjectKeys(properties)
+	if err != nil {
+		return nil, false, g.Error(err, "invalid schema properties")
+	}
+
+	propMap := map[string]singerProperty{}
+	if err = json.Unmarshal(properties, &propMap); err != nil {
+		return nil, false, g.Error(err, "invalid schema properties")
+	}
+
+	typed = true
+	for i, name := range names {
+		types, format := propMap[name].types()
+		col := Column{Name: name, Position: i + 1, Type: singerColumnType(types, format)}
+		if col.Type == "" {
+			col.Type = StringType
+			typed = false
+		}
+		columns = append(columns, col)
+	}
+
+	return columns, typed, nil
+}
+
+// singerProperty is a property of a JSON schema
+type singerProperty struct {
+	Type   any              `json:"type"` // a string, or an array such as ["null", "string"]
+	Format string           `json:"format"`
+	AnyOf  []singerProperty `json:"anyOf"`
+}
+
+// types returns the types and the format of the property
+func (p singerProperty) types() (types []string, format string) {
+	switch tv := p.Type.(type) {
+	case string:
+		types = append(types, tv)
+	case []any:
+		types = append(types, cast.ToStringSlice(tv)...)
+	}
+	format = p.Format
+
+	for _, anyOf := range p.AnyOf {
+		anyOfTypes, anyOfFormat := anyOf.types()
+		types = append(types, anyOfTypes...)
+		if format == "" {
+			format = anyOfFormat
+		}
+	}
+	return types, format
+}
+
+// singerColumnType returns the column type of the JSON schema types
+func singerColumnType(types []string, format string) ColumnType {
+	for _, t := range types {
+		switch t {
+		case "string":
+			switch format {
+			case "date-time":
+				return TimestampzType
+			case "date":
+				return DateType
+			case "time":
+				return TimeType
+			}
+			return StringType
+		case "integer":
+			return BigIntType
+		case "number":
+			return DecimalType
+		case "boolean":
+			return BoolType
+		case "object", "array":
+			return JsonType
+		}
+	}
+	return ""
+}
+
+// jsonObjectKeys returns the keys of the JSON object, in order
+func jsonObjectKeys(data json.RawMessage) (keys []string, err error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if _, err = decoder.Token(); err != nil { // opening brace
+		return nil, err
+	}
+
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, cast.ToString(token))
+
+		var value json.RawMessage
+		if err = decoder.Decode(&value); err != nil {
+			return nil, err
+		}
+	}
+
+	return keys, nil
+}
+
+// SingerSchema returns the JSON schema of the columns
+func (cols Columns) SingerSchema() map[string]any {
+	properties := map[string]any{}
+	for _, col := range cols {
+		var prop map[string]any
+		switch {
+		case col.IsBool():
+			prop = g.M("type", []string{"null", "boolean"})
+		case col.IsInteger():
+			prop = g.M("type", []string{"null", "integer"})
+		case col.IsNumber():
+			prop = g.M("type", []string{"null", "number"})
+		case col.Type == DateType:
+			prop = g.M("type", []string{"null", "string"}, "format", "date")
+		case col.IsDatetime():
+			prop = g.M("type", []string{"null", "string"}, "format", "date-time")
+		case col.Type == JsonType:
+			prop = g.M("type", []string{"null", "object", "array"})
+		default:
+			prop = g.M("type", []string{"null", "string"})
+		}
+		properties[col.Name] = prop
+	}
+	return g.M("type", "object", "properties", properties)
+}
+
+// NewSingerReader returns a reader of the stream as Singer messages:
+// a SCHEMA message, followed by a RECORD message per row
+func (ds *Datastream) NewSingerReader(streamName string) *io.PipeReader {
+	pipeR, pipeW := io.Pipe()
+
+	go func() {
+		writeMessage := func(msg SingerMessage) error {
+			b, err := json.Marshal(msg)
+			if err != nil {
+				return g.Error(err, "could not marshal singer message")
+			}
+			_, err = pipeW.Write(append(b, '\n'))
+			return err
+		}
+
+		var err error
+		defer func() { pipeW.CloseWithError(err) }()
+
+		schemaMsg := SingerMessage{
+			Type:          SingerMessageSchema,
+			Stream:        streamName,
+			Schema:        json.RawMessage(g.Marshal(ds.Columns.SingerSchema())),
+			KeyProperties: ds.Columns.GetKeys(PrimaryKey).Names(),
+		}
+		if err = writeMessage(schemaMsg); err != nil {
+			return
+		}
+
+		for batch := range ds.BatchChan {
+			fields := batch.Columns.Names()
+			for row := range batch.Rows {
+				rec := map[string]any{}
+				for i, val := range row {
+					switch v := val.(type) {
+					case time.Time:
+						if batch.Columns[i].Type == DateType {
+							val = v.Format("2006-01-02")
+						} else {
+							val = v.Format(time.RFC3339Nano)
+						}
+					}
+					rec[fields[i]] = val
+				}
+
+				err = writeMessage(SingerMessage{Type: SingerMessageRecord, Stream: streamName, Record: rec})
+				if err != nil {
+					ds.Context.CaptureErr(g.Error(err, "could not write singer record"))
+					ds.Context.Cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	return pipeR
+}
+
+func truncateString(s string, n int) string {
+	if len(s) > n {
+		return s[:n] + "..."
+	}
+	return s
+}
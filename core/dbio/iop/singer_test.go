@@ -0,0 +1,110 @@
+package iop
+
+import (
+	"bufio"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/flarco/g"
+	"github.com/spf13/cast"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSingerParser(t *testing.T) {
+	messages := `
+{"type": "SCHEMA", "stream": "users", "key_properties": ["id"], "schema": {"properties": {"id": {"type": "integer"}, "name": {"type": ["null", "string"]}, "created_at": {"type": "string", "format": "date-time"}, "score": {"anyOf": [{"type": "number"}, {"type": "null"}]}, "tags": {"type": "array"}}}}
+{"type": "SCHEMA", "stream": "orders", "schema": {"properties": {"id": {"type": "integer"}}}}
+{"type": "RECORD", "stream": "users", "record": {"id": 1, "name": "Fred", "created_at": "2024-01-01T10:00:00Z", "score": 1.5, "tags": ["a", "b"]}}
+{"type": "RECORD", "stream": "orders", "record": {"id": 100}}
+{"type": "STATE", "value": {"bookmarks": {"users": 1}}}
+{"type": "RECORD", "stream": "users", "record": {"name": "Wilma", "id": 2, "other": true}}
+{"type": "STATE", "value": {"bookmarks": {"users": 2}}}
+`
+
+	parser := NewSingerParser(context.Background(), "users")
+	dsCh := make(chan *Datastream, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- parser.Parse(strings.NewReader(messages), dsCh)
+		close(dsCh)
+	}()
+
+	var datastreams []*Datastream
+	for ds := range dsCh {
+		datastreams = append(datastreams, ds)
+	}
+	require.NoError(t, <-errCh)
+	require.Len(t, datastreams, 1)
+	assert.Equal(t, []string{"users"}, parser.Streams())
+	assert.Equal(t, `{"bookmarks": {"users": 2}}`, parser.State)
+
+	ds := datastreams[0]
+	data, err := ds.Collect(0)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"id", "name", "created_at", "score", "tags"}, data.Columns.Names())
+	assert.Equal(t, BigIntType, data.Columns[0].Type)
+	assert.Equal(t, StringType, data.Columns[1].Type)
+	assert.Equal(t, TimestampzType, data.Columns[2].Type)
+	assert.Equal(t, DecimalType, data.Columns[3].Type)
+	assert.Equal(t, JsonType, data.Columns[4].Type)
+	assert.Equal(t, []string{"id"}, data.Columns.GetKeys(PrimaryKey).Names())
+
+	require.Len(t, data.Rows, 2)
+	assert.EqualValues(t, 1, cast.ToInt(data.Rows[0][0]))
+	assert.Equal(t, "Fred", data.Rows[0][1])
+	assert.Equal(t, `["a","b"]`, cast.ToString(data.Rows[0][4]))
+	assert.EqualValues(t, 2, cast.ToInt(data.Rows[1][0]))
+	assert.Equal(t, "Wilma", data.Rows[1][1])
+	assert.Nil(t, data.Rows[1][2])
+
+	// record before schema
+	err = NewSingerParser(context.Background()).Parse(
+		strings.NewReader(`{"type": "RECORD", "stream": "users", "record": {"id": 1}}`),
+		make(chan *Datastream, 1),
+	)
+	assert.Error(t, err)
+}
+
+func TestSingerReader(t *testing.T) {
+	columns := NewColumns(
+		Columns{
+			{Name: "id", Type: BigIntType},
+			{Name: "name", Type: StringType},
+			{Name: "created_date", Type: DateType},
+		}...,
+	)
+	columns.SetKeys(PrimaryKey, "id")
+
+	data := NewDataset(columns)
+	data.Append([]any{1, "Fred", cast.ToTime("2024-01-01")})
+	data.Append([]any{2, nil, nil})
+	data.Inferred = true
+
+	ds := data.Stream()
+
+	var messages []SingerMessage
+	scanner := bufio.NewScanner(ds.NewSingerReader("users"))
+	for scanner.Scan() {
+		msg := SingerMessage{}
+		require.NoError(t, g.Unmarshal(scanner.Text(), &msg))
+		messages = append(messages, msg)
+	}
+	require.NoError(t, scanner.Err())
+	require.Len(t, messages, 3)
+
+	assert.Equal(t, SingerMessageSchema, messages[0].Type)
+	assert.Equal(t, []string{"id"}, messages[0].KeyProperties)
+	cols, typed, err := SingerSchemaColumns(messages[0].Schema)
+	assert.NoError(t, err)
+	assert.True(t, typed)
+	assert.Equal(t, DateType, cols.GetColumn("created_date").Type)
+
+	assert.Equal(t, SingerMessageRecord, messages[1].Type)
+	assert.Equal(t, "users", messages[1].Stream)
+	assert.Equal(t, "Fred", messages[1].Record["name"])
+	assert.Equal(t, "2024-01-01", messages[1].Record["created_date"])
+	assert.Nil(t, messages[2].Record["name"])
+}
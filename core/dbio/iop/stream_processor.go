@@ -38,22 +38,36 @@ type StreamProcessor struct {
 }
 
 type streamConfig struct {
-	TrimSpace      bool                       `json:"trim_space"`
-	EmptyAsNull    bool                       `json:"empty_as_null"`
-	Header         bool                       `json:"header"`
-	Compression    string                     `json:"compression"` // AUTO | ZIP | GZIP | SNAPPY | NONE
-	NullIf         string                     `json:"null_if"`
-	DatetimeFormat string                     `json:"datetime_format"`
-	SkipBlankLines bool                       `json:"skip_blank_lines"`
-	Delimiter      string                     `json:"delimiter"`
-	FileMaxRows    int64                      `json:"file_max_rows"`
-	MaxDecimals    int                        `json:"max_decimals"`
-	Flatten        bool                       `json:"flatten"`
-	FieldsPerRec   int                        `json:"fields_per_rec"`
-	Jmespath       string                     `json:"jmespath"`
-	BoolAsInt      bool                       `json:"-"`
-	Columns        Columns                    `json:"columns"` // list of column types. Can be partial list! likely is!
-	transforms     map[string][]TransformFunc // array of transform functions to apply
+	TrimSpace          bool                       `json:"trim_space"`
+	EmptyAsNull        bool                       `json:"empty_as_null"`
+	Header             bool                       `json:"header"`
+	Compression        string                     `json:"compression"` // AUTO | ZIP | GZIP | SNAPPY | ZSTD | BZIP2 | LZ4 | XZ | BROTLI | NONE
+	NullIf             string                     `json:"null_if"`
+	DatetimeFormat     string                     `json:"datetime_format"`
+	SkipBlankLines     bool                       `json:"skip_blank_lines"`
+	Delimiter          string                     `json:"delimiter"`
+	Quote              string                     `json:"quote"`       // csv quote character
+	Escape             string                     `json:"escape"`      // csv escape character, empty to double quotes
+	Comment            string                     `json:"comment"`     // csv comment line prefix
+	QuoteAll           bool                       `json:"quote_all"`   // quote all csv fields
+	LineEnding         string                     `json:"line_ending"` // LF | CRLF
+	NullAs             string                     `json:"null_as"`     // csv output string for nulls
+	BOM                bool                       `json:"bom"`         // write a UTF-8 byte order mark
+	FileMaxRows        int64                      `json:"file_max_rows"`
+	MaxDecimals        int                        `json:"max_decimals"`
+	Flatten            bool                       `json:"flatten"`
+	Normalize          bool                       `json:"normalize"` // split nested arrays into child streams
+	FieldsPerRec       int                        `json:"fields_per_rec"`
+	Jmespath           string                     `json:"jmespath"`
+	XmlRoot            string                     `json:"xml_root"`
+	XmlRow             string                     `json:"xml_row"`
+	XmlAttributes      string                     `json:"xml_attributes"`      // comma-separated column names written as attributes
+	Layout             string                     `json:"layout"`              // fixed-width layout spec or file path
+	TimestampPrecision int                        `json:"timestamp_precision"` // fractional digits of parquet timestamps, 0 for default
+	BoolAsInt          bool                       `json:"-"`
+	Columns            Columns                    `json:"columns"` // list of column types. Can be partial list! likely is!
+	Sample             *SampleOptions             `json:"sample"`  // rows to keep, none for all
+	transforms         map[string][]TransformFunc // array of transform functions to apply
 }
 
 type TransformFunc func(*StreamProcessor, string) (string, error)
@@ -169,6 +183,34 @@ func (sp *StreamProcessor) SetConfig(configMap map[string]string) {
 		sp.config.Delimiter = configMap["delimiter"]
 	}
 
+	if configMap["quote"] != "" {
+		sp.config.Quote = configMap["quote"]
+	}
+
+	if configMap["escape"] != "" {
+		sp.config.Escape = configMap["escape"]
+	}
+
+	if configMap["comment"] != "" {
+		sp.config.Comment = configMap["comment"]
+	}
+
+	if configMap["quote_all"] != "" {
+		sp.config.QuoteAll = cast.ToBool(configMap["quote_all"])
+	}
+
+	if configMap["line_ending"] != "" {
+		sp.config.LineEnding = configMap["line_ending"]
+	}
+
+	if configMap["null_as"] != "" {
+		sp.config.NullAs = configMap["null_as"]
+	}
+
+	if configMap["bom"] != "" {
+		sp.config.BOM = cast.ToBool(configMap["bom"])
+	}
+
 	if configMap["file_max_rows"] != "" {
 		sp.config.FileMaxRows = cast.ToInt64(configMap["file_max_rows"])
 	}
@@ -183,6 +225,10 @@ func (sp *StreamProcessor) SetConfig(configMap map[string]string) {
 		sp.config.Flatten = cast.ToBool(configMap["flatten"])
 	}
 
+	if configMap["normalize"] != "" {
+		sp.config.Normalize = cast.ToBool(configMap["normalize"])
+	}
+
 	if configMap["max_decimals"] != "" && configMap["max_decimals"] != "-1" {
 		var err error
 		sp.config.MaxDecimals, err = cast.ToIntE(configMap["max_decimals"])
@@ -203,6 +249,21 @@ func (sp *StreamProcessor) SetConfig(configMap map[string]string) {
 	if configMap["jmespath"] != "" {
 		sp.config.Jmespath = cast.ToString(configMap["jmespath"])
 	}
+	if configMap["xml_root"] != "" {
+		sp.config.XmlRoot = configMap["xml_root"]
+	}
+	if configMap["xml_row"] != "" {
+		sp.config.XmlRow = configMap["xml_row"]
+	}
+	if configMap["xml_attributes"] != "" {
+		sp.config.XmlAttributes = configMap["xml_attributes"]
+	}
+	if configMap["layout"] != "" {
+		sp.config.Layout = configMap["layout"]
+	}
+	if configMap["timestamp_precision"] != "" {
+		sp.config.TimestampPrecision = cast.ToInt(configMap["timestamp_precision"])
+	}
 	if configMap["skip_blank_lines"] != "" {
 		sp.config.SkipBlankLines = cast.ToBool(configMap["skip_blank_lines"])
 	}
@@ -212,6 +273,16 @@ func (sp *StreamProcessor) SetConfig(configMap map[string]string) {
 	if configMap["columns"] != "" {
 		g.Unmarshal(configMap["columns"], &sp.config.Columns)
 	}
+	if val := configMap["sample"]; val != "" && val != "null" {
+		sample := &SampleOptions{}
+		if err := g.Unmarshal(val, sample); err != nil {
+			g.Warn("could not parse sample options: %s", err.Error())
+		} else if err = sample.Validate(); err != nil {
+			g.Warn("invalid sample options: %s", err.Error())
+		} else {
+			sp.config.Sample = sample
+		}
+	}
 	if configMap["transforms"] != "" {
 		columnTransforms := map[string][]string{}
 		g.Unmarshal(configMap["transforms"], &columnTransforms)
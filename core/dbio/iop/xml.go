@@ -0,0 +1,189 @@
+package iop
+
+import (
+	"bytes"
+	"encoding/xml"
+	"regexp"
+	"strings"
+
+	"github.com/samber/lo"
+)
+
+var (
+	xmlNameInvalidRegex = regexp.MustCompile(`[^A-Za-z0-9_\-.]`)
+	xmlPathSplitRegex   = regexp.MustCompile(`__|\.`)
+)
+
+// xmlNode is an element (or attribute) of the tree rebuilt
+// from the column names of a batch
+type xmlNode struct {
+	name     string
+	colIndex int // index of the column holding the value, -1 if none
+	attrs    []*xmlNode
+	children []*xmlNode
+	childMap map[string]*xmlNode
+}
+
+func newXmlNode(name string) *xmlNode {
+	return &xmlNode{name: name, colIndex: -1, childMap: map[string]*xmlNode{}}
+}
+
+// hasValue returns true if the node or any of its descendants has a non-nil value
+func (n *xmlNode) hasValue(row []any) bool {
+	if n.colIndex > -1 && n.colIndex < len(row) && row[n.colIndex] != nil {
+		return true
+	}
+	for _, attr := range n.attrs {
+		if attr.hasValue(row) {
+			return true
+		}
+	}
+	for _, child := range n.children {
+		if child.hasValue(row) {
+			return true
+		}
+	}
+	return false
+}
+
+// xmlWriter renders rows as XML elements. Column names are split
+// on `__` or `.` to rebuild nested elements (e.g. `address__city`
+// becomes <address><city>...</city></address>). A path segment
+// starting with `@`, or a column listed in the attributes option,
+// is written as an attribute of its parent element.
+type xmlWriter struct {
+	sp         *StreamProcessor
+	rootName   string
+	rowName    string
+	attributes map[string]bool
+	columns    Columns
+	rowNode    *xmlNode
+}
+
+func newXmlWriter(sp *StreamProcessor) *xmlWriter {
+	xw := &xmlWriter{
+		sp:         sp,
+		rootName:   lo.Ternary(sp.config.XmlRoot == "", "rows", xmlName(sp.config.XmlRoot)),
+		rowName:    lo.Ternary(sp.config.XmlRow == "", "row", xmlName(sp.config.XmlRow)),
+		attributes: map[string]bool{},
+	}
+
+	for _, name := range strings.Split(sp.config.XmlAttributes, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			xw.attributes[strings.ToLower(name)] = true
+		}
+	}
+
+	return xw
+}
+
+// SetColumns rebuilds the element tree from the provided columns
+func (xw *xmlWriter) SetColumns(columns Columns) {
+	xw.columns = columns
+	xw.rowNode = newXmlNode(xw.rowName)
+
+	for i, col := range columns {
+		parts := xmlPathSplitRegex.Split(col.Name, -1)
+		isAttr := xw.attributes[strings.ToLower(col.Name)]
+
+		parent := xw.rowNode
+		for j, part := range parts {
+			isLast := j == len(parts)-1
+			if isLast && strings.HasPrefix(part, "@") {
+				isAttr = true
+				part = strings.TrimPrefix(part, "@")
+			}
+			name := xmlName(part)
+
+			if isLast && isAttr {
+				attr := newXmlNode(name)
+				attr.colIndex = i
+				parent.attrs = append(parent.attrs, attr)
+				break
+			}
+
+			node, ok := parent.childMap[name]
+			if !ok {
+				node = newXmlNode(name)
+				parent.childMap[name] = node
+				parent.children = append(parent.children, node)
+			}
+			if isLast {
+				node.colIndex = i
+			}
+			parent = node
+		}
+	}
+}
+
+// Header returns the XML declaration and the opening root element
+func (xw *xmlWriter) Header() []byte {
+	return []byte(xml.Header + "<" + xw.rootName + ">\n")
+}
+
+// Footer returns the closing root element
+func (xw *xmlWriter) Footer() []byte {
+	return []byte("</" + xw.rootName + ">\n")
+}
+
+// Row returns the row element for the provided values
+func (xw *xmlWriter) Row(row []any) []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteString("  ")
+	xw.writeNode(buf, xw.rowNode, row, true)
+	buf.WriteString("\n")
+	return buf.Bytes()
+}
+
+func (xw *xmlWriter) valueString(i int, row []any) string {
+	if i < 0 || i >= len(row) || row[i] == nil {
+		return ""
+	}
+	var colType ColumnType
+	if i < len(xw.columns) {
+		colType = xw.columns[i].Type
+	}
+	return xw.sp.CastToString(i, row[i], colType)
+}
+
+func (xw *xmlWriter) writeNode(buf *bytes.Buffer, node *xmlNode, row []any, force bool) {
+	if !force && !node.hasValue(row) {
+		return // omit elements without values
+	}
+
+	buf.WriteString("<" + node.name)
+	for _, attr := range node.attrs {
+		if attr.colIndex >= len(row) || row[attr.colIndex] == nil {
+			continue
+		}
+		buf.WriteString(" " + attr.name + `="`)
+		xml.EscapeText(buf, []byte(xw.valueString(attr.colIndex, row)))
+		buf.WriteString(`"`)
+	}
+
+	text := xw.valueString(node.colIndex, row)
+	if text == "" && (len(node.children) == 0 || !node.hasValue(row)) {
+		buf.WriteString("/>")
+		return
+	}
+	buf.WriteString(">")
+
+	xml.EscapeText(buf, []byte(text))
+	for _, child := range node.children {
+		xw.writeNode(buf, child, row, false)
+	}
+
+	buf.WriteString("</" + node.name + ">")
+}
+
+// xmlName cleans up a name to be a valid XML element name
+func xmlName(name string) string {
+	name = xmlNameInvalidRegex.ReplaceAllString(strings.TrimSpace(name), "_")
+	if name == "" {
+		return "_"
+	}
+	if c := name[0]; !(c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')) {
+		name = "_" + name
+	}
+	return name
+}
@@ -0,0 +1,77 @@
+package iop
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestXmlWriter(t *testing.T) {
+	columns := NewColumns(
+		Columns{
+			{Name: "id", Type: BigIntType},
+			{Name: "name", Type: StringType},
+			{Name: "address__city", Type: StringType},
+			{Name: "address.@type", Type: StringType},
+			{Name: "note", Type: StringType},
+		}...,
+	)
+
+	data := NewDataset(columns)
+	data.Append([]any{1, "Fred & Co", "Paris", "home", nil})
+	data.Append([]any{2, "<Wilma>", nil, nil, "hi"})
+	data.Append([]any{3, "Barney", "Rome", nil, nil})
+
+	ds := data.Stream()
+	ds.SetConfig(map[string]string{
+		"xml_root":       "people",
+		"xml_row":        "person",
+		"xml_attributes": "id",
+	})
+
+	var outputs []string
+	var counts []int
+	for br := range ds.NewXmlReaderChnl(2, 0) {
+		b, err := io.ReadAll(br.Reader)
+		assert.NoError(t, err)
+		outputs = append(outputs, string(b))
+		counts = append(counts, br.Counter)
+	}
+
+	if !assert.Len(t, outputs, 2) {
+		return
+	}
+	assert.Equal(t, []int{2, 1}, counts)
+
+	expected := xml.Header + `<people>
+  <person id="1"><name>Fred &amp; Co</name><address type="home"><city>Paris</city></address></person>
+  <person id="2"><name>&lt;Wilma&gt;</name><note>hi</note></person>
+</people>
+`
+	assert.Equal(t, expected, outputs[0])
+	assert.True(t, strings.HasSuffix(outputs[1], "</people>\n"))
+	assert.Contains(t, outputs[1], `<person id="3"><name>Barney</name><address><city>Rome</city></address></person>`)
+
+	// output must be well-formed
+	for _, output := range outputs {
+		decoder := xml.NewDecoder(strings.NewReader(output))
+		for {
+			_, err := decoder.Token()
+			if err == io.EOF {
+				break
+			} else if !assert.NoError(t, err) {
+				break
+			}
+		}
+	}
+}
+
+func TestXmlName(t *testing.T) {
+	assert.Equal(t, "first_name", xmlName("first name"))
+	assert.Equal(t, "_1st", xmlName("1st"))
+	assert.Equal(t, "_", xmlName(""))
+	assert.Equal(t, "a-b.c", xmlName("a-b.c"))
+}
@@ -78,6 +78,9 @@ func (cfg *Config) SetDefault() {
 	switch cfg.SrcConn.Type.Kind() {
 	case dbio.KindFile:
 		sourceOptions = SourceFileOptionsDefault
+		if cfg.SrcConn.Type == dbio.TypeFileAPI {
+			sourceOptions.Flatten = g.Bool(true) // records are objects
+		}
 	case dbio.KindDatabase:
 		sourceOptions = SourceDBOptionsDefault
 	default:
@@ -157,6 +160,7 @@ func (cfg *Config) SetDefault() {
 	if val := os.Getenv("SLING_ROW_NUM_COLUMN"); val != "" {
 		cfg.MetadataRowNum = cast.ToBool(val)
 	}
+
 	if val := os.Getenv("SAMPLE_SIZE"); val != "" {
 		iop.SampleSize = cast.ToInt(val)
 	}
@@ -232,6 +236,15 @@ func (cfg *Config) sourceIsFile() bool {
 	return cfg.Options.StdIn || cfg.SrcConn.Info().Type.IsFile()
 }
 
+// dedupeBySeq returns true when the rows are merged deduped. The internal
+// sequence column breaks the ties of the update key, if any, so that the
+// last row read is kept.
+func (cfg *Config) dedupeBySeq() bool {
+	dedupe := cfg.Target.Options != nil && cfg.Target.Options.Dedupe != nil && *cfg.Target.Options.Dedupe
+	merged := cfg.Mode == IncrementalMode || cfg.Mode == BackfillMode
+	return dedupe && merged
+}
+
 func (cfg *Config) DetermineType() (Type JobType, err error) {
 
 	srcFileProvided := cfg.sourceIsFile()
@@ -500,6 +513,34 @@ func (cfg *Config) Prepare() (err error) {
 		}
 	}
 
+	// validate post-load actions of source files
+	if cfg.Source.Options != nil && cfg.Source.Options.PostLoad != nil {
+		if !cfg.SrcConn.Type.IsFile() {
+			return g.Error("post_load is only supported for file sources")
+		} else if err = cfg.Source.Options.PostLoad.Validate(connsMap); err != nil {
+			return g.Error(err, "invalid post_load options")
+		}
+	}
+
+	// validate dedupe, which needs a key to partition by
+	if dedupe := cfg.Target.Options.Dedupe; dedupe != nil && *dedupe && !cfg.Source.HasPrimaryKey() {
+		return g.Error("dedupe requires a primary key")
+	}
+
+	// validate sample options
+	if cfg.Source.Options != nil && cfg.Source.Options.Sample != nil {
+		if err = cfg.Source.Options.Sample.Validate(); err != nil {
+			return g.Error(err, "invalid sample options")
+		}
+	}
+
+	// validate lookup options
+	if cfg.Source.Options != nil && cfg.Source.Options.Lookup != nil {
+		if err = validateLookup(cfg.Source.Options.Lookup, connsMap); err != nil {
+			return g.Error(err, "invalid lookup options")
+		}
+	}
+
 	// validate conn data keys
 	for key := range cfg.SrcConn.Data {
 		if strings.Contains(key, ":") {
@@ -543,6 +584,9 @@ func (cfg *Config) FormatTargetObjectName() (err error) {
 
 	// replace placeholders
 	cfg.Target.Object = strings.TrimSpace(g.Rm(cfg.Target.Object, m))
+	if cfg.Target.Options != nil && cfg.Target.Options.Sheet != "" {
+		cfg.Target.Options.Sheet = strings.TrimSpace(g.Rm(cfg.Target.Options.Sheet, m))
+	}
 
 	if cfg.TgtConn.Type.IsDb() {
 		// normalize casing of object names
@@ -895,24 +939,41 @@ func (t *Target) MD5() string {
 
 // SourceOptions are connection and stream processing options
 type SourceOptions struct {
-	TrimSpace      *bool               `json:"trim_space,omitempty" yaml:"trim_space,omitempty"`
-	EmptyAsNull    *bool               `json:"empty_as_null,omitempty" yaml:"empty_as_null,omitempty"`
-	Header         *bool               `json:"header,omitempty" yaml:"header,omitempty"`
-	Flatten        *bool               `json:"flatten,omitempty" yaml:"flatten,omitempty"`
-	FieldsPerRec   *int                `json:"fields_per_rec,omitempty" yaml:"fields_per_rec,omitempty"`
-	Compression    *iop.CompressorType `json:"compression,omitempty" yaml:"compression,omitempty"`
-	Format         *filesys.FileType   `json:"format,omitempty" yaml:"format,omitempty"`
-	NullIf         *string             `json:"null_if,omitempty" yaml:"null_if,omitempty"`
-	DatetimeFormat string              `json:"datetime_format,omitempty" yaml:"datetime_format,omitempty"`
-	SkipBlankLines *bool               `json:"skip_blank_lines,omitempty" yaml:"skip_blank_lines,omitempty"`
-	Delimiter      string              `json:"delimiter,omitempty" yaml:"delimiter,omitempty"`
-	MaxDecimals    *int                `json:"max_decimals,omitempty" yaml:"max_decimals,omitempty"`
-	JmesPath       *string             `json:"jmespath,omitempty" yaml:"jmespath,omitempty"`
-	Sheet          *string             `json:"sheet,omitempty" yaml:"sheet,omitempty"`
-	Range          *string             `json:"range,omitempty" yaml:"range,omitempty"`
-	Limit          *int                `json:"limit,omitempty" yaml:"limit,omitempty"`
-	Columns        any                 `json:"columns,omitempty" yaml:"columns,omitempty"`
-	Transforms     any                 `json:"transforms,omitempty" yaml:"transforms,omitempty"`
+	TrimSpace      *bool                  `json:"trim_space,omitempty" yaml:"trim_space,omitempty"`
+	EmptyAsNull    *bool                  `json:"empty_as_null,omitempty" yaml:"empty_as_null,omitempty"`
+	Header         *bool                  `json:"header,omitempty" yaml:"header,omitempty"`
+	Flatten        *bool                  `json:"flatten,omitempty" yaml:"flatten,omitempty"`
+	Normalize      *bool                  `json:"normalize,omitempty" yaml:"normalize,omitempty"`
+	UnionByName    *bool                  `json:"union_by_name,omitempty" yaml:"union_by_name,omitempty"`
+	FieldsPerRec   *int                   `json:"fields_per_rec,omitempty" yaml:"fields_per_rec,omitempty"`
+	Compression    *iop.CompressorType    `json:"compression,omitempty" yaml:"compression,omitempty"`
+	Format         *filesys.FileType      `json:"format,omitempty" yaml:"format,omitempty"`
+	NullIf         *string                `json:"null_if,omitempty" yaml:"null_if,omitempty"`
+	DatetimeFormat string                 `json:"datetime_format,omitempty" yaml:"datetime_format,omitempty"`
+	SkipBlankLines *bool                  `json:"skip_blank_lines,omitempty" yaml:"skip_blank_lines,omitempty"`
+	Delimiter      string                 `json:"delimiter,omitempty" yaml:"delimiter,omitempty"`
+	Quote          string                 `json:"quote,omitempty" yaml:"quote,omitempty"`
+	Escape         string                 `json:"escape,omitempty" yaml:"escape,omitempty"`
+	Comment        string                 `json:"comment,omitempty" yaml:"comment,omitempty"`
+	MaxDecimals    *int                   `json:"max_decimals,omitempty" yaml:"max_decimals,omitempty"`
+	JmesPath       *string                `json:"jmespath,omitempty" yaml:"jmespath,omitempty"`
+	Sheet          *string                `json:"sheet,omitempty" yaml:"sheet,omitempty"`
+	Range          *string                `json:"range,omitempty" yaml:"range,omitempty"`
+	RunDate        *string                `json:"run_date,omitempty" yaml:"run_date,omitempty"`
+	ArchiveGlob    *string                `json:"archive_glob,omitempty" yaml:"archive_glob,omitempty"`
+	Encryption     *filesys.Encryption    `json:"encryption,omitempty" yaml:"encryption,omitempty"`
+	API            *filesys.APIOptions    `json:"api,omitempty" yaml:"api,omitempty"`
+	Generate       *iop.GenerateOptions   `json:"generate,omitempty" yaml:"generate,omitempty"`
+	Singer         *filesys.SingerOptions `json:"singer,omitempty" yaml:"singer,omitempty"`
+	Sample         *iop.SampleOptions     `json:"sample,omitempty" yaml:"sample,omitempty"`
+	Lookup         *iop.LookupOptions     `json:"lookup,omitempty" yaml:"lookup,omitempty"`
+	PostLoad       *PostLoadOptions       `json:"post_load,omitempty" yaml:"post_load,omitempty"`
+	Limit          *int                   `json:"limit,omitempty" yaml:"limit,omitempty"`
+	Layout         any                    `json:"layout,omitempty" yaml:"layout,omitempty"`
+	SnapshotID     *int64                 `json:"snapshot_id,omitempty" yaml:"snapshot_id,omitempty"`
+	AsOf           *string                `json:"as_of,omitempty" yaml:"as_of,omitempty"`
+	Columns        any                    `json:"columns,omitempty" yaml:"columns,omitempty"`
+	Transforms     any                    `json:"transforms,omitempty" yaml:"transforms,omitempty"`
 
 	extraTransforms []string `json:"-" yaml:"-"`
 }
@@ -924,6 +985,16 @@ type TargetOptions struct {
 	Concurrency      int                 `json:"concurrency,omitempty" yaml:"concurrency,omitempty"`
 	DatetimeFormat   string              `json:"datetime_format,omitempty" yaml:"datetime_format,omitempty"`
 	Delimiter        string              `json:"delimiter,omitempty" yaml:"delimiter,omitempty"`
+	Quote            string              `json:"quote,omitempty" yaml:"quote,omitempty"`
+	Escape           string              `json:"escape,omitempty" yaml:"escape,omitempty"`
+	QuoteAll         *bool               `json:"quote_all,omitempty" yaml:"quote_all,omitempty"`
+	LineEnding       string              `json:"line_ending,omitempty" yaml:"line_ending,omitempty"`
+	NullAs           string              `json:"null_as,omitempty" yaml:"null_as,omitempty"`
+	BOM              *bool               `json:"bom,omitempty" yaml:"bom,omitempty"`
+	Encryption       *filesys.Encryption `json:"encryption,omitempty" yaml:"encryption,omitempty"`
+	API              *filesys.APIOptions `json:"api,omitempty" yaml:"api,omitempty"`
+	Manifest         *string             `json:"manifest,omitempty" yaml:"manifest,omitempty"`
+	SuccessMarker    *bool               `json:"success_marker,omitempty" yaml:"success_marker,omitempty"`
 	FileMaxRows      int64               `json:"file_max_rows,omitempty" yaml:"file_max_rows,omitempty"`
 	FileMaxBytes     int64               `json:"file_max_bytes,omitempty" yaml:"file_max_bytes,omitempty"`
 	Format           filesys.FileType    `json:"format,omitempty" yaml:"format,omitempty"`
@@ -931,7 +1002,13 @@ type TargetOptions struct {
 	UseBulk          *bool               `json:"use_bulk,omitempty" yaml:"use_bulk,omitempty"`
 	AddNewColumns    *bool               `json:"add_new_columns,omitempty" yaml:"add_new_columns,omitempty"`
 	AdjustColumnType *bool               `json:"adjust_column_type,omitempty" yaml:"adjust_column_type,omitempty"`
+	Dedupe           *bool               `json:"dedupe,omitempty" yaml:"dedupe,omitempty"`
 	ColumnCasing     *ColumnCasing       `json:"column_casing,omitempty" yaml:"column_casing,omitempty"`
+	Sheet            string              `json:"sheet,omitempty" yaml:"sheet,omitempty"`
+	XmlRoot          string              `json:"xml_root,omitempty" yaml:"xml_root,omitempty"`
+	XmlRow           string              `json:"xml_row,omitempty" yaml:"xml_row,omitempty"`
+	XmlAttributes    string              `json:"xml_attributes,omitempty" yaml:"xml_attributes,omitempty"`
+	Layout           any                 `json:"layout,omitempty" yaml:"layout,omitempty"`
 
 	TableKeys database.TableKeys `json:"table_keys,omitempty" yaml:"table_keys,omitempty"`
 	TableTmp  string             `json:"table_tmp,omitempty" yaml:"table_tmp,omitempty"`
@@ -945,6 +1022,7 @@ var SourceFileOptionsDefault = SourceOptions{
 	EmptyAsNull:    g.Bool(true),
 	Header:         g.Bool(true),
 	Flatten:        g.Bool(false),
+	UnionByName:    g.Bool(false),
 	Compression:    iop.CompressorTypePtr(iop.AutoCompressorType),
 	NullIf:         g.String("NULL"),
 	DatetimeFormat: "AUTO",
@@ -1030,12 +1108,45 @@ func (o *SourceOptions) SetDefaults(sourceOptions SourceOptions) {
 	if o.JmesPath == nil {
 		o.JmesPath = sourceOptions.JmesPath
 	}
+	if o.UnionByName == nil {
+		o.UnionByName = sourceOptions.UnionByName
+	}
+	if o.Normalize == nil {
+		o.Normalize = sourceOptions.Normalize
+	}
 	if o.Sheet == nil {
 		o.Sheet = sourceOptions.Sheet
 	}
 	if o.Range == nil {
 		o.Range = sourceOptions.Range
 	}
+	if o.RunDate == nil {
+		o.RunDate = sourceOptions.RunDate
+	}
+	if o.ArchiveGlob == nil {
+		o.ArchiveGlob = sourceOptions.ArchiveGlob
+	}
+	if o.Encryption == nil {
+		o.Encryption = sourceOptions.Encryption
+	}
+	if o.API == nil {
+		o.API = sourceOptions.API
+	}
+	if o.Generate == nil {
+		o.Generate = sourceOptions.Generate
+	}
+	if o.Singer == nil {
+		o.Singer = sourceOptions.Singer
+	}
+	if o.Sample == nil {
+		o.Sample = sourceOptions.Sample
+	}
+	if o.Lookup == nil {
+		o.Lookup = sourceOptions.Lookup
+	}
+	if o.PostLoad == nil {
+		o.PostLoad = sourceOptions.PostLoad
+	}
 	if o.DatetimeFormat == "" {
 		o.DatetimeFormat = sourceOptions.DatetimeFormat
 	}
@@ -1045,9 +1156,27 @@ func (o *SourceOptions) SetDefaults(sourceOptions SourceOptions) {
 	if o.Delimiter == "" {
 		o.Delimiter = sourceOptions.Delimiter
 	}
+	if o.Quote == "" {
+		o.Quote = sourceOptions.Quote
+	}
+	if o.Escape == "" {
+		o.Escape = sourceOptions.Escape
+	}
+	if o.Comment == "" {
+		o.Comment = sourceOptions.Comment
+	}
 	if o.MaxDecimals == nil {
 		o.MaxDecimals = sourceOptions.MaxDecimals
 	}
+	if o.Layout == nil {
+		o.Layout = sourceOptions.Layout
+	}
+	if o.SnapshotID == nil {
+		o.SnapshotID = sourceOptions.SnapshotID
+	}
+	if o.AsOf == nil {
+		o.AsOf = sourceOptions.AsOf
+	}
 	if o.Columns == nil {
 		o.Columns = sourceOptions.Columns
 	}
@@ -1092,6 +1221,9 @@ func (o *TargetOptions) SetDefaults(targetOptions TargetOptions) {
 	if o.AdjustColumnType == nil {
 		o.AdjustColumnType = targetOptions.AdjustColumnType
 	}
+	if o.Dedupe == nil {
+		o.Dedupe = targetOptions.Dedupe
+	}
 
 	if o.AddNewColumns == nil {
 		o.AddNewColumns = targetOptions.AddNewColumns
@@ -1102,6 +1234,36 @@ func (o *TargetOptions) SetDefaults(targetOptions TargetOptions) {
 	if o.Delimiter == "" {
 		o.Delimiter = targetOptions.Delimiter
 	}
+	if o.Quote == "" {
+		o.Quote = targetOptions.Quote
+	}
+	if o.Escape == "" {
+		o.Escape = targetOptions.Escape
+	}
+	if o.QuoteAll == nil {
+		o.QuoteAll = targetOptions.QuoteAll
+	}
+	if o.LineEnding == "" {
+		o.LineEnding = targetOptions.LineEnding
+	}
+	if o.NullAs == "" {
+		o.NullAs = targetOptions.NullAs
+	}
+	if o.BOM == nil {
+		o.BOM = targetOptions.BOM
+	}
+	if o.Encryption == nil {
+		o.Encryption = targetOptions.Encryption
+	}
+	if o.API == nil {
+		o.API = targetOptions.API
+	}
+	if o.Manifest == nil {
+		o.Manifest = targetOptions.Manifest
+	}
+	if o.SuccessMarker == nil {
+		o.SuccessMarker = targetOptions.SuccessMarker
+	}
 	if o.MaxDecimals == nil {
 		o.MaxDecimals = targetOptions.MaxDecimals
 	}
@@ -1111,6 +1273,35 @@ func (o *TargetOptions) SetDefaults(targetOptions TargetOptions) {
 	if o.TableKeys == nil {
 		o.TableKeys = targetOptions.TableKeys
 	}
+	if o.XmlRoot == "" {
+		o.XmlRoot = targetOptions.XmlRoot
+	}
+	if o.XmlRow == "" {
+		o.XmlRow = targetOptions.XmlRow
+	}
+	if o.XmlAttributes == "" {
+		o.XmlAttributes = targetOptions.XmlAttributes
+	}
+	if o.Sheet == "" {
+		o.Sheet = targetOptions.Sheet
+	}
+	if o.Layout == nil {
+		o.Layout = targetOptions.Layout
+	}
+}
+
+// layoutOptionString returns the fixed-width layout option as a string
+// so that the StreamProcessor parses it. The layout can be a file path,
+// a list of widths or a list of fields
+func layoutOptionString(layout any) string {
+	switch layoutV := layout.(type) {
+	case nil:
+		return ""
+	case string:
+		return layoutV
+	default:
+		return g.Marshal(layoutV)
+	}
 }
 
 func castKeyArray(keyI any) (key []string) {
@@ -3,7 +3,6 @@ package sling
 import (
 	"context"
 	"database/sql/driver"
-	"io"
 	"os"
 	"strings"
 
@@ -11,6 +10,7 @@ import (
 	"github.com/samber/lo"
 	"github.com/slingdata-io/sling-cli/core/dbio/connection"
 	"github.com/slingdata-io/sling-cli/core/dbio/database"
+	"github.com/slingdata-io/sling-cli/core/dbio/filesys"
 	"github.com/spf13/cast"
 	"gopkg.in/yaml.v2"
 )
@@ -21,6 +21,8 @@ type ReplicationConfig struct {
 	Defaults ReplicationStreamConfig             `json:"defaults,omitempty" yaml:"defaults,omitempty"`
 	Streams  map[string]*ReplicationStreamConfig `json:"streams,omitempty" yaml:"streams,omitempty"`
 	Env      map[string]any                      `json:"env,omitempty" yaml:"env,omitempty"`
+	Vars     map[string]any                      `json:"vars,omitempty" yaml:"vars,omitempty"`
+	Include  []string                            `json:"include,omitempty" yaml:"include,omitempty"` // fragments merged by LoadReplicationConfig
 
 	streamsOrdered []string
 	originalCfg    string
@@ -80,16 +82,8 @@ func (rd ReplicationConfig) StreamsOrdered() []string {
 
 // HasStream returns true if the stream name exists
 func (rd ReplicationConfig) HasStream(name string) bool {
-
-	normalize := func(n string) string {
-		n = strings.ReplaceAll(n, "`", "")
-		n = strings.ReplaceAll(n, `"`, "")
-		n = strings.ToLower(n)
-		return n
-	}
-
 	for streamName := range rd.Streams {
-		if normalize(streamName) == normalize(name) {
+		if normalizeStreamName(streamName) == normalizeStreamName(name) {
 			return true
 		}
 	}
@@ -103,7 +97,8 @@ func (rd *ReplicationConfig) ProcessWildcards() (err error) {
 	for name := range rd.Streams {
 		if name == "*" {
 			return g.Error("Must specify schema or path when using wildcard: 'my_schema.*', 'file://./my_folder/*', not '*'")
-		} else if strings.Contains(name, "*") {
+		} else if strings.Contains(name, "*") && !filesys.HasDateTokens(name) {
+			// date-templated paths are resolved when read
 			wildcardNames = append(wildcardNames, name)
 		}
 	}
@@ -288,6 +283,17 @@ func SetStreamDefaults(stream *ReplicationStreamConfig, replicationCfg Replicati
 // UnmarshalReplication converts a yaml file to a replication
 func UnmarshalReplication(replicYAML string) (config ReplicationConfig, err error) {
 
+	// render template expressions
+	replicYAML, err = RenderReplication(replicYAML)
+	if err != nil {
+		return
+	}
+
+	return unmarshalReplication(replicYAML)
+}
+
+// unmarshalReplication converts a rendered yaml file to a replication
+func unmarshalReplication(replicYAML string) (config ReplicationConfig, err error) {
 	m := g.M()
 	err = yaml.Unmarshal([]byte(replicYAML), &m)
 	if err != nil {
@@ -343,6 +349,15 @@ func UnmarshalReplication(replicYAML string) (config ReplicationConfig, err erro
 		config.Env = map[string]any{}
 	}
 
+	// parse vars
+	if vars, ok := m["vars"]; ok {
+		err = g.Unmarshal(g.Marshal(vars), &config.Vars)
+		if err != nil {
+			err = g.Error(err, "could not parse 'vars'")
+			return
+		}
+	}
+
 	// parse streams
 	err = g.Unmarshal(g.Marshal(streams), &config.Streams)
 	if err != nil {
@@ -421,19 +436,12 @@ func getSourceOptionsColumns(sourceOptionsNodes yaml.MapSlice) (columns map[stri
 }
 
 func LoadReplicationConfig(cfgPath string) (config ReplicationConfig, err error) {
-	cfgFile, err := os.Open(cfgPath)
-	if err != nil {
-		err = g.Error(err, "Unable to open replication path: "+cfgPath)
-		return
-	}
-
-	cfgBytes, err := io.ReadAll(cfgFile)
+	replicYAML, err := ReadReplicationFile(cfgPath)
 	if err != nil {
-		err = g.Error(err, "could not read from replication path: "+cfgPath)
 		return
 	}
 
-	config, err = UnmarshalReplication(string(cfgBytes))
+	config, err = unmarshalReplication(replicYAML) // already rendered
 	if err != nil {
 		err = g.Error(err, "Error parsing replication config")
 		return
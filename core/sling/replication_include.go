@@ -0,0 +1,246 @@
+package sling
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/flarco/g"
+	"github.com/samber/lo"
+	"github.com/spf13/cast"
+	"gopkg.in/yaml.v2"
+)
+
+// replicationFile holds the root nodes of a replication file merged
+// with its includes, and the file defining each stream
+type replicationFile struct {
+	rendered    string // rendered text of the file itself
+	included    bool   // whether the file has an `include` key
+	nodes       yaml.MapSlice
+	streams     yaml.MapSlice
+	streamFiles map[string]string // normalized stream name -> file path
+}
+
+// ReadReplicationFile reads a replication file, with its template expressions
+// rendered and its `include` fragments resolved relative to its folder.
+//
+// Each file is rendered once, with its own `vars` merged with the vars of the
+// files including it, the including file winning.
+//
+// Includes are merged in the order listed (glob matches sorted by name), and the
+// file itself is merged last. For `defaults`, `env` and `vars`, maps are merged
+// key by key with the later file winning, other values are replaced. Other
+// root keys (such as `source`) are replaced. Streams of the includes come first,
+// and a stream defined in more than one file is an error.
+func ReadReplicationFile(cfgPath string) (replicYAML string, err error) {
+	replicYAML, _, err = readReplicationFile(cfgPath)
+	return
+}
+
+// readReplicationFile returns the rendered replication, merged with its
+// includes if it has any (then included is true)
+func readReplicationFile(cfgPath string) (replicYAML string, included bool, err error) {
+	rf, err := loadReplicationFile(cfgPath, nil, nil)
+	if err != nil {
+		return "", false, err
+	} else if !rf.included {
+		return rf.rendered, false, nil // keep as is
+	}
+
+	out, err := yaml.Marshal(rf.merged())
+	if err != nil {
+		return "", false, g.Error(err, "could not marshal merged replication")
+	}
+
+	return string(out), true, nil
+}
+
+// loadReplicationFile loads a replication file or fragment, merged with its
+// includes. loading is the chain of files being loaded, to detect cycles.
+// parentVars are the vars of the files including it.
+func loadReplicationFile(cfgPath string, loading []string, parentVars map[string]any) (rf *replicationFile, err error) {
+	absPath, err := filepath.Abs(cfgPath)
+	if err != nil {
+		return nil, g.Error(err, "could not resolve path %s", cfgPath)
+	} else if lo.Contains(loading, absPath) {
+		return nil, g.Error("circular include: %s", strings.Join(append(loading, absPath), " -> "))
+	}
+	loading = append(loading, absPath)
+
+	cfgBytes, err := os.ReadFile(absPath)
+	if err != nil {
+		return nil, g.Error(err, "could not read from replication path: "+cfgPath)
+	}
+
+	vars, err := readReplicationVars(string(cfgBytes))
+	if err != nil {
+		return nil, g.Error(err, "could not parse 'vars' of %s", cfgPath)
+	}
+	vars = mergeVars(vars, parentVars)
+
+	replicYAML, err := renderReplication(string(cfgBytes), vars)
+	if err != nil {
+		return nil, g.Error(err, "could not render %s", cfgPath)
+	}
+
+	nodes := yaml.MapSlice{}
+	if err = yaml.Unmarshal([]byte(replicYAML), &nodes); err != nil {
+		return nil, g.Error(err, "Error parsing yaml content of %s", cfgPath)
+	}
+
+	rf = &replicationFile{rendered: replicYAML, streamFiles: map[string]string{}}
+	own := yaml.MapSlice{}
+	for _, node := range nodes {
+		if cast.ToString(node.Key) != "include" {
+			own = append(own, node)
+			continue
+		}
+		rf.included = true
+
+		var patterns []string
+		switch val := node.Value.(type) {
+		case string:
+			patterns = []string{val}
+		case []any:
+			patterns = cast.ToStringSlice(val)
+		case nil:
+		default:
+			return nil, g.Error("invalid include in %s, expected a path or a list of paths", cfgPath)
+		}
+
+		for _, pattern := range patterns {
+			if !filepath.IsAbs(pattern) {
+				pattern = filepath.Join(filepath.Dir(absPath), pattern)
+			}
+
+			matches, err := filepath.Glob(pattern)
+			if err != nil {
+				return nil, g.Error(err, "invalid include pattern %s", pattern)
+			} else if len(matches) == 0 {
+				return nil, g.Error("include %s of %s did not match any file", pattern, cfgPath)
+			}
+			sort.Strings(matches)
+
+			for _, match := range matches {
+				included, err := loadReplicationFile(match, loading, vars)
+				if err != nil {
+					return nil, g.Error(err, "could not include %s", match)
+				}
+				if err = rf.merge(match, included.merged(), included.streamFiles); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	ownStreamFiles := map[string]string{}
+	for _, node := range own {
+		if cast.ToString(node.Key) != "streams" {
+			continue
+		}
+		streamNodes, _ := node.Value.(yaml.MapSlice)
+		for _, streamNode := range streamNodes {
+			ownStreamFiles[normalizeStreamName(cast.ToString(streamNode.Key))] = cfgPath
+		}
+	}
+
+	if err = rf.merge(cfgPath, own, ownStreamFiles); err != nil {
+		return nil, err
+	}
+
+	return rf, nil
+}
+
+// merge merges the root nodes of a file into the replication.
+// streamFiles is the file defining each stream of the nodes.
+func (rf *replicationFile) merge(file string, nodes yaml.MapSlice, streamFiles map[string]string) (err error) {
+	for _, node := range nodes {
+		key := cast.ToString(node.Key)
+		switch key {
+		case "streams":
+			streamNodes, ok := node.Value.(yaml.MapSlice)
+			if !ok && node.Value != nil {
+				return g.Error("invalid streams in %s, expected a map", file)
+			}
+			for _, streamNode := range streamNodes {
+				name := normalizeStreamName(cast.ToString(streamNode.Key))
+				if existing, ok := rf.streamFiles[name]; ok {
+					return g.Error("stream %s is defined in both %s and %s", streamNode.Key, existing, streamFiles[name])
+				}
+				rf.streamFiles[name] = streamFiles[name]
+				rf.streams = append(rf.streams, streamNode)
+			}
+		case "defaults", "env", "vars":
+			rf.set(key, mergeYamlNodes(rf.get(key), node.Value))
+		default:
+			rf.set(key, node.Value)
+		}
+	}
+	return nil
+}
+
+// merged returns the root nodes, with the streams
+func (rf *replicationFile) merged() yaml.MapSlice {
+	nodes := append(yaml.MapSlice{}, rf.nodes...)
+	if len(rf.streams) > 0 {
+		nodes = append(nodes, yaml.MapItem{Key: "streams", Value: rf.streams})
+	}
+	return nodes
+}
+
+func (rf *replicationFile) get(key string) any {
+	for _, node := range rf.nodes {
+		if cast.ToString(node.Key) == key {
+			return node.Value
+		}
+	}
+	return nil
+}
+
+func (rf *replicationFile) set(key string, value any) {
+	for i, node := range rf.nodes {
+		if cast.ToString(node.Key) == key {
+			rf.nodes[i].Value = value
+			return
+		}
+	}
+	rf.nodes = append(rf.nodes, yaml.MapItem{Key: key, Value: value})
+}
+
+// mergeYamlNodes merges the override into the base. Maps are merged
+// key by key, other values are replaced (unless the override is null).
+func mergeYamlNodes(base, override any) any {
+	if override == nil {
+		return base
+	}
+
+	baseMap, ok1 := base.(yaml.MapSlice)
+	overrideMap, ok2 := override.(yaml.MapSlice)
+	if !ok1 || !ok2 {
+		return override
+	}
+
+	merged := append(yaml.MapSlice{}, baseMap...)
+	for _, item := range overrideMap {
+		found := false
+		for i := range merged {
+			if cast.ToString(merged[i].Key) == cast.ToString(item.Key) {
+				merged[i].Value = mergeYamlNodes(merged[i].Value, item.Value)
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, item)
+		}
+	}
+	return merged
+}
+
+// normalizeStreamName returns the stream name without quotes, in lower case
+func normalizeStreamName(name string) string {
+	name = strings.ReplaceAll(name, "`", "")
+	name = strings.ReplaceAll(name, `"`, "")
+	return strings.ToLower(name)
+}
@@ -0,0 +1,183 @@
+package sling
+
+import (
+	"bytes"
+	"os"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/flarco/g"
+	"github.com/slingdata-io/sling-cli/core/dbio/filesys"
+	"github.com/slingdata-io/sling-cli/core/dbio/iop"
+	"github.com/spf13/cast"
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	// templateActionRegex matches the template actions, such as `{{ .x }}`,
+	// skipping the quoted strings within
+	templateActionRegex = regexp.MustCompile(`(?s){{(?:"(?:\\.|[^"\\])*"|` + "`[^`]*`" + `|[^"` + "`" + `}]|}[^}"` + "`" + `])*}}`)
+
+	// varsKeyRegex matches a top-level `vars` key
+	varsKeyRegex = regexp.MustCompile(`(?m)^["']?vars["']?[ \t]*:`)
+)
+
+// templateMask replaces the template actions when parsing the vars
+const templateMask = "__sling_template__"
+
+// RenderReplication renders the template expressions of a replication, such as
+// `{{ range .tenants }}`, before it is parsed as YAML. The template data is the
+// top-level `vars` map, which is read as is. Text without `{{` is returned as is.
+func RenderReplication(replicYAML string) (rendered string, err error) {
+	if !strings.Contains(replicYAML, "{{") {
+		return replicYAML, nil
+	}
+
+	vars, err := readReplicationVars(replicYAML)
+	if err != nil {
+		return "", g.Error(err, "could not parse 'vars'")
+	}
+
+	return renderReplication(replicYAML, vars)
+}
+
+// renderReplication renders the template expressions of a replication with
+// the provided vars
+func renderReplication(replicYAML string, vars map[string]any) (rendered string, err error) {
+	if !strings.Contains(replicYAML, "{{") {
+		return replicYAML, nil
+	}
+
+	tmpl, err := template.New("replication").
+		Option("missingkey=error").
+		Funcs(replicationTemplateFuncs(vars)).
+		Parse(replicYAML)
+	if err != nil {
+		return "", g.Error(err, "could not parse replication template")
+	}
+
+	var buf bytes.Buffer
+	if err = tmpl.Execute(&buf, vars); err != nil {
+		return "", g.Error(err, "could not render replication template")
+	}
+
+	return buf.String(), nil
+}
+
+// readReplicationVars parses the top-level `vars` map. The replication is
+// parsed as YAML with its template actions masked: lines holding only actions
+// are blanked, other actions are replaced by a placeholder. The vars cannot
+// contain template expressions.
+func readReplicationVars(replicYAML string) (vars map[string]any, err error) {
+	vars = map[string]any{}
+
+	m := map[string]any{}
+	if err = yaml.Unmarshal([]byte(maskTemplate(replicYAML)), &m); err != nil {
+		if !varsKeyRegex.MatchString(replicYAML) {
+			return vars, nil // the template only yields YAML once rendered
+		}
+		return nil, g.Error(err, "could not parse replication with template expressions masked")
+	}
+
+	if strings.Contains(g.Marshal(m["vars"]), templateMask) {
+		return nil, g.Error("template expressions are not allowed in 'vars'")
+	}
+
+	// convert nested yaml maps for json-like access in templates
+	if err = g.Unmarshal(g.Marshal(m["vars"]), &vars); err != nil {
+		return nil, err
+	}
+	if vars == nil {
+		vars = map[string]any{}
+	}
+
+	return vars, nil
+}
+
+// mergeVars returns the base vars with the override merged in. Maps are
+// merged key by key, other values are replaced.
+func mergeVars(base, override map[string]any) map[string]any {
+	merged := make(map[string]any, len(base)+len(override))
+	for key, val := range base {
+		merged[key] = val
+	}
+	for key, val := range override {
+		baseMap, ok1 := merged[key].(map[string]any)
+		overrideMap, ok2 := val.(map[string]any)
+		if ok1 && ok2 {
+			val = mergeVars(baseMap, overrideMap)
+		}
+		merged[key] = val
+	}
+	return merged
+}
+
+// maskTemplate blanks the lines holding only template actions, such as
+// `{{- range .tenants }}`, and replaces the other actions by a placeholder
+func maskTemplate(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if strings.Contains(line, "{{") && strings.TrimSpace(templateActionRegex.ReplaceAllString(line, "")) == "" {
+			lines[i] = ""
+		}
+	}
+	return templateActionRegex.ReplaceAllString(strings.Join(lines, "\n"), templateMask)
+}
+
+// replicationTemplateFuncs returns the functions available in replication templates.
+// Dates are piped, such as `{{ now | date_add "-1d" | format "YYYY-MM-DD" }}`.
+func replicationTemplateFuncs(vars map[string]any) template.FuncMap {
+	toTime := func(val any) (time.Time, error) {
+		if t, ok := val.(time.Time); ok {
+			return t, nil
+		}
+		return cast.ToTimeE(val)
+	}
+
+	return template.FuncMap{
+		// now returns the current time, in UTC
+		"now": func() time.Time {
+			return time.Now().UTC()
+		},
+
+		// date_add shifts a date by an offset such as -1d, +6h, -1w, -1M or -1y
+		"date_add": func(offset string, date any) (time.Time, error) {
+			t, err := toTime(date)
+			if err != nil {
+				return t, g.Error(err, "invalid date for date_add")
+			}
+			return filesys.ParseRunDate(offset, t)
+		},
+
+		// format formats a date with a layout such as YYYY-MM-DD
+		"format": func(layout string, date any) (string, error) {
+			t, err := toTime(date)
+			if err != nil {
+				return "", g.Error(err, "invalid date for format")
+			}
+			return t.Format(iop.Iso8601ToGoLayout(layout)), nil
+		},
+
+		// env returns an environment variable, or the default if unset
+		"env": func(key string, defaultVal ...any) (any, error) {
+			if val, ok := os.LookupEnv(key); ok {
+				return val, nil
+			} else if len(defaultVal) > 0 {
+				return defaultVal[0], nil
+			}
+			return nil, g.Error("environment variable %s is not set", key)
+		},
+
+		// var returns a value of the vars block, or the default if missing
+		"var": func(key string, defaultVal ...any) (any, error) {
+			if val, ok := vars[key]; ok {
+				return val, nil
+			} else if len(defaultVal) > 0 {
+				return defaultVal[0], nil
+			}
+			return nil, g.Error("variable %s is not defined", key)
+		},
+	}
+}
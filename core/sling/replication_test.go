@@ -1,6 +1,8 @@
 package sling
 
 import (
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -33,3 +35,203 @@ streams:
 
 	g.PP(replication)
 }
+
+func TestReplicationTemplate(t *testing.T) {
+	os.Setenv("SLING_TEST_TEMPLATE_TARGET", "POSTGRES")
+	defer os.Unsetenv("SLING_TEST_TEMPLATE_TARGET")
+
+	yaml := `
+vars:
+	tenants: [acme, globex]
+	full: false
+source: MYSQL
+target: {{ env "SLING_TEST_TEMPLATE_TARGET" }}
+defaults:
+	mode: {{ if var "full" }}full-refresh{{ else }}incremental{{ end }}
+	primary_key: [id]
+	update_key: updated_at
+	object: {{ env "SLING_TEST_TEMPLATE_SCHEMA" "raw" }}.{stream_table}
+streams:
+{{- range .tenants }}
+	{{ . }}.orders:
+		object: raw.{{ . }}_orders_{{ "2024-03-01" | date_add "-1d" | format "YYYYMMDD" }}
+{{- end }}
+`
+	yaml = strings.ReplaceAll(yaml, "\t", "  ")
+
+	rendered, err := RenderReplication(yaml)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Contains(t, rendered, "target: POSTGRES")
+	assert.Contains(t, rendered, "object: raw.globex_orders_20240229")
+
+	replication, err := UnmarshalReplication(yaml)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "POSTGRES", replication.Target)
+	assert.Equal(t, IncrementalMode, replication.Defaults.Mode)
+	assert.Equal(t, "raw.{stream_table}", replication.Defaults.Object)
+	assert.Equal(t, []string{"acme.orders", "globex.orders"}, replication.StreamsOrdered())
+	assert.Equal(t, "raw.acme_orders_20240229", replication.Streams["acme.orders"].Object)
+	assert.Len(t, replication.Vars["tenants"], 2)
+
+	// no template expressions
+	plain := "source: A\ntarget: B\nstreams:\n  x: {object: y}\n"
+	rendered, err = RenderReplication(plain)
+	assert.NoError(t, err)
+	assert.Equal(t, plain, rendered)
+
+	// missing values
+	_, err = RenderReplication("source: {{ var \"missing\" }}\n")
+	assert.Error(t, err)
+	_, err = RenderReplication("source: {{ env \"SLING_TEST_TEMPLATE_MISSING\" }}\n")
+	assert.Error(t, err)
+	rendered, err = RenderReplication("source: {{ var \"missing\" \"DEFAULT\" }}\n")
+	assert.NoError(t, err)
+	assert.Equal(t, "source: DEFAULT\n", rendered)
+
+	// vars in flow style, with comments and anchors
+	rendered, err = RenderReplication("base: &base {schema: raw}\nvars: {<<: *base, table: orders} # shared\nsource: {{ .schema }}.{{ .table }}\n")
+	assert.NoError(t, err)
+	assert.Contains(t, rendered, "source: raw.orders")
+
+	// actions holding braces within quotes
+	rendered, err = RenderReplication("vars:\n  table: orders\nsql: \"select '{{ \"{{x}}\" }}' from {{ .table }}\"\n")
+	assert.NoError(t, err)
+	assert.Contains(t, rendered, "select '{{x}}' from orders")
+
+	// no template expressions in vars
+	_, err = RenderReplication("vars:\n  day: '{{ now }}'\nsource: {{ .day }}\n")
+	assert.Error(t, err)
+}
+
+func TestReplicationIncludes(t *testing.T) {
+	folder := t.TempDir()
+	writeFile := func(name, content string) string {
+		path := filepath.Join(folder, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte(strings.ReplaceAll(content, "\t", "  ")), 0644)
+		return path
+	}
+
+	writeFile("shared/defaults.yaml", `
+defaults:
+	mode: incremental
+	object: raw.{stream_table}
+	primary_key: [id]
+	source_options:
+		flatten: true
+env:
+	SLING_LOADED_AT_COLUMN: true
+	SLING_ROW_ID_COLUMN: false
+`)
+	writeFile("streams/a.yaml", `
+streams:
+	public.accounts:
+	public.orders:
+		update_key: updated_at
+`)
+	writeFile("streams/b.yaml", `
+streams:
+	public.users:
+		mode: full-refresh
+`)
+	path := writeFile("replication.yaml", `
+include:
+	- shared/defaults.yaml
+	- streams/*.yaml
+source: MYSQL
+target: POSTGRES
+defaults:
+	source_options:
+		empty_as_null: false
+env:
+	SLING_ROW_ID_COLUMN: true
+streams:
+	public.events:
+`)
+
+	replication, err := LoadReplicationConfig(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// streams of includes first, in order
+	assert.Equal(t, []string{"public.accounts", "public.orders", "public.users", "public.events"}, replication.StreamsOrdered())
+	assert.Equal(t, "updated_at", replication.Streams["public.orders"].UpdateKey)
+
+	// defaults and env are merged, the parent winning
+	assert.Equal(t, IncrementalMode, replication.Defaults.Mode)
+	assert.Equal(t, "raw.{stream_table}", replication.Defaults.Object)
+	if assert.NotNil(t, replication.Defaults.SourceOptions) {
+		assert.True(t, *replication.Defaults.SourceOptions.Flatten)
+		assert.False(t, *replication.Defaults.SourceOptions.EmptyAsNull)
+	}
+	assert.Equal(t, "true", g.F("%v", replication.Env["SLING_LOADED_AT_COLUMN"]))
+	assert.Equal(t, "true", g.F("%v", replication.Env["SLING_ROW_ID_COLUMN"]))
+
+	// duplicate streams
+	writeFile("streams/c.yaml", "streams:\n  PUBLIC.USERS:\n")
+	_, err = LoadReplicationConfig(path)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "is defined in both")
+	}
+	os.Remove(filepath.Join(folder, "streams/c.yaml"))
+
+	// circular includes
+	writeFile("loop.yaml", "include: [loop.yaml]\nsource: A\ntarget: B\nstreams:\n  x:\n")
+	_, err = LoadReplicationConfig(filepath.Join(folder, "loop.yaml"))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "circular include")
+	}
+
+	// fragments see the parent vars, each file is rendered once
+	writeFile("tenants/invoices.yaml", `
+vars:
+	schema: staging
+streams:
+{{- range .tenants }}
+	{{ . }}.invoices:
+		object: {{ $.schema }}.{{ . }}_invoices
+{{- end }}
+`)
+	path = writeFile("tenants.yaml", `
+include: [tenants/invoices.yaml]
+vars:
+	tenants: [acme]
+	schema: raw
+source: A
+target: B
+streams:
+	public.notes:
+		sql: "select '{{ "{{x}}" }}' as x"
+`)
+	replication, err = LoadReplicationConfig(path)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"acme.invoices", "public.notes"}, replication.StreamsOrdered())
+		assert.Equal(t, "raw.acme_invoices", replication.Streams["acme.invoices"].Object)
+		assert.Equal(t, "select '{{x}}' as x", replication.Streams["public.notes"].SQL)
+	}
+	_, err = ValidateFile(path)
+	assert.NoError(t, err)
+
+	// task files are not rendered
+	path = writeFile("task.yaml", `
+source:
+	conn: A
+	stream: public.notes
+	sql: "select '{{x}}' as x"
+target:
+	conn: B
+	object: main.notes
+`)
+	_, err = ValidateFile(path)
+	assert.NoError(t, err)
+
+	// missing include
+	writeFile("missing.yaml", "include: [nothing/*.yaml]\nsource: A\ntarget: B\nstreams:\n  x:\n")
+	_, err = LoadReplicationConfig(filepath.Join(folder, "missing.yaml"))
+	assert.Error(t, err)
+}
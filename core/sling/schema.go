@@ -0,0 +1,198 @@
+package sling
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/flarco/g"
+	"github.com/samber/lo"
+	"github.com/spf13/cast"
+)
+
+const jsonSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// JSONSchema is the subset of JSON Schema used to describe the
+// task and replication files, for validation and editor autocomplete
+type JSONSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Type                 any                    `json:"type,omitempty"` // a type, or a list of types
+	Enum                 []any                  `json:"enum,omitempty"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	AdditionalProperties any                    `json:"additionalProperties,omitempty"` // false, or the schema of the values
+	Items                *JSONSchema            `json:"items,omitempty"`
+}
+
+// TaskSchema returns the JSON Schema of a task file
+func TaskSchema() *JSONSchema {
+	schema := schemaOf(reflect.TypeOf(Config{}), map[reflect.Type]bool{})
+	schema.Schema = jsonSchemaDraft
+	schema.Title = "Sling Task"
+	return schema
+}
+
+// ReplicationSchema returns the JSON Schema of a replication file
+func ReplicationSchema() *JSONSchema {
+	schema := schemaOf(reflect.TypeOf(ReplicationConfig{}), map[reflect.Type]bool{})
+	schema.Schema = jsonSchemaDraft
+	schema.Title = "Sling Replication"
+	return schema
+}
+
+// schemaOf derives the schema of a type from its json tags. Struct fields
+// which are ignored (`json:"-"`) or internal (prefixed with `_`) are left out.
+func schemaOf(t reflect.Type, visiting map[reflect.Type]bool) *JSONSchema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t {
+	case reflect.TypeOf(Mode("")):
+		return &JSONSchema{Type: "string", Enum: []any{
+			string(FullRefreshMode), string(IncrementalMode), string(TruncateMode),
+			string(SnapshotMode), string(BackfillMode),
+		}}
+	case reflect.TypeOf(time.Time{}):
+		return &JSONSchema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &JSONSchema{Type: "string"}
+	case reflect.Bool:
+		return &JSONSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &JSONSchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &JSONSchema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &JSONSchema{Type: "array", Items: schemaOf(t.Elem(), visiting)}
+	case reflect.Map:
+		return &JSONSchema{Type: "object", AdditionalProperties: schemaOf(t.Elem(), visiting)}
+	case reflect.Struct:
+		if visiting[t] {
+			return &JSONSchema{} // recursive type, accept anything
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+
+		schema := &JSONSchema{
+			Type:                 "object",
+			Properties:           map[string]*JSONSchema{},
+			AdditionalProperties: false,
+		}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if field.Anonymous && name == "" {
+				// embedded struct fields are promoted
+				for key, prop := range schemaOf(field.Type, visiting).Properties {
+					schema.Properties[key] = prop
+				}
+				continue
+			} else if name == "-" || strings.HasPrefix(name, "_") {
+				continue
+			} else if name == "" {
+				name = field.Name
+			}
+			schema.Properties[name] = schemaOf(field.Type, visiting)
+		}
+		return schema
+	}
+
+	return &JSONSchema{} // any
+}
+
+// Validate returns the issues of the value against the schema, such as unknown
+// keys, types or values. Nulls are accepted. The path prefixes the messages.
+func (s *JSONSchema) Validate(value any, path string) (issues []string) {
+	if value == nil {
+		return nil
+	}
+
+	issue := func(format string, args ...any) {
+		prefix := lo.Ternary(path != "", path+": ", "")
+		issues = append(issues, prefix+g.F(format, args...))
+	}
+
+	if len(s.Enum) > 0 && !lo.Contains(s.Enum, any(cast.ToString(value))) {
+		issue("invalid value '%v', expected one of %s", value, strings.Join(cast.ToStringSlice(s.Enum), ", "))
+		return
+	}
+
+	switch s.Type {
+	case "object":
+		m, ok := value.(map[string]any)
+		if !ok {
+			issue("expected an object, got %s", jsonTypeOf(value))
+			return
+		}
+
+		keys := make([]string, 0, len(m))
+		for key := range m {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			keyPath := lo.Ternary(path != "", path+"."+key, key)
+			if prop, ok := s.Properties[key]; ok {
+				issues = append(issues, prop.Validate(m[key], keyPath)...)
+			} else if additional, ok := s.AdditionalProperties.(*JSONSchema); ok {
+				issues = append(issues, additional.Validate(m[key], keyPath)...)
+			} else if s.AdditionalProperties == false {
+				issue("unknown key '%s'", key)
+			}
+		}
+	case "array":
+		arr, ok := value.([]any)
+		if !ok {
+			issue("expected an array, got %s", jsonTypeOf(value))
+			return
+		}
+		for i, item := range arr {
+			issues = append(issues, s.Items.Validate(item, g.F("%s[%d]", path, i))...)
+		}
+	case "string", "boolean", "integer", "number":
+		valueType := jsonTypeOf(value)
+		switch {
+		case valueType == s.Type:
+		case s.Type == "number" && valueType == "integer":
+		case s.Type == "string" && valueType != "object" && valueType != "array":
+			// scalars are accepted as strings
+		default:
+			issue("expected %s, got %s", s.Type, valueType)
+		}
+	}
+
+	return issues
+}
+
+// jsonTypeOf returns the JSON Schema type of a decoded value
+func jsonTypeOf(value any) string {
+	switch v := value.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return "integer"
+	case float32, float64:
+		if cast.ToFloat64(v) == float64(int64(cast.ToFloat64(v))) {
+			return "integer"
+		}
+		return "number"
+	}
+	return "null"
+}
@@ -303,6 +303,11 @@ func (t *TaskExecution) getMetadata() (metadata iop.Metadata) {
 		metadata.RowNum.Key = slingRowNumColumn
 	}
 
+	// dedupe keeps the last row seen, among those of the same update key
+	if t.Config.dedupeBySeq() {
+		metadata.RowSeq.Key = slingDedupeSeqColumn
+	}
+
 	// StarRocks: add _sling_row_id column if there is no primary,
 	// duplicate or hash key defined and set as Hash Key
 	if t.Config.TgtConn.Type == dbio.TypeDbStarRocks {
@@ -438,6 +443,40 @@ func (t *TaskExecution) sourceOptionsMap() (options map[string]any) {
 		options["columns"] = g.Marshal(iop.NewColumns(columns...))
 	}
 
+	if layout := t.Config.Source.Options.Layout; layout != nil {
+		options["layout"] = layoutOptionString(layout)
+	}
+
+	if encryption := t.Config.Source.Options.Encryption; encryption != nil {
+		// set as string so that it is passed as a prop
+		options["encryption"] = g.Marshal(encryption)
+	}
+
+	if api := t.Config.Source.Options.API; api != nil {
+		// set as string so that it is passed as a prop
+		options["api"] = g.Marshal(api)
+	}
+
+	if generate := t.Config.Source.Options.Generate; generate != nil {
+		// set as string so that it is passed as a prop
+		options["generate"] = g.Marshal(generate)
+	}
+
+	if singer := t.Config.Source.Options.Singer; singer != nil {
+		// set as string so that it is passed as a prop
+		options["singer"] = g.Marshal(singer)
+	}
+
+	if sample := t.Config.Source.Options.Sample; sample != nil {
+		// set as string so that StreamProcessor parses it
+		options["sample"] = g.Marshal(sample)
+	}
+
+	if snapshotID := t.Config.Source.Options.SnapshotID; snapshotID != nil {
+		// iceberg snapshot ids exceed float64 precision
+		options["snapshot_id"] = cast.ToString(*snapshotID)
+	}
+
 	if transforms := t.Config.Source.Options.Transforms; transforms != nil {
 		colTransforms := map[string][]string{}
 
@@ -0,0 +1,195 @@
+package sling
+
+import (
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/flarco/g"
+	"github.com/slingdata-io/sling-cli/core/dbio/iop"
+)
+
+// childConfig returns the config to write a normalized child stream into
+// its own target, named after the parent target with the child name as
+// suffix (such as `orders__items`). Child records are merged on their
+// generated key, unless the parent target is fully replaced.
+func (cfg *Config) childConfig(name string) *Config {
+	childCfg := *cfg
+
+	childCfg.Source.Select = nil
+	childCfg.Source.UpdateKey = ""
+	childCfg.Source.PrimaryKeyI = nil
+	childCfg.Source.columns = nil
+	switch cfg.Mode {
+	case FullRefreshMode, TruncateMode:
+	default:
+		childCfg.Mode = IncrementalMode
+		childCfg.Source.PrimaryKeyI = []string{iop.NormalizeIDColumn}
+	}
+
+	tgtOptions := *cfg.Target.Options
+	tgtOptions.TableTmp = ""
+	tgtOptions.TableDDL = ""
+	tgtOptions.PreSQL = ""
+	tgtOptions.PostSQL = ""
+	childCfg.Target.Options = &tgtOptions
+	childCfg.Target.TmpTableCreated = false
+	childCfg.Target.columns = nil
+
+	if cfg.TgtConn.Type.IsFile() {
+		childCfg.TgtConn = *cfg.TgtConn.Copy()
+		childCfg.TgtConn.Set(g.M("url", childFileURL(cfg.TgtConn.URL(), name)))
+		childCfg.Target.Object = childFileURL(cfg.Target.Object, name)
+	} else {
+		childCfg.Target.Object = childTableName(cfg.Target.Object, name)
+	}
+
+	return &childCfg
+}
+
+// childTableName appends the child name to the table name,
+// keeping the closing quote of quoted names
+func childTableName(table, name string) string {
+	for _, quote := range []string{`"`, "`", "]"} {
+		if strings.HasSuffix(table, quote) {
+			return strings.TrimSuffix(table, quote) + "__" + name + quote
+		}
+	}
+	return table + "__" + name
+}
+
+// childFileURL inserts the child name in the file url, before the file
+// extension (`orders__items.csv`), or after the folder of partitioned
+// files (`orders__items/*.csv`)
+func childFileURL(url, name string) string {
+	url = strings.TrimSuffix(url, "/")
+	folder, fileName := path.Split(url)
+
+	switch {
+	case strings.HasPrefix(fileName, "*"):
+		return strings.TrimSuffix(folder, "/") + "__" + name + "/" + fileName
+	case strings.Contains(fileName, "."):
+		i := strings.Index(fileName, ".")
+		return folder + fileName[:i] + "__" + name + fileName[i:]
+	}
+	return url + "__" + name
+}
+
+// childTask returns the task to write a child stream, with the child
+// config, since the writers read the task config
+func (t *TaskExecution) childTask(cfg *Config, name string) *TaskExecution {
+	return &TaskExecution{
+		ExecID:      t.ExecID,
+		Config:      cfg.childConfig(name),
+		Type:        t.Type,
+		Context:     t.Context,
+		Replication: t.Replication,
+		PBar:        &ProgressBar{finished: true}, // progress shows the parent
+	}
+}
+
+// writeChildStreams writes the normalized child streams as they appear,
+// concurrently with the parent stream, which waits on its child streams.
+// A failed child write stops the parent stream. The returned function
+// waits for the child writes, or stops them if the parent write failed.
+func (t *TaskExecution) writeChildStreams(write func(task *TaskExecution, df *iop.Dataflow) error) (wait func(parentErr error) error) {
+	if t.df == nil || t.df.ChildStreams == nil {
+		return func(error) error { return nil }
+	}
+
+	parentCfg := *t.Config // the parent write updates its config
+	eG := g.ErrorGroup{}
+	children := []*iop.ChildStream{}
+	stopped := false
+	wg := sync.WaitGroup{}
+	mux := sync.Mutex{}
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		for child := range t.df.ChildStreams {
+			mux.Lock()
+			children = append(children, child)
+			if stopped {
+				child.Context.Cancel()
+				mux.Unlock()
+				continue
+			}
+			mux.Unlock()
+
+			wg.Add(1)
+			go func(child *iop.ChildStream) {
+				defer wg.Done()
+
+				task := t.childTask(&parentCfg, child.Name)
+				defer task.Cleanup()
+
+				df, err := iop.MakeDataFlow(child.Datastream)
+				if err == nil {
+					err = write(task, df)
+				}
+				if err != nil {
+					err = g.Error(err, "could not write child stream %s", child.Name)
+					mux.Lock()
+					eG.Capture(err)
+					mux.Unlock()
+					t.df.Context.CaptureErr(err) // stop the parent stream
+				}
+			}(child)
+		}
+	}()
+
+	return func(parentErr error) error {
+		if parentErr != nil {
+			mux.Lock()
+			stopped = true
+			for _, child := range children {
+				child.Context.Cancel()
+			}
+			mux.Unlock()
+			return nil // the parent stream may not end
+		}
+		<-done
+		wg.Wait()
+		return eG.Err()
+	}
+}
+
+// writeChildStreamsToDb writes the normalized child streams into their own
+// tables, each with its own connection, since transactions are per connection
+func (t *TaskExecution) writeChildStreamsToDb() (wait func(parentErr error) error) {
+	return t.writeChildStreams(func(task *TaskExecution, df *iop.Dataflow) (err error) {
+		tgtConn, err := task.newTgtDBConn(t.Context.Ctx)
+		if err != nil {
+			return err
+		}
+		if err = tgtConn.Connect(); err != nil {
+			return g.Error(err, "could not connect to target")
+		}
+		defer tgtConn.Close()
+
+		cnt, err := task.WriteToDb(task.Config, df, tgtConn)
+		if err != nil {
+			if task.Config.Target.TmpTableCreated {
+				tgtConn.DropTable(task.Config.Target.Options.TableTmp)
+			}
+			return err
+		}
+
+		task.SetProgress("inserted %d rows into %s", cnt, task.Config.Target.Object)
+		return nil
+	})
+}
+
+// writeChildStreamsToFile writes the normalized child streams into their own files
+func (t *TaskExecution) writeChildStreamsToFile() (wait func(parentErr error) error) {
+	return t.writeChildStreams(func(task *TaskExecution, df *iop.Dataflow) (err error) {
+		cnt, err := task.WriteToFile(task.Config, df)
+		if err != nil {
+			return err
+		}
+
+		task.SetProgress("wrote %d rows to %s", cnt, task.Config.TgtConn.URL())
+		return nil
+	})
+}
@@ -0,0 +1,54 @@
+package sling
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/flarco/g"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeChildStreams(t *testing.T) {
+	folder := t.TempDir()
+
+	content := `[
+		{"order_id": 1, "items": [{"sku": "a", "options": [{"name": "gift"}]}, {"sku": "b"}]},
+		{"order_id": 2, "items": [{"sku": "c"}]}
+	]`
+	os.WriteFile(filepath.Join(folder, "orders.json"), []byte(content), 0644)
+
+	runTask := func(tgtConn, object string) error {
+		cfg := &Config{
+			Source: Source{
+				Conn:    "file://",
+				Stream:  "file://" + filepath.Join(folder, "orders.json"),
+				Options: &SourceOptions{Normalize: g.Bool(true)},
+			},
+			Target: Target{Conn: tgtConn, Object: object},
+			Mode:   FullRefreshMode,
+		}
+		return runTestTask(cfg)
+	}
+
+	// file target, a file per child stream
+	err := runTask("file://", "file://"+filepath.Join(folder, "orders.csv"))
+	if assert.NoError(t, err) {
+		orders, _ := os.ReadFile(filepath.Join(folder, "orders.csv"))
+		items, _ := os.ReadFile(filepath.Join(folder, "orders__items.csv"))
+		options, _ := os.ReadFile(filepath.Join(folder, "orders__items__options.csv"))
+		assert.Equal(t, 3, len(strings.Split(strings.TrimSpace(string(orders)), "\n")))
+		assert.Equal(t, 4, len(strings.Split(strings.TrimSpace(string(items)), "\n")))
+		assert.True(t, strings.HasPrefix(string(items), "_sling_parent_id,_sling_index,_sling_id,sku"))
+		assert.Contains(t, string(options), "gift")
+	}
+}
+
+func TestChildObjectNames(t *testing.T) {
+	assert.Equal(t, "main.orders__items", childTableName("main.orders", "items"))
+	assert.Equal(t, `"main"."orders__items"`, childTableName(`"main"."orders"`, "items"))
+	assert.Equal(t, "s3://bucket/orders__items.csv.gz", childFileURL("s3://bucket/orders.csv.gz", "items"))
+	assert.Equal(t, "s3://bucket/orders__items/*.parquet", childFileURL("s3://bucket/orders/*.parquet", "items"))
+	assert.Equal(t, "s3://bucket/orders__items", childFileURL("s3://bucket/orders/", "items"))
+}
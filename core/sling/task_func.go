@@ -176,6 +176,31 @@ func insertFromTemp(cfg *Config, tgtConn database.Connection) (err error) {
 	return
 }
 
+// dedupeTemp creates a copy of the temp table holding the latest row per
+// primary key, by update key, or else the last row seen. It returns the name
+// of the copy, to upsert from.
+func dedupeTemp(cfg *Config, tgtConn database.Connection, tableTmp database.Table) (dedupedTable string, err error) {
+	// the sequence breaks the ties, and is not loaded into the target table
+	orderFields := []string{slingDedupeSeqColumn}
+	if cfg.Source.UpdateKey != "" {
+		orderFields = []string{cfg.Source.UpdateKey, slingDedupeSeqColumn}
+	}
+	dropFields := []string{slingDedupeSeqColumn}
+
+	dedupedTable, err = database.Dedupe(tgtConn, tgtConn.Tx(), tableTmp.FullName(), cfg.Source.PrimaryKey(), orderFields, dropFields)
+	if err != nil {
+		return "", err
+	}
+
+	tmpCnt, _ := tgtConn.GetCount(tableTmp.FullName())
+	dedupedCnt, _ := tgtConn.GetCount(dedupedTable)
+	if tmpCnt > dedupedCnt {
+		g.Debug("removed %d duplicate rows by primary key", tmpCnt-dedupedCnt)
+	}
+
+	return dedupedTable, nil
+}
+
 func getIncrementalValue(cfg *Config, tgtConn database.Connection, srcConnVarMap map[string]string) (val string, err error) {
 	// get table columns type for table creation if not exists
 	// in order to get max value
@@ -248,6 +273,23 @@ func getIncrementalValue(cfg *Config, tgtConn database.Connection, srcConnVarMap
 	return
 }
 
+// apiIncrementalVarMap formats the incremental values of REST API
+// sources, which are bound to a query parameter
+var apiIncrementalVarMap = map[string]string{
+	"timestamp_layout_str": "{value}",
+	"timestamp_layout":     time.RFC3339,
+	"date_layout_str":      "{value}",
+	"date_layout":          "2006-01-02",
+}
+
+// apiIncrementalValue unquotes the incremental value of string columns
+func apiIncrementalValue(val string) string {
+	if len(val) >= 2 && strings.HasPrefix(val, `'`) && strings.HasSuffix(val, `'`) {
+		val = strings.ReplaceAll(val[1:len(val)-1], `''`, `'`)
+	}
+	return val
+}
+
 func getRate(cnt uint64) string {
 	return humanize.Commaf(math.Round(cast.ToFloat64(cnt) / time.Since(start).Seconds()))
 }
@@ -0,0 +1,125 @@
+package sling
+
+import (
+	"strings"
+
+	"github.com/flarco/g"
+	"github.com/slingdata-io/sling-cli/core/dbio"
+	"github.com/slingdata-io/sling-cli/core/dbio/connection"
+	"github.com/slingdata-io/sling-cli/core/dbio/database"
+	"github.com/slingdata-io/sling-cli/core/dbio/filesys"
+	"github.com/slingdata-io/sling-cli/core/dbio/iop"
+)
+
+// validateLookup checks the lookup options and its connection
+func validateLookup(lookup *iop.LookupOptions, connsMap map[string]connection.ConnEntry) (err error) {
+	if err = lookup.Validate(); err != nil {
+		return err
+	}
+
+	if lookup.Conn != "" {
+		c, ok := connsMap[strings.ToLower(lookup.Conn)]
+		if !ok {
+			return g.Error("could not find lookup connection %s", lookup.Conn)
+		} else if !c.Connection.Type.IsDb() && !c.Connection.Type.IsFile() {
+			return g.Error("lookup connection %s is not a database or file system", lookup.Conn)
+		}
+	}
+	return nil
+}
+
+// applyLookup enriches the rows of the dataflow with the columns
+// of the reference dataset. The streams are merged into one.
+func (t *TaskExecution) applyLookup(df *iop.Dataflow) (nDf *iop.Dataflow, err error) {
+	opts := t.Config.Source.Options.Lookup
+
+	t.SetProgress("loading lookup reference %s", opts.Object)
+	ref, err := t.readLookupReference(opts)
+	if err != nil {
+		return df, g.Error(err, "could not read lookup reference %s", opts.Object)
+	}
+
+	lookup, err := iop.NewLookup(opts, ref)
+	if err != nil {
+		return df, g.Error(err, "could not index lookup reference")
+	}
+	g.Debug("loaded %d lookup keys from %s", lookup.Len(), opts.Object)
+
+	nDs, err := lookup.Apply(iop.MergeDataflow(df))
+	if err != nil {
+		return df, g.Error(err, "could not apply lookup")
+	}
+
+	nDf, err = iop.MakeDataFlow(nDs)
+	if err != nil {
+		return df, g.Error(err, "could not make dataflow with lookup")
+	}
+
+	// carry the file paths read, and the state once the source is consumed
+	nDf.FsURL = df.FsURL
+	nDf.FsPaths = df.FsPaths
+	nDf.ChildStreams = df.ChildStreams
+	nDs.Defer(func() {
+		nDf.SingerState, nDf.SingerStatePath = df.SingerState, df.SingerStatePath
+		if err := lookup.Err(); err != nil {
+			nDf.Context.CaptureErr(err)
+		}
+	})
+
+	return nDf, nil
+}
+
+// readLookupReference reads the reference dataset from a table or query of a
+// database connection, or from a file of a file connection (local by default)
+func (t *TaskExecution) readLookupReference(opts *iop.LookupOptions) (data iop.Dataset, err error) {
+	conn := connection.LocalFileConnEntry().Connection
+	if opts.Conn != "" {
+		for _, c := range connection.GetLocalConns() {
+			if strings.EqualFold(c.Connection.Name, opts.Conn) {
+				conn = *c.Connection.Copy()
+			}
+		}
+	}
+
+	if conn.Type.IsDb() {
+		dbConn, err := conn.AsDatabase()
+		if err != nil {
+			return data, g.Error(err, "could not initialize lookup connection")
+		} else if err = dbConn.Connect(); err != nil {
+			return data, g.Error(err, "could not connect to lookup connection %s", opts.Conn)
+		}
+		defer dbConn.Close()
+
+		table, err := database.ParseTableName(opts.Object, dbConn.GetType())
+		if err != nil {
+			return data, g.Error(err, "could not parse lookup object")
+		}
+
+		sql := table.SQL
+		if sql == "" {
+			sql = table.Select()
+		}
+		return dbConn.QueryContext(t.Context.Ctx, sql)
+	}
+
+	url := opts.Object
+	if !strings.Contains(url, "://") {
+		if conn.Type == dbio.TypeFileLocal {
+			url = "file://" + url
+		} else {
+			url = strings.TrimSuffix(conn.URL(), "/") + "/" + strings.TrimPrefix(url, "/")
+		}
+	}
+
+	fs, err := filesys.NewFileSysClientFromURLContext(t.Context.Ctx, url, g.MapToKVArr(conn.DataS())...)
+	if err != nil {
+		return data, g.Error(err, "could not obtain client for %s", url)
+	}
+
+	refDf, err := fs.ReadDataflow(url)
+	if err != nil {
+		return data, g.Error(err, "could not read %s", url)
+	}
+
+	return refDf.Collect()
+}
@@ -0,0 +1,156 @@
+package sling
+
+import (
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/flarco/g"
+	"github.com/slingdata-io/sling-cli/core/dbio"
+	"github.com/slingdata-io/sling-cli/core/dbio/connection"
+	"github.com/slingdata-io/sling-cli/core/dbio/filesys"
+	"github.com/slingdata-io/sling-cli/core/dbio/iop"
+)
+
+// PostLoadAction is the action applied to the source files once loaded
+type PostLoadAction string
+
+const (
+	PostLoadArchive PostLoadAction = "archive"
+	PostLoadDelete  PostLoadAction = "delete"
+	PostLoadRename  PostLoadAction = "rename"
+)
+
+// PostLoadOptions define what happens to the source files
+// once their data has been committed to the target
+type PostLoadOptions struct {
+	Action PostLoadAction `json:"action,omitempty" yaml:"action,omitempty"`
+
+	// ArchivePath is the folder where files are moved. It can be a URL, or a
+	// path relative to the root (bucket, host) of the archive connection.
+	// Date placeholders such as {YYYY} are replaced.
+	ArchivePath string `json:"archive_path,omitempty" yaml:"archive_path,omitempty"`
+	ArchiveConn string `json:"archive_conn,omitempty" yaml:"archive_conn,omitempty"` // source connection by default
+
+	// Suffix is appended to the file names with the rename action
+	Suffix string `json:"suffix,omitempty" yaml:"suffix,omitempty"`
+}
+
+// Validate checks the post-load options
+func (o *PostLoadOptions) Validate(connsMap map[string]connection.ConnEntry) (err error) {
+	o.Action = PostLoadAction(strings.ToLower(string(o.Action)))
+	switch o.Action {
+	case PostLoadArchive:
+		if o.ArchivePath == "" {
+			return g.Error("archive_path is required for the archive action")
+		}
+		if o.ArchiveConn != "" {
+			c, ok := connsMap[strings.ToLower(o.ArchiveConn)]
+			if !ok {
+				return g.Error("could not find archive connection %s", o.ArchiveConn)
+			} else if !c.Connection.Type.IsFile() {
+				return g.Error("archive connection %s is not a file system", o.ArchiveConn)
+			}
+		}
+	case PostLoadRename:
+		if o.Suffix == "" {
+			o.Suffix = ".processed"
+		}
+	case PostLoadDelete:
+	default:
+		return g.Error("invalid post_load action '%s'. Expected archive, delete or rename", o.Action)
+	}
+	return nil
+}
+
+// postLoadSourceFiles archives, deletes or renames the source files that were
+// read. It is called once the data is committed to the target.
+func (t *TaskExecution) postLoadSourceFiles() (err error) {
+	postLoad := t.Config.Source.Options.PostLoad
+	if postLoad == nil || t.df == nil || t.df.Err() != nil || len(t.df.FsPaths) == 0 {
+		return nil
+	} else if t.Config.Source.Limit() > 0 {
+		g.Warn("source limit is specified, not applying post_load %s on source files", postLoad.Action)
+		return nil
+	}
+
+	srcFs, err := filesys.NewFileSysClientFromURLContext(t.Context.Ctx, t.Config.SrcConn.URL(), g.MapToKVArr(t.Config.SrcConn.DataS())...)
+	if err != nil {
+		return g.Error(err, "could not obtain client for source")
+	}
+
+	archiveFs, archiveURL := srcFs, ""
+	if postLoad.Action == PostLoadArchive {
+		archiveFs, archiveURL, err = t.getArchiveFs(postLoad)
+		if err != nil {
+			return g.Error(err, "could not obtain client for archive")
+		}
+	}
+
+	for _, path := range t.df.FsPaths {
+		switch postLoad.Action {
+		case PostLoadArchive:
+			tgtURL := archiveURL + "/" + relativeFilePath(t.Config.SrcConn.URL(), path)
+			g.Debug("archiving %s to %s", path, tgtURL)
+			err = filesys.MoveFile(srcFs, path, archiveFs, tgtURL)
+		case PostLoadRename:
+			g.Debug("renaming %s to %s", path, path+postLoad.Suffix)
+			err = filesys.MoveFile(srcFs, path, srcFs, path+postLoad.Suffix)
+		case PostLoadDelete:
+			g.Debug("deleting %s", path)
+			err = filesys.DeleteFile(srcFs, path)
+		}
+		if err != nil {
+			return g.Error(err, "could not %s source file %s", postLoad.Action, path)
+		}
+	}
+
+	t.SetProgress("applied post_load %s on %d source files", postLoad.Action, len(t.df.FsPaths))
+
+	return nil
+}
+
+// getArchiveFs returns the file system client and folder url of the archive
+func (t *TaskExecution) getArchiveFs(postLoad *PostLoadOptions) (fs filesys.FileSysClient, archiveURL string, err error) {
+	conn := t.Config.SrcConn
+	if postLoad.ArchiveConn != "" {
+		for _, c := range connection.GetLocalConns() {
+			if strings.EqualFold(c.Connection.Name, postLoad.ArchiveConn) {
+				conn = *c.Connection.Copy()
+			}
+		}
+	}
+
+	archiveURL = strings.TrimSuffix(g.Rm(postLoad.ArchivePath, iop.GetISO8601DateMap(time.Now())), "/")
+	if !strings.Contains(archiveURL, "://") {
+		if conn.Type == dbio.TypeFileLocal {
+			archiveURL = "file://" + archiveURL
+		} else {
+			archiveURL = rootURL(conn.URL()) + "/" + strings.TrimPrefix(archiveURL, "/")
+		}
+	}
+
+	fs, err = filesys.NewFileSysClientFromURLContext(t.Context.Ctx, archiveURL, g.MapToKVArr(conn.DataS())...)
+	return fs, archiveURL, err
+}
+
+// rootURL returns the scheme and host of the url, such as s3://bucket
+func rootURL(urlStr string) string {
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return strings.TrimSuffix(urlStr, "/")
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// relativeFilePath returns the path of the file relative to the base url,
+// or the file name if it is not within the base url
+func relativeFilePath(baseURL, fileURL string) string {
+	clean := func(u string) string { return strings.TrimPrefix(u, "file://") }
+	base, file := strings.TrimSuffix(clean(baseURL), "/"), clean(fileURL)
+	if rel := strings.TrimPrefix(file, base+"/"); rel != file {
+		return rel
+	}
+	return path.Base(file)
+}
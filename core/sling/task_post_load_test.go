@@ -0,0 +1,76 @@
+package sling
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPostLoadSourceFiles(t *testing.T) {
+	folder := t.TempDir()
+
+	writeSourceFiles := func() {
+		os.MkdirAll(filepath.Join(folder, "inbox", "sub"), 0755)
+		os.WriteFile(filepath.Join(folder, "inbox", "a.csv"), []byte("id,name\n1,a\n2,b\n"), 0644)
+		os.WriteFile(filepath.Join(folder, "inbox", "sub", "b.csv"), []byte("id,name\n3,c\n"), 0644)
+	}
+
+	runTask := func(postLoad *PostLoadOptions, target string) error {
+		cfg := &Config{
+			Source: Source{
+				Conn:    "file://",
+				Stream:  "file://" + folder + "/inbox/",
+				Options: &SourceOptions{PostLoad: postLoad},
+			},
+			Target: Target{Conn: "file://", Object: "file://" + folder + "/" + target},
+		}
+		return runTestTask(cfg)
+	}
+
+	// archive, keeping the sub folders
+	writeSourceFiles()
+	err := runTask(&PostLoadOptions{Action: "archive", ArchivePath: folder + "/archive/{YYYY}"}, "out1.csv")
+	if assert.NoError(t, err) {
+		year := time.Now().Format("2006")
+		assert.FileExists(t, filepath.Join(folder, "archive", year, "a.csv"))
+		assert.FileExists(t, filepath.Join(folder, "archive", year, "sub", "b.csv"))
+		assert.NoFileExists(t, filepath.Join(folder, "inbox", "a.csv"))
+		assert.NoFileExists(t, filepath.Join(folder, "inbox", "sub", "b.csv"))
+	}
+
+	// rename with suffix
+	writeSourceFiles()
+	err = runTask(&PostLoadOptions{Action: "rename", Suffix: ".done"}, "out2.csv")
+	if assert.NoError(t, err) {
+		assert.FileExists(t, filepath.Join(folder, "inbox", "a.csv.done"))
+		assert.FileExists(t, filepath.Join(folder, "inbox", "sub", "b.csv.done"))
+		assert.NoFileExists(t, filepath.Join(folder, "inbox", "a.csv"))
+	}
+
+	// delete
+	os.RemoveAll(filepath.Join(folder, "inbox"))
+	writeSourceFiles()
+	err = runTask(&PostLoadOptions{Action: "delete"}, "out3.csv")
+	if assert.NoError(t, err) {
+		content, _ := os.ReadFile(filepath.Join(folder, "out3.csv"))
+		assert.Contains(t, string(content), "3,c")
+		assert.NoFileExists(t, filepath.Join(folder, "inbox", "a.csv"))
+		assert.NoFileExists(t, filepath.Join(folder, "inbox", "sub", "b.csv"))
+	}
+
+	// invalid options
+	assert.Error(t, runTask(&PostLoadOptions{Action: "move"}, "out4.csv"))
+	assert.Error(t, runTask(&PostLoadOptions{Action: "archive"}, "out4.csv"))
+	assert.Error(t, runTask(&PostLoadOptions{Action: "archive", ArchivePath: "archive", ArchiveConn: "does_not_exist"}, "out4.csv"))
+}
+
+func TestRelativeFilePath(t *testing.T) {
+	assert.Equal(t, "a.csv", relativeFilePath("s3://bucket/inbox/", "s3://bucket/inbox/a.csv"))
+	assert.Equal(t, "sub/b.csv", relativeFilePath("s3://bucket/inbox", "s3://bucket/inbox/sub/b.csv"))
+	assert.Equal(t, "a.csv", relativeFilePath("/tmp/inbox/a.csv", "file:///tmp/inbox/a.csv"))
+	assert.Equal(t, "s3://bucket", rootURL("s3://bucket/inbox/a.csv"))
+	assert.Equal(t, "sftp://host:22", rootURL("sftp://host:22/inbox/"))
+}
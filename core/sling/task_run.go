@@ -30,6 +30,7 @@ var slingLoadedAtColumn = "_sling_loaded_at"
 var slingStreamURLColumn = "_sling_stream_url"
 var slingRowNumColumn = "_sling_row_num"
 var slingRowIDColumn = "_sling_row_id"
+var slingDedupeSeqColumn = "_sling_dedupe_seq"
 
 func init() {
 	// we need a webserver to get the pprof webserver
@@ -194,6 +195,20 @@ func (t *TaskExecution) getTgtDBConn(ctx context.Context) (conn database.Connect
 		return conn, nil
 	}
 
+	conn, err = t.newTgtDBConn(ctx)
+	if err != nil {
+		return
+	}
+
+	// cache connection is using replication from CLI
+	if t.isUsingPool() {
+		connPool[t.Config.TgtConn.Hash()] = conn
+	}
+	return
+}
+
+// newTgtDBConn initializes a new target connection, not cached
+func (t *TaskExecution) newTgtDBConn(ctx context.Context) (conn database.Connection, err error) {
 	options := g.M()
 	g.Unmarshal(g.Marshal(t.Config.Target.Options), &options)
 	tgtProps := append(
@@ -207,11 +222,6 @@ func (t *TaskExecution) getTgtDBConn(ctx context.Context) (conn database.Connect
 		return
 	}
 
-	// cache connection is using replication from CLI
-	if t.isUsingPool() {
-		connPool[t.Config.TgtConn.Hash()] = conn
-	}
-
 	// set bulk
 	if val := t.Config.Target.Options.UseBulk; val != nil && !*val {
 		conn.SetProp("use_bulk", "false")
@@ -343,6 +353,9 @@ func (t *TaskExecution) runFileToDB() (err error) {
 			t.Config.Source.UpdateKey = slingLoadedAtColumn
 		}
 		varMap := map[string]string{} // should always be number
+		if t.Config.SrcConn.Type == dbio.TypeFileAPI {
+			varMap = apiIncrementalVarMap
+		}
 		t.Config.IncrementalVal, err = getIncrementalValue(t.Config, tgtConn, varMap)
 		if err != nil {
 			err = g.Error(err, "Could not get incremental value")
@@ -376,10 +389,14 @@ func (t *TaskExecution) runFileToDB() (err error) {
 	t.Config.Target.Object = setSchema(cast.ToString(t.Config.Target.Data["schema"]), t.Config.Target.Object)
 	t.Config.Target.Options.TableTmp = setSchema(cast.ToString(t.Config.Target.Data["schema"]), t.Config.Target.Options.TableTmp)
 
+	// write the nested arrays into child tables, along with the parent
+	waitChildStreams := t.writeChildStreamsToDb()
+
 	t.SetProgress("writing to target database [mode: %s]", t.Config.Mode)
 	defer t.Cleanup()
 	cnt, err := t.WriteToDb(t.Config, t.df, tgtConn)
 	if err != nil {
+		waitChildStreams(err)
 		err = g.Error(err, "could not write to database")
 		if t.Config.Target.TmpTableCreated {
 			// need to drop residue
@@ -393,6 +410,24 @@ func (t *TaskExecution) runFileToDB() (err error) {
 
 	if err != nil {
 		err = g.Error(t.df.Err(), "error in transfer")
+		return
+	}
+
+	// wait for the nested arrays written into child tables
+	if err = waitChildStreams(nil); err != nil {
+		err = g.Error(err, "could not write child streams")
+		return
+	}
+
+	// data is committed, handle the source files
+	if err = t.postLoadSourceFiles(); err != nil {
+		err = g.Error(err, "could not apply post_load on source files")
+		return
+	}
+
+	// save the tap state for the next run
+	if err = t.persistSingerState(); err != nil {
+		err = g.Error(err, "could not persist singer state")
 	}
 	return
 }
@@ -428,9 +463,13 @@ func (t *TaskExecution) runFileToFile() (err error) {
 	} else {
 		t.SetProgress("writing to target file system (%s)", t.Config.TgtConn.Type)
 	}
+	// write the nested arrays into child files, along with the parent
+	waitChildStreams := t.writeChildStreamsToFile()
+
 	defer t.Cleanup()
 	cnt, err := t.WriteToFile(t.Config, t.df)
 	if err != nil {
+		waitChildStreams(err)
 		err = g.Error(err, "Could not WriteToFile")
 		return
 	}
@@ -439,6 +478,24 @@ func (t *TaskExecution) runFileToFile() (err error) {
 
 	if t.df.Err() != nil {
 		err = g.Error(t.df.Err(), "Error in runFileToFile")
+		return
+	}
+
+	// wait for the nested arrays written into child files
+	if err = waitChildStreams(nil); err != nil {
+		err = g.Error(err, "could not write child streams")
+		return
+	}
+
+	// data is written, handle the source files
+	if err = t.postLoadSourceFiles(); err != nil {
+		err = g.Error(err, "could not apply post_load on source files")
+		return
+	}
+
+	// save the tap state for the next run
+	if err = t.persistSingerState(); err != nil {
+		err = g.Error(err, "could not persist singer state")
 	}
 	return
 }
@@ -96,6 +96,20 @@ func (t *TaskExecution) ReadFromDB(cfg *Config, srcConn database.Connection) (df
 		selectFieldsStr = strings.Join(fields, ", ")
 	}
 
+	// push the sample down to the database if supported, else
+	// it is applied on the stream. a seed requires the stream sampling,
+	// and a full fraction is not a valid percentage for some databases
+	tableExpr := sTable.FDQN()
+	if sample := cfg.Source.Options.Sample; sample != nil {
+		template := srcConn.Template().Core["tablesample"]
+		if sample.Fraction > 0 && sample.Fraction < 1 && sample.Seed == nil && sTable.SQL == "" && template != "" {
+			tableExpr = g.R(template, "table", sTable.FDQN(), "percent", cast.ToString(sample.Percent()))
+			srcConn.SetProp("sample", "")
+		} else {
+			srcConn.SetProp("sample", g.Marshal(sample))
+		}
+	}
+
 	if t.usingCheckpoint() || t.Config.Mode == BackfillMode {
 		// default true value
 		incrementalWhereCond := "1=1"
@@ -162,7 +176,7 @@ func (t *TaskExecution) ReadFromDB(cfg *Config, srcConn database.Connection) (df
 			sTable.SQL = g.R(
 				`select{limit_top} {fields} from {table} where {incremental_where_cond} order by {update_key} asc {limit_end}`,
 				"fields", selectFieldsStr,
-				"table", sTable.FDQN(),
+				"table", tableExpr,
 				"incremental_where_cond", incrementalWhereCond,
 				"update_key", srcConn.Quote(cfg.Source.UpdateKey, false),
 				"limit_top", lo.Ternary(limitTop != "", " "+limitTop, ""),
@@ -191,7 +205,7 @@ func (t *TaskExecution) ReadFromDB(cfg *Config, srcConn database.Connection) (df
 		}
 	} else if cfg.Source.Limit() > 0 {
 		if sTable.SQL == "" {
-			sTable.SQL = "select * from " + sTable.FDQN()
+			sTable.SQL = "select * from " + tableExpr
 		}
 		sTable.SQL = g.R(
 			srcConn.Template().Core["limit"],
@@ -210,7 +224,9 @@ func (t *TaskExecution) ReadFromDB(cfg *Config, srcConn database.Connection) (df
 	sTable.SQL = g.R(sTable.SQL, "incremental_value", "null")     // if running non-incremental mode
 
 	// construct SELECT statement for selected fields
-	if sTable.SQL == "" && selectFieldsStr != "*" {
+	if sTable.SQL == "" && tableExpr != sTable.FDQN() {
+		sTable.SQL = g.F("select %s from %s", selectFieldsStr, tableExpr)
+	} else if sTable.SQL == "" && selectFieldsStr != "*" {
 		sTable.SQL = sTable.Select(strings.Split(selectFieldsStr, ",")...)
 	}
 
@@ -220,6 +236,14 @@ func (t *TaskExecution) ReadFromDB(cfg *Config, srcConn database.Connection) (df
 		return t.df, err
 	}
 
+	if cfg.Source.Options.Lookup != nil {
+		df, err = t.applyLookup(df)
+		if err != nil {
+			err = g.Error(err, "Could not apply lookup")
+			return t.df, err
+		}
+	}
+
 	err = t.setColumnKeys(df)
 	if err != nil {
 		err = g.Error(err, "Could not set column keys")
@@ -240,6 +264,9 @@ func (t *TaskExecution) ReadFromFile(cfg *Config) (df *iop.Dataflow, err error)
 	if cfg.SrcConn.URL() != "" {
 		// construct props by merging with options
 		options["SLING_FS_TIMESTAMP"] = t.Config.IncrementalVal
+		if cfg.SrcConn.Type == dbio.TypeFileAPI {
+			options["INCREMENTAL_VALUE"] = apiIncrementalValue(t.Config.IncrementalVal)
+		}
 		props := append(
 			g.MapToKVArr(cfg.SrcConn.DataS()),
 			g.MapToKVArr(g.ToMapString(options))...,
@@ -277,6 +304,14 @@ func (t *TaskExecution) ReadFromFile(cfg *Config) (df *iop.Dataflow, err error)
 		return df, g.Error("Could not read columns")
 	}
 
+	if cfg.Source.Options.Lookup != nil {
+		df, err = t.applyLookup(df)
+		if err != nil {
+			err = g.Error(err, "Could not apply lookup")
+			return t.df, err
+		}
+	}
+
 	err = t.setColumnKeys(df)
 	if err != nil {
 		err = g.Error(err, "Could not set column keys")
@@ -0,0 +1,249 @@
+package sling
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/flarco/g"
+	"github.com/samber/lo"
+	"github.com/slingdata-io/sling-cli/core/dbio"
+	"github.com/slingdata-io/sling-cli/core/dbio/connection"
+	"github.com/slingdata-io/sling-cli/core/dbio/filesys"
+	"github.com/slingdata-io/sling-cli/core/dbio/iop"
+	"github.com/spf13/cast"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPISourceIncremental(t *testing.T) {
+	users := []map[string]any{
+		{"id": 1, "name": "alice", "updated": "2024-01-01"},
+		{"id": 2, "name": "bob", "updated": "2024-01-02"},
+	}
+
+	sinceValues := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		since := r.URL.Query().Get("since")
+		sinceValues = append(sinceValues, since)
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		records := lo.Filter(users, func(u map[string]any, i int) bool {
+			return cast.ToString(u["updated"]) > since
+		})
+		w.Write([]byte(g.Marshal(g.M("results", records))))
+	}))
+	defer server.Close()
+
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	runTask := func() error {
+		srcConn, err := connection.NewConnection("MY_API", dbio.TypeFileAPI, g.M(
+			"base_url", server.URL+"/v1",
+			"token", "secret",
+		))
+		if err != nil {
+			return err
+		}
+
+		cfg := &Config{
+			Source: Source{
+				Conn:        "MY_API",
+				Stream:      "users",
+				PrimaryKeyI: []string{"id"},
+				UpdateKey:   "updated",
+				Options: &SourceOptions{
+					API: &filesys.APIOptions{Records: "results", IncrementalParam: "since"},
+				},
+			},
+			Target:  Target{Conn: "sqlite://" + dbPath, Object: "main.users"},
+			Mode:    IncrementalMode,
+			SrcConn: srcConn,
+		}
+		return runTestTask(cfg)
+	}
+
+	// first run loads everything, the next runs request newer records only
+	if !assert.NoError(t, runTask()) {
+		return
+	}
+	users = append(users, map[string]any{"id": 3, "name": "carl", "updated": "2024-01-03"})
+	if !assert.NoError(t, runTask()) {
+		return
+	}
+	if assert.Len(t, sinceValues, 2) {
+		assert.Equal(t, "", sinceValues[0])
+		assert.Equal(t, "2024-01-02", cast.ToTime(sinceValues[1]).Format("2006-01-02"))
+	}
+
+	conn, err := connection.NewConnection("", dbio.TypeDbSQLite, g.M("url", "sqlite://"+dbPath))
+	if !assert.NoError(t, err) {
+		return
+	}
+	dbConn, err := conn.AsDatabase()
+	if !assert.NoError(t, err) {
+		return
+	}
+	data, err := dbConn.Query("select id, name from users order by id")
+	if assert.NoError(t, err) {
+		assert.Len(t, data.Rows, 3)
+	}
+}
+
+func TestSingerSource(t *testing.T) {
+	folder := t.TempDir()
+	tapPath := filepath.Join(folder, "tap.sh")
+	tapScript := `#!/bin/sh
+echo '{"type": "SCHEMA", "stream": "users", "key_properties": ["id"], "schema": {"properties": {"id": {"type": "integer"}, "name": {"type": "string"}}}}'
+echo '{"type": "RECORD", "stream": "users", "record": {"id": 1, "name": "alice"}}'
+echo '{"type": "RECORD", "stream": "users", "record": {"id": 2, "name": "bob"}}'
+echo '{"type": "STATE", "value": {"bookmarks": {"users": 2}}}'
+`
+	os.WriteFile(tapPath, []byte(tapScript), 0755)
+
+	statePath := filepath.Join(folder, "state.json")
+	srcConn, err := connection.NewConnection("MY_TAP", dbio.TypeFileSinger, g.M(
+		"command", tapPath,
+		"state", statePath,
+	))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	dbPath := filepath.Join(folder, "test.db")
+	cfg := &Config{
+		Source:  Source{Conn: "MY_TAP", Stream: "users"},
+		Target:  Target{Conn: "sqlite://" + dbPath, Object: "main.users"},
+		Mode:    FullRefreshMode,
+		SrcConn: srcConn,
+	}
+	if !assert.NoError(t, runTestTask(cfg)) {
+		return
+	}
+
+	// state is persisted once loaded, keyed by stream
+	state, err := os.ReadFile(filepath.Join(folder, "state.users.json"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, `{"bookmarks": {"users": 2}}`, string(state))
+	}
+
+	conn, err := connection.NewConnection("", dbio.TypeDbSQLite, g.M("url", "sqlite://"+dbPath))
+	if !assert.NoError(t, err) {
+		return
+	}
+	dbConn, err := conn.AsDatabase()
+	if !assert.NoError(t, err) {
+		return
+	}
+	data, err := dbConn.Query("select id, name from users order by id")
+	if assert.NoError(t, err) {
+		assert.Len(t, data.Rows, 2)
+	}
+}
+
+func TestGenerateSource(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	cfg := &Config{
+		Source: Source{
+			Stream: "generate://customers",
+			Options: &SourceOptions{
+				Generate: &iop.GenerateOptions{
+					Rows: 250,
+					Columns: []iop.GenerateColumn{
+						{Name: "id", Distribution: iop.GenerateDistributionSequence, PrimaryKey: true},
+						{Name: "name", Faker: "name"},
+						{Name: "balance", Type: iop.DecimalType, Min: 0, Max: 100},
+					},
+				},
+			},
+		},
+		Target: Target{Conn: "sqlite://" + dbPath, Object: "main.customers"},
+		Mode:   FullRefreshMode,
+	}
+	if !assert.NoError(t, runTestTask(cfg)) {
+		return
+	}
+
+	conn, err := connection.NewConnection("", dbio.TypeDbSQLite, g.M("url", "sqlite://"+dbPath))
+	if !assert.NoError(t, err) {
+		return
+	}
+	dbConn, err := conn.AsDatabase()
+	if !assert.NoError(t, err) {
+		return
+	}
+	data, err := dbConn.Query("select count(*), max(id) from customers")
+	if assert.NoError(t, err) && assert.Len(t, data.Rows, 1) {
+		assert.EqualValues(t, 250, cast.ToInt(data.Rows[0][0]))
+		assert.EqualValues(t, 250, cast.ToInt(data.Rows[0][1]))
+	}
+}
+
+func TestSourceSample(t *testing.T) {
+	folder := t.TempDir()
+	dbURL := "sqlite://" + filepath.Join(folder, "test.db")
+	runTask := func(source Source, object string) error {
+		return runTestTask(&Config{Source: source, Target: Target{Conn: dbURL, Object: object}, Mode: FullRefreshMode})
+	}
+
+	// source table, sampled in the stream since sqlite has no tablesample
+	err := runTask(Source{
+		Stream: "generate://numbers",
+		Options: &SourceOptions{Generate: &iop.GenerateOptions{
+			Rows:    1000,
+			Columns: []iop.GenerateColumn{{Name: "id", Distribution: iop.GenerateDistributionSequence}},
+		}},
+	}, "main.numbers")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = runTask(Source{
+		Conn:    dbURL,
+		Stream:  "main.numbers",
+		Options: &SourceOptions{Sample: &iop.SampleOptions{Size: 25}},
+	}, "main.numbers_reservoir")
+	assert.NoError(t, err)
+
+	err = runTask(Source{
+		Conn:    dbURL,
+		Stream:  "main.numbers",
+		Options: &SourceOptions{Sample: &iop.SampleOptions{Fraction: 0.1, Seed: g.Int64(1)}},
+	}, "main.numbers_fraction")
+	assert.NoError(t, err)
+
+	csvPath := filepath.Join(folder, "numbers.csv")
+	os.WriteFile(csvPath, []byte("id\n1\n2\n3\n4\n5\n"), 0644)
+	err = runTask(Source{
+		Stream:  "file://" + csvPath,
+		Options: &SourceOptions{Sample: &iop.SampleOptions{First: 3}},
+	}, "main.numbers_first")
+	assert.NoError(t, err)
+
+	// invalid sample
+	err = runTask(Source{
+		Stream:  "file://" + csvPath,
+		Options: &SourceOptions{Sample: &iop.SampleOptions{First: 3, Size: 2}},
+	}, "main.numbers_invalid")
+	assert.Error(t, err)
+
+	conn, err := connection.NewConnection("", dbio.TypeDbSQLite, g.M("url", dbURL))
+	if !assert.NoError(t, err) {
+		return
+	}
+	dbConn, err := conn.AsDatabase()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	count := func(table string) int {
+		data, err := dbConn.Query("select count(*) from " + table)
+		assert.NoError(t, err)
+		return cast.ToInt(data.Rows[0][0])
+	}
+	assert.Equal(t, 25, count("numbers_reservoir"))
+	assert.InDelta(t, 100, count("numbers_fraction"), 50)
+	assert.Equal(t, 3, count("numbers_first"))
+}
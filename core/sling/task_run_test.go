@@ -0,0 +1,23 @@
+package sling
+
+import (
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	StoreUpdate = func(t *TaskExecution) {} // no store in tests
+	os.Exit(m.Run())
+}
+
+// runTestTask prepares the config, and executes its task
+func runTestTask(cfg *Config) error {
+	if err := cfg.Prepare(); err != nil {
+		return err
+	}
+	task := NewTask("", cfg)
+	if task.Err != nil {
+		return task.Err
+	}
+	return task.Execute()
+}
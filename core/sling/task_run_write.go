@@ -30,6 +30,18 @@ func (t *TaskExecution) WriteToFile(cfg *Config, df *iop.Dataflow) (cnt uint64,
 		// construct props by merging with options
 		options := g.M()
 		g.Unmarshal(g.Marshal(cfg.Target.Options), &options)
+		if layout := cfg.Target.Options.Layout; layout != nil {
+			options["layout"] = layoutOptionString(layout)
+		}
+		if encryption := cfg.Target.Options.Encryption; encryption != nil {
+			options["encryption"] = g.Marshal(encryption)
+		}
+		if api := cfg.Target.Options.API; api != nil {
+			options["api"] = g.Marshal(api)
+		}
+		if g.In(cfg.Mode, IncrementalMode, SnapshotMode, BackfillMode) {
+			options["delta_mode"] = "append" // for delta tables, otherwise overwrite
+		}
 		props := append(
 			g.MapToKVArr(cfg.TgtConn.DataS()),
 			g.MapToKVArr(g.ToMapString(options))...,
@@ -361,10 +373,15 @@ func (t *TaskExecution) WriteToDb(cfg *Config, df *iop.Dataflow, tgtConn databas
 			}
 		}
 
-		// create table if not exists
+		// create table if not exists, without the internal dedupe sequence
 		sample := iop.NewDataset(df.Columns)
 		sample.Rows = df.Buffer
 		sample.Inferred = true // already inferred with SyncStats
+		if cfg.dedupeBySeq() {
+			sample.Columns = lo.Filter(df.Columns, func(col iop.Column, i int) bool {
+				return !strings.EqualFold(col.Name, slingDedupeSeqColumn)
+			})
+		}
 
 		created, err := createTableIfNotExists(tgtConn, sample, targetTable)
 		if err != nil {
@@ -402,7 +419,7 @@ func (t *TaskExecution) WriteToDb(cfg *Config, df *iop.Dataflow, tgtConn databas
 					return cnt, g.Error(err, "could not optimize table schema")
 				} else if ok {
 					cfg.Target.columns = targetTable.Columns
-					for i := range df.Columns {
+					for i := range sample.Columns {
 						df.Columns[i].Type = targetTable.Columns[i].Type
 						df.Columns[i].DbType = targetTable.Columns[i].DbType
 						for _, ds := range df.StreamMap {
@@ -461,7 +478,17 @@ func (t *TaskExecution) WriteToDb(cfg *Config, df *iop.Dataflow, tgtConn databas
 		// create final if not exists
 		// delete from final and insert
 		// or update (such as merge or ON CONFLICT)
-		rowAffCnt, err := tgtConn.Upsert(tableTmp.FullName(), targetTable.FullName(), cfg.Source.PrimaryKey())
+		srcTable := tableTmp.FullName()
+		if dedupe := cfg.Target.Options.Dedupe; dedupe != nil && *dedupe {
+			srcTable, err = dedupeTemp(cfg, tgtConn, tableTmp)
+			if err != nil {
+				err = g.Error(err, "Could not dedupe temp table")
+				return 0, err
+			}
+			defer tgtConn.DropTable(srcTable)
+		}
+
+		rowAffCnt, err := tgtConn.Upsert(srcTable, targetTable.FullName(), cfg.Source.PrimaryKey())
 		if err != nil {
 			err = g.Error(err, "Could not incremental from temp")
 			// data is still in temp table at this point
@@ -0,0 +1,116 @@
+package sling
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/flarco/g"
+	"github.com/samber/lo"
+	"github.com/slingdata-io/sling-cli/core/dbio"
+	"github.com/slingdata-io/sling-cli/core/dbio/connection"
+	"github.com/slingdata-io/sling-cli/core/dbio/filesys"
+	"github.com/spf13/cast"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTargetDedupe(t *testing.T) {
+	folder := t.TempDir()
+	dbURL := "sqlite://" + filepath.Join(folder, "test.db")
+	csvPath := filepath.Join(folder, "users.csv")
+	os.WriteFile(csvPath, []byte("id,name,updated\n1,alice,2024-01-02\n2,bob,2024-01-01\n1,alicia,2024-01-03\n2,bobby,2023-12-31\n1,al,2024-01-01\n3,carl,2024-01-05\n3,carla,2024-01-05\n3,carlos,\n"), 0644)
+
+	runTask := func(object, updateKey string, dedupe bool) error {
+		cfg := &Config{
+			Source: Source{Stream: "file://" + csvPath, PrimaryKeyI: []string{"id"}, UpdateKey: updateKey},
+			Target: Target{Conn: dbURL, Object: object, Options: &TargetOptions{Dedupe: g.Bool(dedupe)}},
+			Mode:   IncrementalMode,
+		}
+		return runTestTask(cfg)
+	}
+
+	conn, err := connection.NewConnection("", dbio.TypeDbSQLite, g.M("url", dbURL))
+	if !assert.NoError(t, err) {
+		return
+	}
+	dbConn, err := conn.AsDatabase()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	names := func(table string) []string {
+		data, err := dbConn.Query("select name from " + table + " order by id")
+		assert.NoError(t, err)
+		return lo.Map(data.Rows, func(row []any, i int) string { return cast.ToString(row[0]) })
+	}
+
+	// latest by update key, the last seen for ties, and nulls last
+	if assert.NoError(t, runTask("main.users_latest", "updated", true)) {
+		assert.Equal(t, []string{"alicia", "bob", "carla"}, names("users_latest"))
+	}
+
+	// last seen, without update key
+	if assert.NoError(t, runTask("main.users_last", "", true)) {
+		assert.Equal(t, []string{"al", "bobby", "carlos"}, names("users_last"))
+
+		// the internal sequence is not loaded
+		columns, err := dbConn.GetColumns("main.users_last")
+		if assert.NoError(t, err) {
+			assert.NotContains(t, columns.Names(), slingDedupeSeqColumn)
+			assert.NotContains(t, columns.Names(), slingRowNumColumn)
+		}
+	}
+
+	// requires a primary key
+	cfg := &Config{
+		Source: Source{Stream: "file://" + csvPath},
+		Target: Target{Conn: dbURL, Object: "main.users_nokey", Options: &TargetOptions{Dedupe: g.Bool(true)}},
+	}
+	assert.Error(t, cfg.Prepare())
+}
+
+func TestAPITarget(t *testing.T) {
+	batches := [][]map[string]any{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		records := []map[string]any{}
+		if r.URL.Path != "/v1/contacts" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		if err := g.Unmarshal(string(body), &records); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		batches = append(batches, records)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	csvPath := filepath.Join(t.TempDir(), "contacts.csv")
+	os.WriteFile(csvPath, []byte("id,email\n1,a@x.com\n2,b@x.com\n3,c@x.com\n"), 0644)
+
+	tgtConn, err := connection.NewConnection("CRM", dbio.TypeFileAPI, g.M("base_url", server.URL+"/v1"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	cfg := &Config{
+		Source: Source{Conn: "file://", Stream: "file://" + csvPath},
+		Target: Target{
+			Conn:    "CRM",
+			Object:  "contacts",
+			Options: &TargetOptions{API: &filesys.APIOptions{BatchSize: 2}, Concurrency: 1},
+		},
+		TgtConn: tgtConn,
+	}
+	if assert.NoError(t, runTestTask(cfg)) {
+		if assert.Len(t, batches, 2) {
+			assert.Len(t, batches[0], 2)
+			assert.Equal(t, "c@x.com", batches[1][0]["email"])
+		}
+	}
+}
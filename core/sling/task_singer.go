@@ -0,0 +1,28 @@
+package sling
+
+import (
+	"github.com/flarco/g"
+	"github.com/slingdata-io/sling-cli/core/dbio"
+	"github.com/slingdata-io/sling-cli/core/dbio/filesys"
+)
+
+// persistSingerState writes the latest state of the Singer tap into the
+// state file of the stream, so that the next run resumes from it.
+// It is called once the data is committed to the target.
+func (t *TaskExecution) persistSingerState() (err error) {
+	if t.Config.SrcConn.Type != dbio.TypeFileSinger || t.df == nil || t.df.Err() != nil {
+		return nil
+	}
+
+	statePath := t.df.SingerStatePath
+	if statePath == "" || t.df.SingerState == "" {
+		return nil
+	}
+
+	if err = filesys.WriteSingerState(statePath, t.df.SingerState); err != nil {
+		return g.Error(err, "could not write state to %s", statePath)
+	}
+	g.Debug("wrote singer state to %s", statePath)
+
+	return nil
+}
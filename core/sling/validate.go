@@ -0,0 +1,193 @@
+package sling
+
+import (
+	"os"
+	"strings"
+
+	"github.com/flarco/g"
+	"github.com/samber/lo"
+	"github.com/slingdata-io/sling-cli/core/dbio/connection"
+	"github.com/spf13/cast"
+	"gopkg.in/yaml.v2"
+)
+
+// ValidateFile checks a task or replication file without running it.
+// A file with a `streams` or `include` key is a replication, which is
+// validated with its includes resolved. It returns the issues found,
+// such as unknown keys, invalid modes, connections not found or
+// incremental streams without keys. The error is set when the file
+// cannot be read or parsed.
+func ValidateFile(cfgPath string) (issues []string, err error) {
+	cfgBytes, err := os.ReadFile(cfgPath)
+	if err != nil {
+		return nil, g.Error(err, "could not read %s", cfgPath)
+	}
+
+	// only replications are templates, tasks are validated as is
+	if !isReplicationText(string(cfgBytes)) {
+		return ValidateTask(string(cfgBytes))
+	}
+
+	replicYAML, _, err := readReplicationFile(cfgPath)
+	if err != nil {
+		return nil, err
+	}
+	return validateReplication(replicYAML)
+}
+
+// isReplicationText returns true when the text has streams or includes,
+// with its template expressions masked. A text which only parses once
+// rendered is a replication as well, since tasks are not rendered.
+func isReplicationText(text string) bool {
+	m, err := parseConfigMap(maskTemplate(text))
+	if err != nil {
+		return strings.Contains(text, "{{")
+	}
+
+	_, hasStreams := m["streams"]
+	_, hasInclude := m["include"]
+	return hasStreams || hasInclude
+}
+
+// ValidateReplication checks the replication text against its schema,
+// and the connections and keys of its streams
+func ValidateReplication(replicYAML string) (issues []string, err error) {
+	replicYAML, err = RenderReplication(replicYAML)
+	if err != nil {
+		return nil, err
+	}
+	return validateReplication(replicYAML)
+}
+
+// validateReplication checks a rendered replication text
+func validateReplication(replicYAML string) (issues []string, err error) {
+	m, err := parseConfigMap(replicYAML)
+	if err != nil {
+		return nil, err
+	}
+
+	issues = ReplicationSchema().Validate(m, "")
+	if len(issues) > 0 {
+		return issues, nil // the structure must be valid to continue
+	}
+
+	replication, err := unmarshalReplication(replicYAML)
+	if err != nil {
+		return nil, err
+	}
+
+	connsMap := localConnsMap()
+	issues = append(issues, validateConn("source", replication.Source, connsMap)...)
+	issues = append(issues, validateConn("target", replication.Target, connsMap)...)
+
+	for _, name := range replication.StreamsOrdered() {
+		stream := replication.Streams[name]
+		if stream == nil {
+			stream = &ReplicationStreamConfig{}
+		}
+
+		// copy so that the replication defaults are not shared
+		streamCopy := ReplicationStreamConfig{}
+		g.Unmarshal(g.Marshal(stream), &streamCopy)
+		SetStreamDefaults(&streamCopy, replication)
+
+		path := "streams." + name
+		if streamCopy.Object == "" {
+			issues = append(issues, path+": missing 'object' (in stream or defaults)")
+		}
+		issues = append(issues, validateKeys(path, streamCopy.Mode, streamCopy.PrimaryKey(), streamCopy.UpdateKey)...)
+	}
+
+	return issues, nil
+}
+
+// ValidateTask checks the task text against its schema, and its connections and keys
+func ValidateTask(cfgStr string) (issues []string, err error) {
+	cfgStr = expandEnvVars(cfgStr)
+
+	m, err := parseConfigMap(cfgStr)
+	if err != nil {
+		return nil, err
+	}
+
+	issues = TaskSchema().Validate(m, "")
+	if len(issues) > 0 {
+		return issues, nil // the structure must be valid to continue
+	}
+
+	cfg := Config{}
+	if err = yaml.Unmarshal([]byte(cfgStr), &cfg); err != nil {
+		return nil, err
+	}
+
+	connsMap := localConnsMap()
+	issues = append(issues, validateConn("source.conn", cfg.Source.Conn, connsMap)...)
+	issues = append(issues, validateConn("target.conn", cfg.Target.Conn, connsMap)...)
+	issues = append(issues, validateKeys("source", cfg.Mode, cfg.Source.PrimaryKey(), cfg.Source.UpdateKey)...)
+
+	return issues, nil
+}
+
+// parseConfigMap parses the yaml (or json) text into a map with string keys
+func parseConfigMap(text string) (m map[string]any, err error) {
+	var value any
+	if err = yaml.Unmarshal([]byte(text), &value); err != nil {
+		return nil, g.Error(err, "invalid yaml")
+	}
+
+	m, ok := stringKeys(value).(map[string]any)
+	if !ok {
+		return nil, g.Error("expected a map at the root")
+	}
+	return m, nil
+}
+
+// stringKeys converts the nested yaml maps to maps with string keys
+func stringKeys(value any) any {
+	switch v := value.(type) {
+	case map[any]any:
+		m := make(map[string]any, len(v))
+		for key, val := range v {
+			m[cast.ToString(key)] = stringKeys(val)
+		}
+		return m
+	case []any:
+		for i, val := range v {
+			v[i] = stringKeys(val)
+		}
+	}
+	return value
+}
+
+// localConnsMap returns the local connections, keyed by lower case name
+func localConnsMap() map[string]connection.ConnEntry {
+	return lo.KeyBy(connection.GetLocalConns(), func(c connection.ConnEntry) string {
+		return strings.ToLower(c.Connection.Name)
+	})
+}
+
+// validateConn checks that a named connection exists. URLs
+// and environment variable references are not checked.
+func validateConn(path, name string, connsMap map[string]connection.ConnEntry) (issues []string) {
+	if name == "" || strings.Contains(name, "://") || strings.HasPrefix(name, "$") {
+		return nil
+	} else if _, ok := connsMap[strings.ToLower(name)]; !ok {
+		return []string{g.F("%s: connection '%s' not found", path, name)}
+	}
+	return nil
+}
+
+// validateKeys checks that the keys required by the mode are set
+func validateKeys(path string, mode Mode, primaryKey []string, updateKey string) (issues []string) {
+	switch mode {
+	case IncrementalMode:
+		if len(primaryKey) == 0 && updateKey == "" {
+			issues = append(issues, path+": incremental mode requires 'primary_key' and/or 'update_key'")
+		}
+	case BackfillMode:
+		if len(primaryKey) == 0 || updateKey == "" {
+			issues = append(issues, path+": backfill mode requires 'primary_key' and 'update_key'")
+		}
+	}
+	return issues
+}